@@ -0,0 +1,790 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// アクション構造体
+type Action struct {
+	Name string              // アクション名 (コマンドパレットに表示)
+	Run  func(v *View) uint8 // 処理本体 (0以外を返すとエディタを終了)
+}
+
+// 利用可能なアクションの一覧 (コマンドパレットでの表示順)
+func newActions() []*Action {
+	actions := []*Action{
+		{"Command Palette", (*View).actionCommandPalette},
+		{"Open File", (*View).actionOpenFile},
+		{"Toggle Sidebar", (*View).actionToggleSidebar},
+		{"Save", (*View).actionSave},
+		{"Save All and Quit", (*View).actionSaveAllAndQuit},
+		{"Next Tab", (*View).actionNextTab},
+		{"Previous Tab", (*View).actionPrevTab},
+		{"Move Tab Left", (*View).actionMoveTabLeft},
+		{"Move Tab Right", (*View).actionMoveTabRight},
+		{"Close Tab", (*View).actionCloseTab},
+		{"Buffer List", (*View).actionBufferList},
+		{"Window Command", (*View).actionWindowCommand},
+		{"Split Down", (*View).actionSplitDown},
+		{"Split Right", (*View).actionSplitRight},
+		{"Close Pane", (*View).actionClosePane},
+		{"Focus Next Pane", (*View).actionFocusNextPane},
+		{"Focus Pane Left", (*View).actionFocusPaneLeft},
+		{"Focus Pane Right", (*View).actionFocusPaneRight},
+		{"Focus Pane Up", (*View).actionFocusPaneUp},
+		{"Focus Pane Down", (*View).actionFocusPaneDown},
+		{"Increase Pane Height", (*View).actionIncreasePaneHeight},
+		{"Decrease Pane Height", (*View).actionDecreasePaneHeight},
+		{"Increase Pane Width", (*View).actionIncreasePaneWidth},
+		{"Decrease Pane Width", (*View).actionDecreasePaneWidth},
+		{"Equalize Panes", (*View).actionEqualizePanes},
+		{"Move Top", (*View).actionMoveTop},
+		{"Move Bottom", (*View).actionMoveBottom},
+		{"Go to Line", (*View).actionGotoLine},
+		{"Jump Back", (*View).actionJumpBack},
+		{"Jump Forward", (*View).actionJumpForward},
+		{"Cursor Up", (*View).actionCursorUp},
+		{"Cursor Down", (*View).actionCursorDown},
+		{"Cursor Left", (*View).actionCursorLeft},
+		{"Cursor Right", (*View).actionCursorRight},
+		{"Undo", (*View).actionUndo},
+		{"Redo", (*View).actionRedo},
+		{"Insert Newline", (*View).actionNewline},
+		{"Indent", (*View).actionIndent},
+		{"Outdent", (*View).actionOutdent},
+		{"Toggle Comment", (*View).actionToggleComment},
+		{"Fold", (*View).actionFold},
+		{"Unfold", (*View).actionUnfold},
+		{"Toggle Fold", (*View).actionToggleFold},
+		{"Fold All", (*View).actionFoldAll},
+		{"Unfold All", (*View).actionUnfoldAll},
+		{"Fold to Level", (*View).actionFoldToLevel},
+		{"Delete Backward", (*View).actionBackspace},
+		{"Delete Forward", (*View).actionDeleteForward},
+		{"Set Mark", (*View).actionSetMark},
+		{"Exchange Point and Mark", (*View).actionExchangeMark},
+		{"Kill Line", (*View).actionKillLine},
+		{"Kill Region", (*View).actionKillRegion},
+		{"Copy Region", (*View).actionCopyRegion},
+		{"Yank", (*View).actionYank},
+		{"Block Select Up", (*View).actionBlockSelectUp},
+		{"Block Select Down", (*View).actionBlockSelectDown},
+		{"Block Select Left", (*View).actionBlockSelectLeft},
+		{"Block Select Right", (*View).actionBlockSelectRight},
+		{"Add Cursor at Next Match", (*View).actionAddCursorNextMatch},
+		{"Add Cursors to Lines", (*View).actionAddCursorsToLines},
+		{"Add Cursor Above", (*View).actionAddCursorAbove},
+		{"Add Cursor Below", (*View).actionAddCursorBelow},
+		{"Record Macro", (*View).actionRecordMacro},
+		{"Record Macro to Register", (*View).actionRecordMacroTo},
+		{"Play Macro", (*View).actionPlayMacro},
+		{"Play Macro from Register", (*View).actionPlayMacroFrom},
+		{"Cancel", (*View).actionCancel},
+		{"Exit", (*View).actionExit},
+	}
+	actions = append(actions, motionActions()...)
+	for n := 1; n <= 9; n++ { // Go to Tab 1 ~ 9
+		n := n
+		actions = append(actions, &Action{fmt.Sprintf("Go to Tab %d", n), func(v *View) uint8 {
+			return v.actionGoToTab(n)
+		}})
+	}
+	return actions
+}
+
+// 名前からアクションを取得
+func (v *View) FindAction(name string) *Action {
+	for _, a := range v.Actions {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// 名前を指定してアクションを実行
+func (v *View) RunAction(name string) uint8 {
+	a := v.FindAction(name)
+	if a == nil {
+		return 0
+	}
+	if caretActions[name] && !v.splitBlockSelection(false) { // 矩形選択の各行での入力・削除
+		v.RefleshTextField()
+		return 0
+	}
+	if isCaretAction(name) && v.eachCaret(func() { a.Run(v) }) { // 複数カーソルの場合は全てのカーソルで実行
+		return 0
+	}
+	return a.Run(v)
+}
+
+// 最近使用したアクションとして記録 (先頭が最新)
+func (v *View) TouchRecentAction(name string) {
+	for i, n := range v.RecentActions {
+		if n == name {
+			v.RecentActions = append(v.RecentActions[:i], v.RecentActions[i+1:]...)
+			break
+		}
+	}
+	v.RecentActions = append([]string{name}, v.RecentActions...)
+}
+
+func (v *View) actionCommandPalette() uint8 {
+	v.OpenOverlay(NewPalette(v))
+	return 0
+}
+
+func (v *View) actionOpenFile() uint8 {
+	if v.Files == nil {
+		v.Files = NewFileIndex(".")
+	} else {
+		v.Files.Rescan() // 前回の走査以降に追加・削除されたファイルを反映
+	}
+	v.OpenOverlay(NewFinder(v))
+	return 0
+}
+
+// ファイルツリーの表示切り替え (非表示 -> 表示してフォーカス -> 非表示)
+func (v *View) actionToggleSidebar() uint8 {
+	if v.Sidebar == nil {
+		v.Sidebar = NewSidebar(".")
+	} else if !v.Sidebar.Visible {
+		v.Sidebar.Visible = true
+		v.Sidebar.Focused = true
+	} else if !v.Sidebar.Focused {
+		v.Sidebar.Focused = true
+	} else {
+		v.Sidebar.Visible = false
+		v.Sidebar.Focused = false
+	}
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionSave() uint8 {
+	return v.Save(v.GetCurrentTab().Buffer, nil)
+}
+
+func (v *View) actionSaveAllAndQuit() uint8 {
+	return v.saveAll(v.dirtyTabs(), func(v *View) uint8 {
+		return 1
+	})
+}
+
+func (v *View) actionNextTab() uint8 {
+	v.AutosaveTabSwitch()
+	v.NextTab()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionPrevTab() uint8 {
+	v.AutosaveTabSwitch()
+	v.PrevTab()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionMoveTabLeft() uint8 {
+	idx := v.TabIndex()
+	if v.MoveTabTo(idx, idx-1) {
+		v.UpdateTabBar()
+	}
+	return 0
+}
+
+func (v *View) actionMoveTabRight() uint8 {
+	idx := v.TabIndex()
+	if v.MoveTabTo(idx, idx+1) {
+		v.UpdateTabBar()
+	}
+	return 0
+}
+
+// n番目のタブへ移動 (1始まり)
+func (v *View) actionGoToTab(n int) uint8 {
+	if n-1 == v.TabIndex() || n > len(v.Tabs) {
+		return 0
+	}
+	v.AutosaveTabSwitch()
+	v.MoveTab(n - 1)
+	v.Reflesh()
+	return 0
+}
+
+// 未保存の場合は保存するか確認してからタブを閉じる (最後のタブを閉じた場合はエディタを終了)
+func (v *View) actionCloseTab() uint8 {
+	e := v.GetCurrentTab().Buffer
+	dirty := make([]*Buffer, 0)
+	if !e.IsSaved {
+		dirty = append(dirty, e)
+	}
+	return v.guardUnsaved(dirty, func(v *View) uint8 {
+		v.focusTab(e)
+		if !v.DeleteTab() {
+			return 1
+		}
+		v.Reflesh()
+		return 0
+	})
+}
+
+func (v *View) actionBufferList() uint8 {
+	v.OpenOverlay(NewBufferList(v))
+	return 0
+}
+
+// ウィンドウ操作のキー入力待ち (CTRL_Wに続けて入力)
+func (v *View) actionWindowCommand() uint8 {
+	v.OpenOverlay(&WindowMode{})
+	return 0
+}
+
+func (v *View) actionSplitDown() uint8 {
+	if !v.SplitPane(SPLIT_HORIZONTAL) {
+		v.SetMessage("Pane is too small to split")
+		return 0
+	}
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionSplitRight() uint8 {
+	if !v.SplitPane(SPLIT_VERTICAL) {
+		v.SetMessage("Pane is too small to split")
+		return 0
+	}
+	v.Reflesh()
+	return 0
+}
+
+// 現在のペインを閉じる (バッファは閉じない)
+func (v *View) actionClosePane() uint8 {
+	if !v.ClosePane() {
+		v.SetMessage("Cannot close the last pane")
+		return 0
+	}
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionFocusNextPane() uint8 {
+	v.FocusNextPane()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionFocusPaneLeft() uint8 {
+	v.FocusPaneDir(-1, 0)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionFocusPaneRight() uint8 {
+	v.FocusPaneDir(1, 0)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionFocusPaneUp() uint8 {
+	v.FocusPaneDir(0, -1)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionFocusPaneDown() uint8 {
+	v.FocusPaneDir(0, 1)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionIncreasePaneHeight() uint8 {
+	v.ResizePane(SPLIT_HORIZONTAL, 1)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionDecreasePaneHeight() uint8 {
+	v.ResizePane(SPLIT_HORIZONTAL, -1)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionIncreasePaneWidth() uint8 {
+	v.ResizePane(SPLIT_VERTICAL, 1)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionDecreasePaneWidth() uint8 {
+	v.ResizePane(SPLIT_VERTICAL, -1)
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionEqualizePanes() uint8 {
+	v.EqualizePanes()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionMoveTop() uint8 {
+	v.clearSelection()
+	v.PushJump()
+	cTab := v.GetCurrentTab()
+	cTab.MoveHeadRow()
+	cTab.ScrollHead()
+	v.RefleshTextField()
+	return 0
+}
+
+func (v *View) actionMoveBottom() uint8 {
+	v.clearSelection()
+	v.PushJump()
+	cTab := v.GetCurrentTab()
+	cTab.MoveTailRow()
+	cTab.ScrollTail()
+	v.RefleshTextField()
+	return 0
+}
+
+func (v *View) actionGotoLine() uint8 {
+	v.promptGoto()
+	return 0
+}
+
+func (v *View) actionJumpBack() uint8 {
+	if !v.JumpBack() {
+		v.SetMessage("No older jump")
+	}
+	return 0
+}
+
+func (v *View) actionJumpForward() uint8 {
+	if !v.JumpForward() {
+		v.SetMessage("No newer jump")
+	}
+	return 0
+}
+
+func (v *View) actionCursorUp() uint8 {
+	v.clearSelection()
+	cTab := v.GetCurrentTab()
+	if !cTab.IsFirstRow() {
+		cTab.MovePrevRow()
+		v.ScrollUp()
+	} else {
+		v.Fail()
+	}
+	return 0
+}
+
+func (v *View) actionCursorDown() uint8 {
+	v.clearSelection()
+	cTab := v.GetCurrentTab()
+	if !cTab.IsLastRow() {
+		cTab.MoveNextRow()
+		v.ScrollDown()
+	} else {
+		v.Fail()
+	}
+	return 0
+}
+
+func (v *View) actionCursorLeft() uint8 {
+	v.clearSelection()
+	cTab := v.GetCurrentTab()
+	if !cTab.IsFirstCol() {
+		cTab.MovePrevCol()
+		v.RefleshCursor()
+		v.UpdateStatusBar()
+	} else {
+		v.Fail()
+	}
+	return 0
+}
+
+func (v *View) actionCursorRight() uint8 {
+	v.clearSelection()
+	cTab := v.GetCurrentTab()
+	if !cTab.IsLastCol() {
+		cTab.MoveNextCol()
+		v.RefleshCursor()
+		v.UpdateStatusBar()
+	} else {
+		v.Fail()
+	}
+	return 0
+}
+
+func (v *View) actionUndo() uint8 {
+	if !v.GetCurrentTab().Undo() {
+		v.SetMessage("Already at oldest change")
+		return 0
+	}
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionRedo() uint8 {
+	if !v.GetCurrentTab().Redo() {
+		v.SetMessage("Already at newest change")
+		return 0
+	}
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionNewline() uint8 {
+	cTab := v.GetCurrentTab()
+	cTab.SaveUndo()
+	cTab.DeleteSelection()
+	cTab.IsSaved = false
+	cTab.NewlineIndented()
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+// 選択範囲の行のインデントを1段階深くする (選択していない場合は次のタブ位置までスペースを入力)
+func (v *View) actionIndent() uint8 {
+	cTab := v.GetCurrentTab()
+	if _, _, ok := cTab.Selection(); !ok || cTab.SelMode == SELECT_BLOCK {
+		v.insertSoftTab()
+		return 0
+	}
+	cTab.SaveUndo()
+	top, bottom := cTab.selectedRows()
+	cTab.IndentLines(top, bottom, 1)
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return 0
+}
+
+// 選択範囲またはカーソルの行のインデントを1段階浅くする
+func (v *View) actionOutdent() uint8 {
+	cTab := v.GetCurrentTab()
+	cTab.SaveUndo()
+	top, bottom := cTab.selectedRows()
+	cTab.IndentLines(top, bottom, -1)
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return 0
+}
+
+// 選択範囲またはカーソルの行のコメントの切り替え
+func (v *View) actionToggleComment() uint8 {
+	cTab := v.GetCurrentTab()
+	if !cTab.ToggleComment() {
+		v.SetMessage("No comment syntax for %s", LanguageOf(cTab.FilePath))
+		return 0
+	}
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return 0
+}
+
+func (v *View) actionFold() uint8 {
+	if !v.GetCurrentTab().CloseFold() {
+		v.SetMessage("No fold at cursor")
+		v.Fail()
+		return 0
+	}
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionUnfold() uint8 {
+	if !v.GetCurrentTab().OpenFold() {
+		v.SetMessage("No closed fold at cursor")
+		v.Fail()
+		return 0
+	}
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+// カーソル位置の範囲が閉じている場合は開き、開いている場合は閉じる
+func (v *View) actionToggleFold() uint8 {
+	if v.GetCurrentTab().OpenFold() {
+		v.ScrollToCursor()
+		v.Reflesh()
+		return 0
+	}
+	return v.actionFold()
+}
+
+func (v *View) actionFoldAll() uint8 {
+	v.GetCurrentTab().FoldToLevel(0)
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionUnfoldAll() uint8 {
+	v.GetCurrentTab().OpenAllFolds()
+	v.ScrollToCursor()
+	v.Reflesh()
+	return 0
+}
+
+func (v *View) actionFoldToLevel() uint8 {
+	v.promptFoldLevel()
+	return 0
+}
+
+func (v *View) actionBackspace() uint8 {
+	cTab := v.GetCurrentTab()
+	if cTab.Anchor != nil || !cTab.IsFirstCol() || !cTab.IsFirstRow() {
+		v.saveTypingUndo()
+	}
+	if cTab.DeleteSelection() { // 選択範囲がある場合は選択範囲のみを削除
+		v.ScrollToCursor()
+		v.RefleshTextField()
+	} else if !cTab.IsFirstCol() { // カーソルの前の文字を削除
+		cTab.IsSaved = false
+		if cTab.inEmptyPair() { // 空の括弧・引用符の組は両方を削除
+			cTab.Lines[cTab.Cursor.Row-1].Erase(int(cTab.Cursor.Col - 1))
+		}
+		cTab.Lines[cTab.Cursor.Row-1].Erase(int(cTab.Cursor.Col - 2))
+		cTab.changedFrom(cTab.Cursor.Row)
+		cTab.MovePrevCol()
+		v.RefleshTargetRow(cTab.Cursor.Row)
+		v.UpdateTabBar()
+		v.UpdateStatusBar()
+	} else if !cTab.IsFirstRow() { // 行頭の場合は前の行と連結
+		cTab.IsSaved = false
+		tmp := cTab.Lines[cTab.Cursor.Row-1].GetAll()
+		cTab.DeleteLine(uint(cTab.Cursor.Row - 1))
+		cTab.MovePrevRow()
+		cTab.MoveTailCol()
+		cTab.Lines[cTab.Cursor.Row-1].AppendAll(tmp)
+		cTab.changedFrom(cTab.Cursor.Row)
+		v.ScrollToCursor()
+		v.Reflesh()
+	}
+	return 0
+}
+
+// 入力中の操作の中断 (メッセージの消去と選択・追加のカーソルの解除)
+func (v *View) actionCancel() uint8 {
+	v.Message = ""
+	if v.GetCurrentTab().ClearCarets() {
+		v.RefleshTextField()
+	}
+	v.clearSelection()
+	v.UpdateStatusBar()
+	return 0
+}
+
+func (v *View) actionDeleteForward() uint8 {
+	cTab := v.GetCurrentTab()
+	if cTab.Anchor != nil || !cTab.IsLastCol() || !cTab.IsLastRow() {
+		v.saveTypingUndo()
+	}
+	if cTab.DeleteSelection() { // 選択範囲がある場合は選択範囲のみを削除
+		v.ScrollToCursor()
+		v.RefleshTextField()
+	} else if !cTab.IsLastCol() { // カーソル位置の文字を削除
+		cTab.IsSaved = false
+		cTab.Lines[cTab.Cursor.Row-1].Erase(int(cTab.Cursor.Col - 1))
+		cTab.changedFrom(cTab.Cursor.Row)
+		v.RefleshTargetRow(cTab.Cursor.Row)
+		v.UpdateTabBar()
+		v.UpdateStatusBar()
+	} else if !cTab.IsLastRow() { // 行末の場合は次の行と連結
+		cTab.IsSaved = false
+		tmp := cTab.Lines[cTab.Cursor.Row].GetAll()
+		cTab.DeleteLine(uint(cTab.Cursor.Row))
+		cTab.Lines[cTab.Cursor.Row-1].AppendAll(tmp)
+		cTab.changedFrom(cTab.Cursor.Row)
+		v.Reflesh()
+	}
+	return 0
+}
+
+func (v *View) actionSetMark() uint8 {
+	cTab := v.GetCurrentTab()
+	cTab.ClearSelection()
+	cTab.StartSelection()
+	v.SetMessage("Mark set")
+	v.RefleshTextField()
+	return 0
+}
+
+func (v *View) actionExchangeMark() uint8 {
+	cTab := v.GetCurrentTab()
+	if cTab.Anchor == nil {
+		v.SetMessage("No mark set in this buffer")
+		return 0
+	}
+	mark := *cTab.Anchor
+	*cTab.Anchor = Cursor{Row: cTab.Cursor.Row, Col: cTab.Cursor.Col}
+	*cTab.Cursor = Cursor{Row: mark.Row, Col: mark.Col}
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	return 0
+}
+
+func (v *View) actionKillLine() uint8 {
+	cTab := v.GetCurrentTab()
+	cTab.ClearSelection()
+	start := Cursor{Row: cTab.Cursor.Row, Col: cTab.Cursor.Col}
+	end := Cursor{Row: start.Row, Col: uint(cTab.Lines[start.Row-1].Length()) + 1}
+	if start.Col >= end.Col { // 行末の場合は改行を削除
+		if cTab.IsLastRow() {
+			v.SetMessage("End of buffer")
+			return 0
+		}
+		end = Cursor{Row: start.Row + 1, Col: 1}
+	}
+	cTab.SaveUndo()
+	v.kill(Register{REG_CHARS, strings.Split(string(cTab.TextRange(start, end)), "\n")})
+	cTab.DeleteRange(start, end)
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return 0
+}
+
+func (v *View) actionKillRegion() uint8 {
+	cTab := v.GetCurrentTab()
+	reg, ok := cTab.SelectionRegister()
+	if !ok {
+		v.SetMessage("The mark is not set now, so there is no region")
+		return 0
+	}
+	cTab.SaveUndo()
+	v.kill(reg)
+	cTab.DeleteSelection()
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return 0
+}
+
+func (v *View) actionCopyRegion() uint8 {
+	cTab := v.GetCurrentTab()
+	reg, ok := cTab.SelectionRegister()
+	if !ok {
+		v.SetMessage("The mark is not set now, so there is no region")
+		return 0
+	}
+	v.SetRegister('"', reg, false)
+	v.clearSelection()
+	return 0
+}
+
+func (v *View) actionYank() uint8 {
+	reg, ok := v.GetRegister('"')
+	if !ok {
+		v.SetMessage("Kill ring is empty")
+		return 0
+	}
+	cTab := v.GetCurrentTab()
+	cTab.SaveUndo()
+	cTab.DeleteSelection()
+	if reg.Kind == REG_BLOCK {
+		cTab.InsertBlock(cTab.Cursor.Row, int(cTab.DisplayCol())-1, reg.Lines)
+	} else {
+		end := cTab.InsertText(*cTab.Cursor, []rune(reg.Text()))
+		cTab.MoveTargetRow(end.Row)
+		cTab.MoveTargetCol(end.Col)
+	}
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return 0
+}
+
+func (v *View) actionBlockSelectUp() uint8 {
+	return v.blockSelect(-1, 0)
+}
+
+func (v *View) actionBlockSelectDown() uint8 {
+	return v.blockSelect(1, 0)
+}
+
+func (v *View) actionBlockSelectLeft() uint8 {
+	return v.blockSelect(0, -1)
+}
+
+func (v *View) actionBlockSelectRight() uint8 {
+	return v.blockSelect(0, 1)
+}
+
+func (v *View) actionAddCursorNextMatch() uint8 {
+	v.AddCaretAtNextMatch()
+	return 0
+}
+
+func (v *View) actionAddCursorsToLines() uint8 {
+	v.AddCaretsToLines()
+	return 0
+}
+
+func (v *View) actionAddCursorAbove() uint8 {
+	v.AddCaretVertical(false)
+	return 0
+}
+
+func (v *View) actionAddCursorBelow() uint8 {
+	v.AddCaretVertical(true)
+	return 0
+}
+
+// マクロの記録の開始・終了 (デフォルトのレジスタに記録)
+func (v *View) actionRecordMacro() uint8 {
+	if v.recording != 0 {
+		v.StopRecording()
+	} else {
+		v.StartRecording(MACRO_DEFAULT)
+	}
+	return 0
+}
+
+// レジスタ名を指定してマクロの記録を開始 (記録中の場合は終了)
+func (v *View) actionRecordMacroTo() uint8 {
+	if v.recording != 0 {
+		v.StopRecording()
+		return 0
+	}
+	v.OpenOverlay(NewPrompt("Record macro to register (a-z, A-Z to append): ", "", func(v *View, input string) uint8 {
+		r := []rune(input)
+		if len(r) != 1 || !isMacroName(r[0]) {
+			v.SetMessage("Error: invalid register name")
+			return 0
+		}
+		v.StartRecording(r[0])
+		return 0
+	}))
+	return 0
+}
+
+func (v *View) actionPlayMacro() uint8 {
+	return v.PlayMacro('@', 1)
+}
+
+// レジスタ名と回数を指定してマクロを再生 (*は失敗するまで繰り返す)
+func (v *View) actionPlayMacroFrom() uint8 {
+	v.OpenOverlay(NewPrompt("Play macro ([register][count or *]): ", "", func(v *View, input string) uint8 {
+		name, count, ok := parseMacroInput(input)
+		if !ok {
+			v.SetMessage("Error: invalid macro %q", input)
+			return 0
+		}
+		return v.PlayMacro(name, count)
+	}))
+	return 0
+}
+
+// 未保存のタブがあれば保存するか確認してから終了
+func (v *View) actionExit() uint8 {
+	return v.guardUnsaved(v.dirtyTabs(), func(v *View) uint8 {
+		return 1
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import "time"
+
+const AUTOSAVE_TICK = 250 * time.Millisecond // 入力がない時間の確認間隔
+
+// 自動保存の対象かどうか (無題のバッファ・まだ作成していないファイル・外部で変更されたファイルは対象外)
+func canAutosave(b *Buffer) bool {
+	return b.FilePath != "" && !b.IsNew && !b.IsSaved && !b.DiskChanged
+}
+
+// バッファの自動保存 (保存できなかった場合はメッセージを表示)
+// ファイルが外部で変更されていた場合は上書きせずに警告する
+func (v *View) autosave(b *Buffer) {
+	if d, _ := b.changedOnDisk(); d != nil {
+		v.CheckDisk(b)
+		return
+	}
+	if _, err := b.WriteFile(); err != nil {
+		v.SetMessage("Error: cannot autosave %s: %v", b.Title(), err)
+		return
+	}
+	v.UpdateTabBar()
+}
+
+// 最後の入力から設定した時間が経過した未保存のバッファを自動保存
+// 保存に失敗した場合は次の入力があるまで再試行しない
+func (v *View) AutosaveIdle() {
+	idle := time.Since(v.LastInput)
+	for _, b := range v.Buffers.All() {
+		if !canAutosave(b) || b.autosaveInput.Equal(v.LastInput) {
+			continue
+		}
+		a := v.Config.AutosaveFor(b.FilePath)
+		if !a.Enabled || a.Delay <= 0 || idle < a.Delay {
+			continue
+		}
+		b.autosaveInput = v.LastInput
+		v.autosave(b)
+	}
+}
+
+// タブの切り替え前に現在のタブを自動保存
+func (v *View) AutosaveTabSwitch() {
+	b := v.GetCurrentTab().Buffer
+	if a := v.Config.AutosaveFor(b.FilePath); canAutosave(b) && a.Enabled && a.OnTabSwitch {
+		v.autosave(b)
+	}
+}
+
+// 端末のフォーカスが外れた時に全てのタブを自動保存
+func (v *View) AutosaveFocusLost() {
+	for _, b := range v.Buffers.All() {
+		if a := v.Config.AutosaveFor(b.FilePath); canAutosave(b) && a.Enabled && a.OnFocusLost {
+			v.autosave(b)
+		}
+	}
+}
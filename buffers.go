@@ -0,0 +1,347 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+const (
+	BUFFER_LIST_WIDTH_MAX = 80 // バッファ一覧の最大幅
+	BUFFER_LIST_ITEM_MAX  = 12 // バッファ一覧の最大表示件数
+)
+
+// バッファを識別する正規化したパス (シンボリックリンクを解決した絶対パス)
+// ファイルが存在しない場合はディレクトリ部分のみ解決する
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// 開いているバッファの登録簿 (全てのバッファを所有し、タブとペインはここに登録したバッファを参照する)
+type BufferRegistry struct {
+	byPath  map[string]*Buffer // 正規化したパス -> バッファ (無題のバッファは含まない)
+	buffers []*Buffer          // 登録した順の全てのバッファ
+}
+
+func NewBufferRegistry() *BufferRegistry {
+	r := new(BufferRegistry)
+	r.byPath = make(map[string]*Buffer)
+	r.buffers = make([]*Buffer, 0)
+	return r
+}
+
+// バッファの登録 (パスは登録時に1度だけ正規化する)
+func (r *BufferRegistry) Add(b *Buffer) {
+	b.key = ""
+	if b.FilePath != "" {
+		b.key = canonicalPath(b.FilePath)
+		r.byPath[b.key] = b
+	}
+	r.buffers = append(r.buffers, b)
+}
+
+// バッファの登録の解除
+func (r *BufferRegistry) Remove(b *Buffer) {
+	if b.key != "" && r.byPath[b.key] == b {
+		delete(r.byPath, b.key)
+	}
+	for i, buf := range r.buffers {
+		if buf == b {
+			r.buffers = append(r.buffers[:i], r.buffers[i+1:]...)
+			break
+		}
+	}
+}
+
+// 指定したパスのファイルを開いているバッファの取得 (ない場合はnil)
+func (r *BufferRegistry) Find(path string) *Buffer {
+	if path == "" {
+		return nil
+	}
+	return r.byPath[canonicalPath(path)]
+}
+
+// 登録している全てのバッファ
+func (r *BufferRegistry) All() []*Buffer {
+	return r.buffers
+}
+
+// バッファのパスの変更 (新しいパスで登録し直す)
+func (r *BufferRegistry) SetFilePath(b *Buffer, filePath string) {
+	if b.key != "" && r.byPath[b.key] == b {
+		delete(r.byPath, b.key)
+	}
+	b.SetFilePath(filePath)
+	b.key = ""
+	if filePath != "" {
+		b.key = canonicalPath(filePath)
+		r.byPath[b.key] = b
+	}
+}
+
+// 指定したパスのファイルを開いているバッファの取得 (ない場合はnil)
+func (v *View) FindBuffer(path string) *Buffer {
+	return v.Buffers.Find(path)
+}
+
+// タブを追加してバッファを登録
+func (v *View) addBuffer(b *Buffer) {
+	v.Buffers.Add(b)
+	v.Tabs = append(v.Tabs, b)
+}
+
+// バッファを閉じる (表示していたペインは隣のバッファに切り替える)
+// 最後のバッファの場合は閉じずにfalseを返す
+func (v *View) DeleteBuffer(b *Buffer) bool {
+	idx := -1
+	for i, tab := range v.Tabs {
+		if tab == b {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return true
+	}
+	if len(v.Tabs) == 1 {
+		return false
+	}
+	b.removeSwap()
+	v.Buffers.Remove(b)
+	v.unwatchFile(b)
+	v.forgetJumps(b)
+	v.Tabs = append(v.Tabs[:idx], v.Tabs[idx+1:]...)
+	if idx > 0 {
+		idx--
+	}
+	for _, p := range v.Panes() {
+		p.Forget(b, v.Tabs[idx])
+	}
+	return true
+}
+
+// バッファ一覧の候補
+type bufferItem struct {
+	Buffer    *Buffer
+	Score     int
+	Positions []int
+}
+
+// バッファ一覧構造体 (開いているバッファの切り替え・削除)
+type BufferList struct {
+	Query    []rune
+	Items    []bufferItem // 絞り込み後の候補 (スコア順)
+	Selected int
+}
+
+// 新しいバッファ一覧の取得 (現在のバッファを選択)
+func NewBufferList(v *View) (l *BufferList) {
+	l = new(BufferList)
+	l.Query = make([]rune, 0)
+	l.filter(v)
+	for i, item := range l.Items {
+		if item.Buffer == v.GetCurrentTab().Buffer {
+			l.Selected = i
+		}
+	}
+	return
+}
+
+// 検索文字列でバッファ名を絞り込み
+func (l *BufferList) filter(v *View) {
+	l.Items = make([]bufferItem, 0, len(v.Buffers.All()))
+	for _, b := range v.Buffers.All() {
+		score, positions, ok := core.FuzzyMatch(l.Query, []rune(b.Title()))
+		if ok {
+			l.Items = append(l.Items, bufferItem{b, score, positions})
+		}
+	}
+	if len(l.Query) > 0 {
+		sort.SliceStable(l.Items, func(i, j int) bool { return l.Items[i].Score > l.Items[j].Score })
+	}
+	l.Selected = 0
+}
+
+// 一覧の表示領域 (左端の列・上端の行・幅)
+func (l *BufferList) bounds(v *View) (left uint, top uint, width uint) {
+	w := min(int(v.WinCol)-4, BUFFER_LIST_WIDTH_MAX)
+	w = max(w, 1) // 狭い端末でも1文字分は確保
+	width = uint(w)
+	left = uint(max(int(v.WinCol)-w, 0)/2 + 1)
+	top = 2
+	return
+}
+
+// 表示可能な候補数
+func (l *BufferList) visibleItems(v *View) int {
+	n := BUFFER_LIST_ITEM_MAX
+	if int(v.WinRow)-4 < n {
+		n = int(v.WinRow) - 4
+	}
+	if len(l.Items) < n {
+		n = len(l.Items)
+	}
+	return n
+}
+
+// 一覧に表示するパス (作業ディレクトリ以下は相対パス)
+func displayPath(b *Buffer) string {
+	if b.FilePath == "" {
+		return "[no file]"
+	}
+	abs, err := filepath.Abs(b.FilePath)
+	if err != nil {
+		return b.FilePath
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return abs
+}
+
+func (l *BufferList) Draw(v *View) {
+	if v.suspended {
+		return
+	}
+	defer v.Term.ResetStyle()
+	left, top, width := l.bounds(v)
+
+	// 入力行
+	v.Term.MoveCursorPos(left, top)
+	v.Term.SetBGColor(237)
+	input := fmt.Sprintf(" Buffer: %s", string(l.Query))
+	fmt.Print(padRight(input, int(width)))
+
+	// 候補の一覧 (変更の有無・バッファ名・言語・パス)
+	n := l.visibleItems(v)
+	offset := 0
+	if l.Selected >= n {
+		offset = l.Selected - n + 1
+	}
+	for i := 0; i < n; i++ {
+		item := l.Items[offset+i]
+		b := item.Buffer
+		v.Term.MoveCursorPos(left, top+uint(i)+1)
+		v.Term.ResetStyle()
+		bg := uint8(235)
+		if offset+i == l.Selected {
+			bg = 25
+		}
+		v.Term.SetBGColor(bg)
+
+		mark := "  "
+		if !b.IsSaved {
+			mark = " *"
+		}
+		title := []rune(b.Title())
+		fmt.Printf("%s ", mark)
+		drawMatched(v, title, item.Positions, bg)
+		lang := padRight(LanguageOf(b.FilePath), 12)
+		rest := int(width) - len(mark) - len(title) - 2
+		v.Term.SetColor(245)
+		fmt.Printf("%s ", padLeft(fmt.Sprintf("%s  %s", displayPath(b), lang), rest))
+	}
+	if n == 0 {
+		v.Term.MoveCursorPos(left, top+1)
+		v.Term.SetBGColor(235)
+		v.Term.SetColor(245)
+		fmt.Print(padRight(" No matching buffers", int(width)))
+		return
+	}
+	v.Term.MoveCursorPos(left, top+uint(n)+1)
+	v.Term.SetBGColor(237)
+	v.Term.SetColor(245)
+	fmt.Print(padRight(" Enter: switch  Ctrl+D: delete  Esc: close", int(width)))
+}
+
+func (l *BufferList) CursorPos(v *View) (col uint, row uint) {
+	left, top, _ := l.bounds(v)
+	return left + 9 + uint(len(l.Query)), top
+}
+
+func (l *BufferList) HandleKey(v *View, r rune) uint8 {
+	switch r {
+	case ESC: // Close
+		v.CloseOverlay()
+		return 0
+	case CTRL_M: // Switch
+		if len(l.Items) == 0 {
+			return 0
+		}
+		b := l.Items[l.Selected].Buffer
+		v.CloseOverlay()
+		v.AutosaveTabSwitch()
+		v.focusTab(b)
+		v.Reflesh()
+		return 0
+	case CTRL_D: // Delete
+		if len(l.Items) == 0 {
+			return 0
+		}
+		l.delete(v, l.Items[l.Selected].Buffer)
+		return 0
+	case KEY_UP, CTRL_P:
+		if l.Selected > 0 {
+			l.Selected--
+		}
+	case KEY_DOWN, CTRL_N:
+		if l.Selected < len(l.Items)-1 {
+			l.Selected++
+		}
+	case BACKSPACE:
+		if len(l.Query) == 0 {
+			return 0
+		}
+		l.Query = l.Query[:len(l.Query)-1]
+		l.filter(v)
+	default:
+		if !isInsertable(r) {
+			return 0
+		}
+		l.Query = append(l.Query, r)
+		l.filter(v)
+	}
+	v.Reflesh()
+	return 0
+}
+
+// 未保存の場合は保存するか確認してからバッファを閉じ、一覧に戻る
+// 最後のバッファを閉じた場合は無題のバッファに置き換える
+func (l *BufferList) delete(v *View, b *Buffer) {
+	dirty := make([]*Buffer, 0)
+	if !b.IsSaved {
+		dirty = append(dirty, b)
+	}
+	v.guardUnsaved(dirty, func(v *View) uint8 {
+		if len(v.Tabs) == 1 {
+			v.AddUntitledTab()
+		}
+		v.DeleteBuffer(b)
+		selected := l.Selected
+		l.filter(v)
+		if selected >= len(l.Items) {
+			selected = len(l.Items) - 1
+		}
+		if selected > 0 {
+			l.Selected = selected
+		}
+		v.Overlay = l
+		v.Reflesh()
+		return 0
+	})
+}
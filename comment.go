@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/broccolingual/Xanadu/core"
+)
+
+// 選択範囲またはカーソルの行のコメントの切り替え (言語にコメントの記法がない場合はfalse)
+func (e *Editor) ToggleComment() bool {
+	lang, _ := languageOf(e.FilePath)
+	top, bottom := e.selectedRows()
+	lines := make([][]rune, 0, bottom-top+1)
+	for row := top; row <= bottom; row++ {
+		lines = append(lines, e.LineRunes(row))
+	}
+	result, ok := core.ToggleComment(lines, lang.Syntax)
+	if !ok {
+		return false
+	}
+	saved := false
+	for i, line := range result {
+		row := top + uint(i)
+		if len(line) == len(lines[i]) {
+			continue
+		}
+		if !saved { // 変更する行がある場合のみ記録
+			e.SaveUndo()
+			saved = true
+		}
+		e.ReplaceLine(row, line)
+		col, delta := core.CommentShift(lines[i], line, lang.Syntax)
+		shiftPos(e.Cursor, row, col, delta)
+		shiftPos(e.Anchor, row, col, delta)
+	}
+	*e.Cursor = e.clampPos(*e.Cursor)
+	if e.Anchor != nil {
+		anchor := e.clampPos(*e.Anchor)
+		e.Anchor = &anchor
+	}
+	return true
+}
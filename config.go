@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 設定ファイル構造体 ($XDG_CONFIG_HOME/paprika/config.json)
+type Config struct {
+	Autosave   AutosaveConfig            `json:"autosave"`    // 自動保存の設定
+	FileTypes  map[string]FileTypeConfig `json:"filetypes"`   // ファイルの種類ごとの設定 (拡張子またはファイル名 -> 設定)
+	StatusLine *StatusLineConfig         `json:"status_line"` // ステータスバーの表示項目 (省略した場合はデフォルト)
+	Theme      Theme                     `json:"theme"`       // 表示スタイル (スタイル名 -> スタイル、省略したものはデフォルト)
+	Keymap     string                    `json:"keymap"`      // キー操作の方式 ("vim"でVim風のモード編集、"emacs"でEmacs風のキー割り当て、省略した場合はデフォルト)
+}
+
+// ステータスバーの表示項目 (セグメント名を左寄せ・中央・右寄せの順に並べる)
+type StatusLineConfig struct {
+	Left   []string `json:"left"`
+	Center []string `json:"center"`
+	Right  []string `json:"right"`
+}
+
+// ファイルの種類ごとの設定 (省略した項目は全体の設定を使用)
+type FileTypeConfig struct {
+	Autosave AutosaveConfig `json:"autosave"`
+}
+
+// 設定ファイルの自動保存の項目 (省略した項目はnil)
+type AutosaveConfig struct {
+	Enabled     *bool    `json:"enabled,omitempty"`       // 自動保存を行うかどうか
+	Delay       *float64 `json:"delay,omitempty"`         // 最後の入力から保存するまでの秒数 (0の場合は時間経過では保存しない)
+	OnTabSwitch *bool    `json:"on_tab_switch,omitempty"` // タブの切り替え時に保存するかどうか
+	OnFocusLost *bool    `json:"on_focus_lost,omitempty"` // 端末のフォーカスが外れた時に保存するかどうか
+}
+
+// 自動保存の設定値
+type Autosave struct {
+	Enabled     bool
+	Delay       time.Duration
+	OnTabSwitch bool
+	OnFocusLost bool
+}
+
+// 設定ファイルを保存するディレクトリ
+// $XDG_CONFIG_HOME/paprika (未設定の場合は ~/.config/paprika)
+func configDir() (string, error) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, "paprika"), nil
+}
+
+// 設定ファイルの読み込み (存在しない場合はデフォルトの設定)
+func LoadConfig() (*Config, error) {
+	dir, err := configDir()
+	if err != nil {
+		return new(Config), nil
+	}
+	path := filepath.Join(dir, "config.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return new(Config), nil
+	}
+	if err != nil {
+		return new(Config), err
+	}
+	c := new(Config)
+	if err := json.Unmarshal(data, c); err != nil {
+		return new(Config), fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+// ファイルの種類の設定の取得 (ファイル名・拡張子の順に検索)
+func (c *Config) fileType(filePath string) (FileTypeConfig, bool) {
+	base := filepath.Base(filePath)
+	if ft, ok := c.FileTypes[base]; ok {
+		return ft, true
+	}
+	ext := strings.TrimPrefix(filepath.Ext(base), ".")
+	if ext == "" {
+		return FileTypeConfig{}, false
+	}
+	if ft, ok := c.FileTypes[ext]; ok {
+		return ft, true
+	}
+	ft, ok := c.FileTypes["."+ext]
+	return ft, ok
+}
+
+// ファイルに適用される自動保存の設定
+// デフォルト (無効・2秒・タブ切り替え時とフォーカス喪失時も保存) に全体の設定、ファイルの種類の設定の順に上書きする
+func (c *Config) AutosaveFor(filePath string) Autosave {
+	a := Autosave{false, 2 * time.Second, true, true}
+	a.apply(c.Autosave)
+	if ft, ok := c.fileType(filePath); ok {
+		a.apply(ft.Autosave)
+	}
+	return a
+}
+
+// 設定ファイルで指定された項目の反映
+func (a *Autosave) apply(c AutosaveConfig) {
+	if c.Enabled != nil {
+		a.Enabled = *c.Enabled
+	}
+	if c.Delay != nil {
+		a.Delay = time.Duration(*c.Delay * float64(time.Second))
+	}
+	if c.OnTabSwitch != nil {
+		a.OnTabSwitch = *c.OnTabSwitch
+	}
+	if c.OnFocusLost != nil {
+		a.OnFocusLost = *c.OnFocusLost
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import "strings"
+
+// 行のコメントの切り替え (全ての行がコメントの場合はコメントを外し、それ以外は全ての行をコメントにする)
+// コメントの記号は空でない行の最小のインデントの位置に揃え、空白だけの行は変更しない
+// 行コメントがない言語ではブロックコメントで各行を囲む (どちらもない場合はok=false)
+func ToggleComment(lines [][]rune, syn Syntax) (result [][]rune, ok bool) {
+	start, end := commentMarkers(syn)
+	if start == "" {
+		return lines, false
+	}
+	indent, commented, blank := -1, true, true
+	for _, line := range lines {
+		first := FirstNonBlank(line)
+		if first == len(line) {
+			continue
+		}
+		blank = false
+		if indent < 0 || first < indent {
+			indent = first
+		}
+		text := string(line[first:])
+		if !strings.HasPrefix(text, start) || !strings.HasSuffix(strings.TrimRight(text, " "), end) {
+			commented = false
+		}
+	}
+	if blank {
+		return lines, true
+	}
+	result = make([][]rune, len(lines))
+	for i, line := range lines {
+		first := FirstNonBlank(line)
+		switch {
+		case first == len(line):
+			result[i] = line
+		case commented:
+			result[i] = uncommentLine(line, first, start, end)
+		default:
+			text := start + " " + string(line[indent:])
+			if end != "" {
+				text += " " + end
+			}
+			result[i] = append(append([]rune{}, line[:indent]...), []rune(text)...)
+		}
+	}
+	return result, true
+}
+
+// コメントの開始・終了の記号 (行コメントを優先し、ない場合はブロックコメント)
+func commentMarkers(syn Syntax) (start string, end string) {
+	if syn.LineComment != "" {
+		return syn.LineComment, ""
+	}
+	return syn.BlockComment[0], syn.BlockComment[1]
+}
+
+// ToggleCommentで変更した行の開始の記号の位置と文字数の増減 (lineは変更前、afterは変更後の行)
+// コメントにした行では空でない行の最小のインデントの位置 (変更後の行の最初の空白でない文字) に挿入している
+func CommentShift(line []rune, after []rune, syn Syntax) (col int, delta int) {
+	start, _ := commentMarkers(syn)
+	n := len([]rune(start))
+	switch {
+	case len(after) > len(line):
+		return FirstNonBlank(after), n + 1
+	case len(after) < len(line):
+		col = FirstNonBlank(line)
+		if col+n < len(line) && line[col+n] == ' ' {
+			n++
+		}
+		return col, -n
+	}
+	return 0, 0
+}
+
+// 行のコメントの記号を取り除く (記号に隣接する空白も1つ取り除く)
+func uncommentLine(line []rune, first int, start string, end string) []rune {
+	text := strings.TrimPrefix(string(line[first:]), start)
+	text = strings.TrimPrefix(text, " ")
+	if end != "" {
+		text = strings.TrimSuffix(strings.TrimRight(text, " "), end)
+		text = strings.TrimSuffix(text, " ")
+	}
+	return append(append([]rune{}, line[:first]...), []rune(text)...)
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Comment_ToggleComment(t *testing.T) {
+	goSyntax := Syntax{LineComment: "//", BlockComment: [2]string{"/*", "*/"}}
+	cssSyntax := Syntax{BlockComment: [2]string{"/*", "*/"}}
+	type args struct {
+		text string
+		syn  Syntax
+	}
+	type want struct {
+		text string
+		ok   bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{"a := 1", goSyntax}, want{"// a := 1", true}},
+		{"Test #2", args{"// a := 1", goSyntax}, want{"a := 1", true}},
+		{"Test #3", args{"  if x {\n    y()\n  }", goSyntax}, want{"  // if x {\n  //   y()\n  // }", true}},
+		{"Test #4", args{"  // if x {\n  //   y()\n  // }", goSyntax}, want{"  if x {\n    y()\n  }", true}},
+		{"Test #5", args{"// a\nb", goSyntax}, want{"// // a\n// b", true}},
+		{"Test #6", args{"    a\n\n    b", goSyntax}, want{"    // a\n\n    // b", true}},
+		{"Test #7", args{"  //a", goSyntax}, want{"  a", true}},
+		{"Test #8", args{"a { b }", cssSyntax}, want{"/* a { b } */", true}},
+		{"Test #9", args{"/* a { b } */", cssSyntax}, want{"a { b }", true}},
+		{"Test #10", args{"a", Syntax{}}, want{"a", false}},
+		{"Test #11", args{"  \n", goSyntax}, want{"  \n", true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := make([][]rune, 0)
+			for _, s := range strings.Split(tt.args.text, "\n") {
+				lines = append(lines, []rune(s))
+			}
+			result, ok := ToggleComment(lines, tt.args.syn)
+			got := make([]string, len(result))
+			for i, line := range result {
+				got[i] = string(line)
+			}
+			if strings.Join(got, "\n") != tt.want.text || ok != tt.want.ok {
+				t.Errorf("ToggleComment(%q) = %q, %v, want %q, %v", tt.args.text, strings.Join(got, "\n"), ok, tt.want.text, tt.want.ok)
+			}
+		})
+	}
+}
+
+func Test_Comment_CommentShift(t *testing.T) {
+	goSyntax := Syntax{LineComment: "//", BlockComment: [2]string{"/*", "*/"}}
+	cssSyntax := Syntax{BlockComment: [2]string{"/*", "*/"}}
+	type args struct {
+		line  string
+		after string
+		syn   Syntax
+	}
+	type want struct {
+		col   int
+		delta int
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{"a := 1", "// a := 1", goSyntax}, want{0, 3}},
+		{"Test #2", args{"    y()", "  //   y()", goSyntax}, want{2, 3}},
+		{"Test #3", args{"  //   y()", "    y()", goSyntax}, want{2, -3}},
+		{"Test #4", args{"  //a", "  a", goSyntax}, want{2, -2}},
+		{"Test #5", args{"a { b }", "/* a { b } */", cssSyntax}, want{0, 3}},
+		{"Test #6", args{"/* a { b } */", "a { b }", cssSyntax}, want{0, -3}},
+		{"Test #7", args{"", "", goSyntax}, want{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			col, delta := CommentShift([]rune(tt.args.line), []rune(tt.args.after), tt.args.syn)
+			if col != tt.want.col || delta != tt.want.delta {
+				t.Errorf("CommentShift(%q, %q) = %v, %v, want %v, %v", tt.args.line, tt.args.after, col, delta, tt.want.col, tt.want.delta)
+			}
+		})
+	}
+}
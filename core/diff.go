@@ -0,0 +1,288 @@
+package core
+
+import "fmt"
+
+type DiffKind int8 // 差分の種類
+
+const (
+	DIFF_EQUAL DiffKind = iota
+	DIFF_DELETE
+	DIFF_INSERT
+)
+
+// 3方向マージの競合マーカー
+const (
+	CONFLICT_OURS   = "<<<<<<< ours"
+	CONFLICT_SEP    = "======="
+	CONFLICT_THEIRS = ">>>>>>> theirs"
+)
+
+// 差分の1行分の操作
+type DiffOp struct {
+	Kind DiffKind
+	AIdx int    // 変更前の行インデックス (挿入の場合は挿入位置)
+	BIdx int    // 変更後の行インデックス (削除の場合は削除位置)
+	Line string // 行の内容
+}
+
+// 行単位の差分の計算 (Myersのアルゴリズム)
+func DiffLines(a []string, b []string) []DiffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	trace := make([][]int, 0)
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // 挿入
+			} else {
+				x = v[offset+k-1] + 1 // 削除
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	// 末尾から辿って操作列を復元
+	ops := make([]DiffOp, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			ops = append(ops, DiffOp{DIFF_EQUAL, x - 1, y - 1, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, DiffOp{DIFF_INSERT, x, y - 1, b[y-1]})
+		} else {
+			ops = append(ops, DiffOp{DIFF_DELETE, x - 1, y, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, DiffOp{DIFF_EQUAL, x - 1, y - 1, a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// unified形式の差分 (前後contextに指定した行数を含める)
+func UnifiedDiff(a []string, b []string, nameA string, nameB string, context int) []string {
+	ops := DiffLines(a, b)
+	out := make([]string, 0)
+
+	for start := 0; start < len(ops); {
+		// 次の変更箇所を探す
+		for start < len(ops) && ops[start].Kind == DIFF_EQUAL {
+			start++
+		}
+		if start >= len(ops) {
+			break
+		}
+		// 前後の文脈を含めたハンクの範囲を決める
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+		end := start
+		for end < len(ops) {
+			if ops[end].Kind != DIFF_EQUAL {
+				end++
+				continue
+			}
+			equal := 0
+			for end+equal < len(ops) && ops[end+equal].Kind == DIFF_EQUAL {
+				equal++
+			}
+			if end+equal >= len(ops) || equal > context*2 {
+				break
+			}
+			end += equal
+		}
+		hunkEnd := end + context
+		if hunkEnd > len(ops) {
+			hunkEnd = len(ops)
+		}
+
+		if len(out) == 0 {
+			out = append(out, "--- "+nameA, "+++ "+nameB)
+		}
+		aStart, bStart := ops[hunkStart].AIdx, ops[hunkStart].BIdx
+		aLen, bLen := 0, 0
+		lines := make([]string, 0, hunkEnd-hunkStart)
+		for _, op := range ops[hunkStart:hunkEnd] {
+			switch op.Kind {
+			case DIFF_EQUAL:
+				lines = append(lines, " "+op.Line)
+				aLen++
+				bLen++
+			case DIFF_DELETE:
+				lines = append(lines, "-"+op.Line)
+				aLen++
+			case DIFF_INSERT:
+				lines = append(lines, "+"+op.Line)
+				bLen++
+			}
+		}
+		out = append(out, fmt.Sprintf("@@ -%s +%s @@", hunkRange(aStart, aLen), hunkRange(bStart, bLen)))
+		out = append(out, lines...)
+		start = hunkEnd
+	}
+	return out
+}
+
+// ハンクヘッダの範囲表記 (開始行,行数)
+func hunkRange(start int, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// 共通祖先からの変更箇所 (base[BaseStart:BaseEnd]をLinesに置き換える)
+type diffChunk struct {
+	BaseStart int
+	BaseEnd   int
+	Lines     []string
+}
+
+// 共通祖先からの変更箇所の一覧
+func diffChunks(base []string, other []string) []diffChunk {
+	chunks := make([]diffChunk, 0)
+	var cur *diffChunk
+	for _, op := range DiffLines(base, other) {
+		if op.Kind == DIFF_EQUAL {
+			if cur != nil {
+				chunks = append(chunks, *cur)
+				cur = nil
+			}
+			continue
+		}
+		if cur == nil {
+			cur = &diffChunk{op.AIdx, op.AIdx, nil}
+		}
+		if op.Kind == DIFF_DELETE {
+			cur.BaseEnd = op.AIdx + 1
+		} else {
+			cur.Lines = append(cur.Lines, op.Line)
+		}
+	}
+	if cur != nil {
+		chunks = append(chunks, *cur)
+	}
+	return chunks
+}
+
+// 共通祖先の範囲[start, end)に変更箇所を適用した行
+func applyChunks(base []string, start int, end int, chunks []diffChunk) []string {
+	lines := make([]string, 0)
+	pos := start
+	for _, c := range chunks {
+		lines = append(lines, base[pos:c.BaseStart]...)
+		lines = append(lines, c.Lines...)
+		pos = c.BaseEnd
+	}
+	return append(lines, base[pos:end]...)
+}
+
+// 3方向マージ
+// 片方のみの変更はそのまま取り込み、両方で重なる (または隣接する) 箇所を異なる内容に変更した場合は
+// 競合マーカーで囲んで両方の内容を残す
+func Merge3(base []string, ours []string, theirs []string) (merged []string, conflicts int) {
+	a, b := diffChunks(base, ours), diffChunks(base, theirs)
+	merged = make([]string, 0, len(base))
+	pos, i, j := 0, 0, 0
+	for i < len(a) || j < len(b) {
+		// 最初の変更箇所から重なる変更箇所をまとめる
+		var start int
+		if j >= len(b) || (i < len(a) && a[i].BaseStart <= b[j].BaseStart) {
+			start = a[i].BaseStart
+		} else {
+			start = b[j].BaseStart
+		}
+		end, ai, bj := start, i, j
+		for {
+			if ai < len(a) && a[ai].BaseStart <= end {
+				if a[ai].BaseEnd > end {
+					end = a[ai].BaseEnd
+				}
+				ai++
+			} else if bj < len(b) && b[bj].BaseStart <= end {
+				if b[bj].BaseEnd > end {
+					end = b[bj].BaseEnd
+				}
+				bj++
+			} else {
+				break
+			}
+		}
+
+		merged = append(merged, base[pos:start]...)
+		oursLines := applyChunks(base, start, end, a[i:ai])
+		theirsLines := applyChunks(base, start, end, b[j:bj])
+		switch {
+		case bj == j: // こちらのみの変更
+			merged = append(merged, oursLines...)
+		case ai == i: // 相手のみの変更
+			merged = append(merged, theirsLines...)
+		case equalLines(oursLines, theirsLines): // 同じ変更
+			merged = append(merged, oursLines...)
+		default:
+			merged = append(merged, CONFLICT_OURS)
+			merged = append(merged, oursLines...)
+			merged = append(merged, CONFLICT_SEP)
+			merged = append(merged, theirsLines...)
+			merged = append(merged, CONFLICT_THEIRS)
+			conflicts++
+		}
+		pos, i, j = end, ai, bj
+	}
+	merged = append(merged, base[pos:]...)
+	return
+}
+
+// 行リストが等しいかどうか
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
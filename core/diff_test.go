@@ -0,0 +1,92 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Diff_Lines(t *testing.T) {
+	type args struct {
+		a string
+		b string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"Test #1", args{"a b c", "a b c"}, "=a =b =c"},
+		{"Test #2", args{"a b c", "a x c"}, "=a -b +x =c"},
+		{"Test #3", args{"", "a b"}, "+a +b"},
+		{"Test #4", args{"a b", ""}, "-a -b"},
+		{"Test #5", args{"a b c a b b a", "c b a b a c"}, "-a -b =c +b =a =b -b =a +c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := DiffLines(strings.Fields(tt.args.a), strings.Fields(tt.args.b))
+			got := make([]string, len(ops))
+			for i, op := range ops {
+				got[i] = map[DiffKind]string{DIFF_EQUAL: "=", DIFF_DELETE: "-", DIFF_INSERT: "+"}[op.Kind] + op.Line
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("DiffLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Diff_Unified(t *testing.T) {
+	type args struct {
+		a []string
+		b []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want []string
+	}{
+		{"Test #1", args{[]string{"a", "b"}, []string{"a", "b"}}, []string{}},
+		{"Test #2", args{[]string{"a", "b", "c"}, []string{"a", "x", "c"}}, []string{"--- A", "+++ B", "@@ -1,3 +1,3 @@", " a", "-b", "+x", " c"}},
+		{"Test #3", args{[]string{"1", "2", "3", "4", "5", "6"}, []string{"1", "2", "3", "4", "5", "6", "7"}}, []string{"--- A", "+++ B", "@@ -6 +6,2 @@", " 6", "+7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff(tt.args.a, tt.args.b, "A", "B", 1); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnifiedDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Diff_Merge3(t *testing.T) {
+	type args struct {
+		base   string
+		ours   string
+		theirs string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      string
+		conflicts int
+	}{
+		{"Test #1", args{"a b c", "a b c", "a b c"}, "a b c", 0},
+		{"Test #2", args{"a b c d e", "a B c d e", "a b c d E"}, "a B c d E", 0},
+		{"Test #3", args{"a b c", "a x c", "a x c"}, "a x c", 0},
+		{"Test #4", args{"a b c", "a b c z", "q a b c"}, "q a b c z", 0},
+		{"Test #5", args{"a b c", "a x c", "a y c"}, "a <<<<<<< x ======= y >>>>>>> c", 1},
+		{"Test #6", args{"a b c d", "a c d", "a b c"}, "a c", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := Merge3(strings.Fields(tt.args.base), strings.Fields(tt.args.ours), strings.Fields(tt.args.theirs))
+			for i, line := range got {
+				got[i] = strings.Fields(line)[0]
+			}
+			if strings.Join(got, " ") != tt.want || conflicts != tt.conflicts {
+				t.Errorf("Merge3() = %v, %d, want %v, %d", got, conflicts, tt.want, tt.conflicts)
+			}
+		})
+	}
+}
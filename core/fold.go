@@ -0,0 +1,98 @@
+package core
+
+import "sort"
+
+// 折りたたみ範囲 (行は0始まりで両端を含む、Levelは入れ子の深さで最も外側が1)
+type FoldRange struct {
+	Start int
+	End   int
+	Level int
+}
+
+// インデントによる折りたたみ範囲 (次の行からインデントが深くなる行から、深い行が続く最後の行まで)
+// 空白だけの行はどちらの範囲にも含めるが、範囲の末尾には含めない
+func IndentFolds(lineAt func(row int) []rune, lineCount int) []FoldRange {
+	type open struct{ row, indent int }
+	stack := make([]open, 0)
+	ends := make(map[int]int)
+	last := -1 // 直前の空白だけでない行
+	closeTo := func(indent int) {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if last > top.row {
+				ends[top.row] = last
+			}
+		}
+	}
+	for row := 0; row < lineCount; row++ {
+		line := lineAt(row)
+		indent := FirstNonBlank(line)
+		if indent == len(line) {
+			continue
+		}
+		closeTo(indent)
+		stack = append(stack, open{row, indent})
+		last = row
+	}
+	closeTo(0)
+	return foldRanges(ends)
+}
+
+// 括弧の対応による折りたたみ範囲 (開き括弧の行から対応する閉じ括弧の行まで、閉じ括弧で始まる行の場合は前の行まで)
+// kindAtは行の各文字の字句の種類を返す関数で、文字列・コメント内の括弧は対応させない (nilの場合は区別しない)
+func BracketFolds(lineAt func(row int) []rune, kindAt func(row int) []TokenKind, lineCount int) []FoldRange {
+	type open struct {
+		row int
+		r   rune
+	}
+	stack := make([]open, 0)
+	ends := make(map[int]int)
+	for row := 0; row < lineCount; row++ {
+		line := lineAt(row)
+		var kinds []TokenKind
+		if kindAt != nil {
+			kinds = kindAt(row)
+		}
+		for col, r := range line {
+			if col < len(kinds) && kinds[col] != TOKEN_CODE {
+				continue
+			}
+			if IsClosingBracket(r) {
+				if len(stack) == 0 || bracketPairs[stack[len(stack)-1].r] != r {
+					continue
+				}
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				end := row
+				if first := FirstNonBlank(line); IsClosingBracket(line[first]) { // 閉じ括弧で始まる行は折りたたまずに残す
+					end--
+				}
+				if end > top.row && end > ends[top.row] {
+					ends[top.row] = end
+				}
+			} else if bracketPairs[r] != 0 {
+				stack = append(stack, open{row, r})
+			}
+		}
+	}
+	return foldRanges(ends)
+}
+
+// 開始行 -> 終了行の対応から入れ子の深さを付けた範囲の一覧 (開始行の順)
+func foldRanges(ends map[int]int) []FoldRange {
+	folds := make([]FoldRange, 0, len(ends))
+	for start, end := range ends {
+		folds = append(folds, FoldRange{Start: start, End: end})
+	}
+	sort.Slice(folds, func(i, j int) bool { return folds[i].Start < folds[j].Start })
+	stack := make([]int, 0) // 囲んでいる範囲の終了行
+	for i := range folds {
+		for len(stack) > 0 && stack[len(stack)-1] < folds[i].Start {
+			stack = stack[:len(stack)-1]
+		}
+		folds[i].Level = len(stack) + 1
+		stack = append(stack, folds[i].End)
+	}
+	return folds
+}
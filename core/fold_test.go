@@ -0,0 +1,65 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_Fold_IndentFolds(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []FoldRange
+	}{
+		{"Test #1", "a\nb", []FoldRange{}},
+		{"Test #2", "def f():\n    x\n    y\nz", []FoldRange{{0, 2, 1}}},
+		{"Test #3", "a:\n  b:\n    c\n  d\ne", []FoldRange{{0, 3, 1}, {1, 2, 2}}},
+		{"Test #4", "a:\n  b\n\n  c\n\nd", []FoldRange{{0, 3, 1}}},
+		{"Test #5", "a:\n  b\n  c", []FoldRange{{0, 2, 1}}},
+		{"Test #6", "  a\nb:\n  c", []FoldRange{{1, 2, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(tt.text, "\n")
+			lineAt := func(row int) []rune { return []rune(lines[row]) }
+			if got := IndentFolds(lineAt, len(lines)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IndentFolds(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Fold_BracketFolds(t *testing.T) {
+	syn := Syntax{LineComment: "//", Quotes: "\""}
+	tests := []struct {
+		name string
+		text string
+		want []FoldRange
+	}{
+		{"Test #1", "f()\ng()", []FoldRange{}},
+		{"Test #2", "func f() {\n\tx\n}", []FoldRange{{0, 1, 1}}},
+		{"Test #3", "if a {\n\tx\n} else {\n\ty\n}", []FoldRange{{0, 1, 1}, {2, 3, 1}}},
+		{"Test #4", "func f() {\n\tif a {\n\t\tx\n\t}\n}", []FoldRange{{0, 3, 1}, {1, 2, 2}}},
+		{"Test #5", "x := f(a,\n\tb)\ny", []FoldRange{{0, 1, 1}}},
+		{"Test #6", "s := \"{\"\n// {\nt", []FoldRange{}},
+		{"Test #7", "g(func() {\n\tx\n})", []FoldRange{{0, 1, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(tt.text, "\n")
+			lineAt := func(row int) []rune { return []rune(lines[row]) }
+			kindAt := func(row int) []TokenKind {
+				var state LexState
+				var kinds []TokenKind
+				for r := 0; r <= row; r++ {
+					kinds, state = Lex(lineAt(r), syn, state)
+				}
+				return kinds
+			}
+			if got := BracketFolds(lineAt, kindAt, len(lines)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BracketFolds(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import "unicode"
+
+const (
+	fuzzyScoreMatch       = 16 // 1文字一致ごとの基本スコア
+	fuzzyBonusHead        = 8  // 先頭文字の一致
+	fuzzyBonusBoundary    = 12 // 単語境界での一致
+	fuzzyBonusConsecutive = 8  // 連続した一致
+	fuzzyPenaltyGap       = 1  // 一致間の1文字ごとの減点
+)
+
+// あいまい一致の判定
+// patternの全ての文字がtextに順番通り出現する場合にok=trueを返す
+// スコアが高いほど一致度が高く、positionsには一致したtextのインデックスが入る
+// 大文字・小文字は区別しない
+func FuzzyMatch(pattern []rune, text []rune) (score int, positions []int, ok bool) {
+	if len(pattern) == 0 {
+		return 0, nil, true
+	}
+	if len(pattern) > len(text) {
+		return 0, nil, false
+	}
+
+	// dp[i][j]: pattern[i]をtext[j]に一致させた時の最大スコア (一致不可は負の無限大)
+	// prev[i][j]: その時のpattern[i-1]の一致位置
+	const none = -1 << 30
+	dp := make([][]int, len(pattern))
+	prev := make([][]int, len(pattern))
+	for i := range pattern {
+		dp[i] = make([]int, len(text))
+		prev[i] = make([]int, len(text))
+		p := foldRune(pattern[i])
+
+		bestGap, bestGapIdx := none, -1 // max(dp[i-1][k] + penalty*k) (k < j-1)
+		for j := range text {
+			dp[i][j] = none
+			if i > 0 && j >= 2 && dp[i-1][j-2] != none {
+				if cand := dp[i-1][j-2] + fuzzyPenaltyGap*(j-2); cand > bestGap {
+					bestGap, bestGapIdx = cand, j-2
+				}
+			}
+			if foldRune(text[j]) != p {
+				continue
+			}
+			bonus := fuzzyScoreMatch
+			if j == 0 {
+				bonus += fuzzyBonusHead
+			}
+			if isWordBoundary(text, j) {
+				bonus += fuzzyBonusBoundary
+			}
+			if i == 0 {
+				dp[i][j] = bonus
+				continue
+			}
+			if j >= 1 && dp[i-1][j-1] != none {
+				dp[i][j] = dp[i-1][j-1] + fuzzyBonusConsecutive + bonus
+				prev[i][j] = j - 1
+			}
+			if bestGapIdx >= 0 {
+				if cand := bestGap - fuzzyPenaltyGap*(j-1) + bonus; cand > dp[i][j] {
+					dp[i][j] = cand
+					prev[i][j] = bestGapIdx
+				}
+			}
+		}
+	}
+
+	last := len(pattern) - 1
+	end := -1
+	for j := range text {
+		if dp[last][j] != none && (end < 0 || dp[last][j] > dp[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions = make([]int, len(pattern))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = prev[i][j]
+	}
+	return dp[last][end], positions, true
+}
+
+// patternの全ての文字がtextに順番通り出現するかどうか (スコアを求めずに判定する、大文字・小文字は区別しない)
+func FuzzyContains(pattern []rune, text string) bool {
+	i := 0
+	for _, ch := range text {
+		if i == len(pattern) {
+			break
+		}
+		if foldRune(ch) == foldRune(pattern[i]) {
+			i++
+		}
+	}
+	return i == len(pattern)
+}
+
+// 比較用に大文字・小文字を揃える
+func foldRune(ch rune) rune {
+	return unicode.ToLower(ch)
+}
+
+// 単語の先頭かどうかの判定 (区切り文字の直後・キャメルケースの大文字)
+func isWordBoundary(text []rune, pos int) bool {
+	if pos == 0 {
+		return true
+	}
+	prev, cur := text[pos-1], text[pos]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return unicode.IsLetter(cur) || unicode.IsDigit(cur)
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
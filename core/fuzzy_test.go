@@ -0,0 +1,88 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Fuzzy_Match(t *testing.T) {
+	type args struct {
+		pattern string
+		text    string
+	}
+	tests := []struct {
+		name          string
+		args          args
+		wantPositions []int
+		wantOk        bool
+	}{
+		{"Test #1", args{"", "Next Tab"}, nil, true},
+		{"Test #2", args{"nt", "Next Tab"}, []int{0, 5}, true},
+		{"Test #3", args{"SAVE", "save"}, []int{0, 1, 2, 3}, true},
+		{"Test #4", args{"tn", "Next Tab"}, nil, false},
+		{"Test #5", args{"abc", "a_xbc_abc"}, []int{6, 7, 8}, true},
+		{"Test #6", args{"あう", "あいう"}, []int{0, 2}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, gotPositions, gotOk := FuzzyMatch([]rune(tt.args.pattern), []rune(tt.args.text))
+			if gotOk != tt.wantOk {
+				t.Errorf("FuzzyMatch() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+			if gotOk && !reflect.DeepEqual(gotPositions, tt.wantPositions) {
+				t.Errorf("FuzzyMatch() positions = %v, want %v", gotPositions, tt.wantPositions)
+			}
+		})
+	}
+}
+
+func Test_Fuzzy_Score(t *testing.T) {
+	type args struct {
+		pattern string
+		better  string
+		worse   string
+	}
+	tests := []struct {
+		name string
+		args args
+	}{
+		{"Test #1", args{"nt", "Next Tab", "Comment"}},
+		{"Test #2", args{"save", "Save", "Select All Vertical Edit"}},
+		{"Test #3", args{"mb", "Move Bottom", "Number"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			better, _, _ := FuzzyMatch([]rune(tt.args.pattern), []rune(tt.args.better))
+			worse, _, _ := FuzzyMatch([]rune(tt.args.pattern), []rune(tt.args.worse))
+			if better <= worse {
+				t.Errorf("FuzzyMatch() score %q = %v, %q = %v", tt.args.better, better, tt.args.worse, worse)
+			}
+		})
+	}
+}
+
+func Test_Fuzzy_Contains(t *testing.T) {
+	type args struct {
+		pattern string
+		text    string
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"Test #1", args{"", "main.go"}, true},
+		{"Test #2", args{"mg", "main.go"}, true},
+		{"Test #3", args{"MAIN", "main.go"}, true},
+		{"Test #4", args{"gm", "main.go"}, false},
+		{"Test #5", args{"main.go!", "main.go"}, false},
+		{"Test #6", args{"あう", "あいう"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FuzzyContains([]rune(tt.args.pattern), tt.args.text); got != tt.want {
+				t.Errorf("FuzzyContains(%q, %q) = %v, want %v", tt.args.pattern, tt.args.text, got, tt.want)
+			}
+		})
+	}
+}
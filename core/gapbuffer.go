@@ -13,12 +13,19 @@ type _GapBuffer struct {
 }
 
 // 新しいギャップバッファの取得
+// データがバッファサイズを超える場合はバッファサイズを拡張
 func NewGapBuffer(data []rune, bufSize int) *GapBuffer {
+	for bufSize <= len(data) {
+		bufSize *= 2
+		if bufSize == 0 {
+			bufSize = 1
+		}
+	}
 	gBuf := new(GapBuffer)
 	gBuf.size = bufSize
 	gBuf.gapIdx = len(data)
 	gBuf.gapSize = bufSize - gBuf.gapIdx
-	gBuf.buf = data
+	gBuf.buf = append(make([]rune, 0, bufSize), data...)
 	gBuf.initGap()
 	return gBuf
 }
@@ -28,6 +35,21 @@ func (gBuf *GapBuffer) initGap() {
 	gBuf.buf = append(gBuf.buf, make([]rune, gBuf.gapIdx-len(gBuf.buf)+gBuf.gapSize)...)
 }
 
+// ギャップが無くなった場合にバッファサイズを2倍に拡張
+func (gBuf *GapBuffer) grow() {
+	newSize := gBuf.size * 2
+	if newSize == 0 {
+		newSize = 1
+	}
+	buf := make([]rune, newSize)
+	copy(buf, gBuf.buf[:gBuf.gapIdx])
+	tail := gBuf.buf[gBuf.gapIdx+gBuf.gapSize:]
+	copy(buf[newSize-len(tail):], tail)
+	gBuf.gapSize += newSize - gBuf.size
+	gBuf.size = newSize
+	gBuf.buf = buf
+}
+
 // 指定したインデックスにギャップを移動
 func (gBuf *GapBuffer) moveGap(idx int) {
 	if idx < 0 || idx > gBuf.size {
@@ -102,9 +124,12 @@ func (gBuf GapBuffer) GetAll() (out []rune) {
 
 // バッファにruneを挿入
 func (gBuf *GapBuffer) Insert(idx int, ch rune) bool {
-	if idx < 0 || idx > gBuf.size {
+	if idx < 0 || idx > gBuf.Length() {
 		return false
 	}
+	if gBuf.gapSize == 0 {
+		gBuf.grow()
+	}
 	gBuf.moveGap(idx)
 	gBuf.buf[gBuf.gapIdx] = ch
 	gBuf.gapIdx++
@@ -121,7 +146,7 @@ func (gBuf *GapBuffer) InsertAll(idx int, data []rune) {
 
 // バッファのruneを削除
 func (gBuf *GapBuffer) Erase(idx int) bool {
-	if idx < 0 || idx > gBuf.size {
+	if idx < 0 || idx >= gBuf.Length() {
 		return false
 	}
 	gBuf.moveGap(idx)
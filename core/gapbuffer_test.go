@@ -204,3 +204,33 @@ func Test_GB_AppendAll(t *testing.T) {
 		})
 	}
 }
+
+func Test_GB_Grow(t *testing.T) {
+	type fields struct {
+		data []rune
+		bufSize int
+	}
+	type args struct {
+		idx int
+		data []rune
+	}
+	tests := []struct {
+		name string
+		fields fields
+		args args
+		want string
+	}{
+		{"Test #1", fields{[]rune("ABCD"), 4}, args{2, []rune("xyz")}, "ABxyzCD"},
+		{"Test #2", fields{[]rune("Hello"), 2}, args{5, []rune(" World !")}, "Hello World !"},
+		{"Test #3", fields{[]rune(""), 1}, args{0, []rune("あいうえお")}, "あいうえお"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gBuf := NewGapBuffer(tt.fields.data, tt.fields.bufSize)
+			gBuf.InsertAll(tt.args.idx, tt.args.data)
+			if got := string(gBuf.GetAll()); got != tt.want {
+				t.Errorf("InsertAll() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+// 閉じ括弧かどうか
+func IsClosingBracket(r rune) bool {
+	return r == ')' || r == ']' || r == '}'
+}
+
+// 改行した次の行のインデントの幅 (beforeは改行する位置より前の内容)
+// 末尾の空白を除いた最後の文字がopenersに含まれる場合はunitだけ深くする
+func NextIndent(before []rune, openers string, unit int) (width int, opened bool) {
+	width = FirstNonBlank(before)
+	for i := len(before) - 1; i >= width; i-- {
+		if ClassOf(before[i]) == CHAR_SPACE {
+			continue
+		}
+		for _, o := range openers {
+			if before[i] == o {
+				return width + unit, true
+			}
+		}
+		break
+	}
+	return width, false
+}
+
+// インデントの幅をunit単位で段数分増減した幅 (unitの倍数に揃え、0未満にはしない)
+func ShiftIndent(width int, unit int, levels int) int {
+	if unit <= 0 {
+		return width
+	}
+	if levels > 0 {
+		return (width/unit + levels) * unit
+	}
+	if levels < 0 {
+		return max((width+unit-1)/unit+levels, 0) * unit
+	}
+	return width
+}
@@ -0,0 +1,67 @@
+package core
+
+import "testing"
+
+func Test_Indent_NextIndent(t *testing.T) {
+	type args struct {
+		before  string
+		openers string
+		unit    int
+	}
+	type want struct {
+		width  int
+		opened bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{"", "{", 4}, want{0, false}},
+		{"Test #2", args{"    foo()", "{", 4}, want{4, false}},
+		{"Test #3", args{"    if x {", "{([", 4}, want{8, true}},
+		{"Test #4", args{"if x {  ", "{", 4}, want{4, true}},
+		{"Test #5", args{"def f():", ":", 4}, want{4, true}},
+		{"Test #6", args{"  ", "{", 4}, want{2, false}},
+		{"Test #7", args{"{", "", 4}, want{0, false}},
+		{"Test #8", args{"\tcall(", "(", 2}, want{3, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			width, opened := NextIndent([]rune(tt.args.before), tt.args.openers, tt.args.unit)
+			if width != tt.want.width || opened != tt.want.opened {
+				t.Errorf("NextIndent(%q, %q, %d) = %d, %v, want %d, %v", tt.args.before, tt.args.openers, tt.args.unit, width, opened, tt.want.width, tt.want.opened)
+			}
+		})
+	}
+}
+
+func Test_Indent_ShiftIndent(t *testing.T) {
+	type args struct {
+		width  int
+		unit   int
+		levels int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"Test #1", args{0, 4, 1}, 4},
+		{"Test #2", args{4, 4, 1}, 8},
+		{"Test #3", args{3, 4, 1}, 4},
+		{"Test #4", args{8, 4, -1}, 4},
+		{"Test #5", args{6, 4, -1}, 4},
+		{"Test #6", args{2, 4, -1}, 0},
+		{"Test #7", args{0, 4, -1}, 0},
+		{"Test #8", args{4, 4, 2}, 12},
+		{"Test #9", args{5, 0, 1}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ShiftIndent(tt.args.width, tt.args.unit, tt.args.levels); got != tt.want {
+				t.Errorf("ShiftIndent(%d, %d, %d) = %d, want %d", tt.args.width, tt.args.unit, tt.args.levels, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import "strings"
+
+// 字句の種類 (文字列・コメント内の括弧を区別するため)
+type TokenKind int8
+
+const (
+	TOKEN_CODE    TokenKind = iota // コード
+	TOKEN_STRING                   // 文字列 (引用符を含む)
+	TOKEN_COMMENT                  // コメント
+)
+
+// 言語の文字列・コメントの記法
+type Syntax struct {
+	LineComment  string    // 行コメントの開始 (空の場合はなし)
+	BlockComment [2]string // ブロックコメントの開始・終了 (空の場合はなし)
+	Quotes       string    // 行内の文字列を囲む引用符 (\でエスケープする)
+	RawQuotes    string    // 複数行にまたがる文字列を囲む引用符 (エスケープしない)
+}
+
+// 行の終わりでの字句解析の状態 (次の行に引き継ぐ)
+type LexState struct {
+	Comment bool // ブロックコメントの途中
+	Quote   rune // 複数行にまたがる文字列の途中の場合はその引用符
+}
+
+// 行の各文字の字句の種類 (stateは前の行の終わりの状態、この行の終わりの状態を返す)
+func Lex(line []rune, syn Syntax, state LexState) ([]TokenKind, LexState) {
+	kinds := make([]TokenKind, len(line))
+	comment, quote, raw := state.Comment, state.Quote, state.Quote != 0
+	fill := func(from int, n int, kind TokenKind) {
+		for i := from; i < min(from+n, len(line)); i++ {
+			kinds[i] = kind
+		}
+	}
+	for i := 0; i < len(line); i++ {
+		switch {
+		case comment:
+			kinds[i] = TOKEN_COMMENT
+			if end := []rune(syn.BlockComment[1]); hasPrefixAt(line, i, end) {
+				fill(i, len(end), TOKEN_COMMENT)
+				i += len(end) - 1
+				comment = false
+			}
+		case quote != 0:
+			kinds[i] = TOKEN_STRING
+			if !raw && line[i] == '\\' && i+1 < len(line) {
+				kinds[i+1] = TOKEN_STRING
+				i++
+			} else if line[i] == quote {
+				quote = 0
+			}
+		case syn.LineComment != "" && hasPrefixAt(line, i, []rune(syn.LineComment)):
+			fill(i, len(line)-i, TOKEN_COMMENT)
+			i = len(line)
+		case syn.BlockComment[0] != "" && hasPrefixAt(line, i, []rune(syn.BlockComment[0])):
+			start := []rune(syn.BlockComment[0])
+			fill(i, len(start), TOKEN_COMMENT)
+			i += len(start) - 1
+			comment = true
+		case strings.ContainsRune(syn.Quotes, line[i]):
+			kinds[i] = TOKEN_STRING
+			quote, raw = line[i], false
+		case strings.ContainsRune(syn.RawQuotes, line[i]):
+			kinds[i] = TOKEN_STRING
+			quote, raw = line[i], true
+		}
+	}
+	state = LexState{Comment: comment}
+	if raw {
+		state.Quote = quote
+	}
+	return kinds, state
+}
+
+// 指定位置から始まる文字列かどうか
+func hasPrefixAt(line []rune, i int, prefix []rune) bool {
+	return len(prefix) > 0 && i+len(prefix) <= len(line) && equalRunes(line[i:i+len(prefix)], prefix)
+}
@@ -0,0 +1,49 @@
+package core
+
+import "testing"
+
+func Test_Lexer_Lex(t *testing.T) {
+	goSyntax := Syntax{"//", [2]string{"/*", "*/"}, "\"'", "`"}
+	shSyntax := Syntax{LineComment: "#", Quotes: "\"'"}
+	// 字句の種類を1文字で表す (c: コード、s: 文字列、#: コメント)
+	marks := map[TokenKind]byte{TOKEN_CODE: 'c', TOKEN_STRING: 's', TOKEN_COMMENT: '#'}
+	type args struct {
+		line  string
+		syn   Syntax
+		state LexState
+	}
+	type want struct {
+		kinds string
+		state LexState
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{`f("(")`, goSyntax, LexState{}}, want{"ccsssc", LexState{}}},
+		{"Test #2", args{`a // (`, goSyntax, LexState{}}, want{"cc####", LexState{}}},
+		{"Test #3", args{`a /* ( */ b`, goSyntax, LexState{}}, want{"cc#######cc", LexState{}}},
+		{"Test #4", args{`a /* (`, goSyntax, LexState{}}, want{"cc####", LexState{Comment: true}}},
+		{"Test #5", args{`) */ (`, goSyntax, LexState{Comment: true}}, want{"####cc", LexState{}}},
+		{"Test #6", args{`"\")" )`, goSyntax, LexState{}}, want{"ssssscc", LexState{}}},
+		{"Test #7", args{"x := `(", goSyntax, LexState{}}, want{"cccccss", LexState{Quote: '`'}}},
+		{"Test #8", args{"\\`) `", goSyntax, LexState{Quote: '`'}}, want{"ssccs", LexState{Quote: '`'}}},
+		{"Test #9", args{`"abc`, goSyntax, LexState{}}, want{"ssss", LexState{}}},
+		{"Test #10", args{`echo '#' # x`, shSyntax, LexState{}}, want{"cccccsssc###", LexState{}}},
+		{"Test #11", args{`"/*"`, goSyntax, LexState{}}, want{"ssss", LexState{}}},
+		{"Test #12", args{`(a)`, Syntax{}, LexState{}}, want{"ccc", LexState{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kinds, state := Lex([]rune(tt.args.line), tt.args.syn, tt.args.state)
+			got := make([]byte, len(kinds))
+			for i, k := range kinds {
+				got[i] = marks[k]
+			}
+			if string(got) != tt.want.kinds || state != tt.want.state {
+				t.Errorf("Lex(%q) = %s, %+v, want %s, %+v", tt.args.line, got, state, tt.want.kinds, tt.want.state)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package core
+
+// 文字列の検索 (row行目のcol文字目以降で最初に一致する位置、0始まり)
+// 末尾まで見つからない場合は先頭から検索し直す。needleは行ごとに分割した検索文字列で、2行以上の場合は行をまたいで一致する
+func FindNext(lineAt func(int) []rune, lineCount int, needle [][]rune, row int, col int) (int, int, bool) {
+	if len(needle) == 0 || (len(needle) == 1 && len(needle[0]) == 0) {
+		return 0, 0, false
+	}
+	for k := 0; k <= lineCount; k++ {
+		r := (row + k) % lineCount
+		line := lineAt(r)
+		from, to := 0, len(line)
+		if k == 0 {
+			from = col
+		}
+		if k == lineCount { // 1周して開始位置の行の前半
+			to = min(col-1, len(line))
+		}
+		for c := from; c <= to; c++ {
+			if matchAt(lineAt, lineCount, needle, r, c) {
+				return r, c, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// 指定位置から検索文字列が一致するかどうか
+func matchAt(lineAt func(int) []rune, lineCount int, needle [][]rune, row int, col int) bool {
+	if row+len(needle) > lineCount {
+		return false
+	}
+	line := lineAt(row)
+	first := needle[0]
+	if len(needle) == 1 {
+		return col+len(first) <= len(line) && equalRunes(line[col:col+len(first)], first)
+	}
+	if col+len(first) != len(line) || !equalRunes(line[col:], first) { // 最初の行は行末まで
+		return false
+	}
+	for i := 1; i < len(needle)-1; i++ {
+		if !equalRunes(lineAt(row+i), needle[i]) {
+			return false
+		}
+	}
+	last, tail := lineAt(row+len(needle)-1), needle[len(needle)-1] // 最後の行は行頭から
+	return len(tail) <= len(last) && equalRunes(last[:len(tail)], tail)
+}
+
+func equalRunes(a []rune, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
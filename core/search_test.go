@@ -0,0 +1,47 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Search_FindNext(t *testing.T) {
+	type args struct {
+		text   string
+		needle string
+		row    int
+		col    int
+	}
+	tests := []struct {
+		name string
+		args args
+		row  int
+		col  int
+		ok   bool
+	}{
+		{"Test #1", args{"foo bar foo", "foo", 0, 0}, 0, 0, true},
+		{"Test #2", args{"foo bar foo", "foo", 0, 1}, 0, 8, true},
+		{"Test #3", args{"foo bar\nbaz foo", "foo", 0, 1}, 1, 4, true},
+		{"Test #4", args{"foo bar\nbaz", "foo", 0, 1}, 0, 0, true},
+		{"Test #5", args{"foo bar\nbaz", "qux", 0, 0}, 0, 0, false},
+		{"Test #6", args{"a foo\nbar b\nfoo\nbar", "foo\nbar", 0, 3}, 2, 0, true},
+		{"Test #7", args{"a foo\nbar b\nfoo\nbar", "foo\nbar", 1, 0}, 2, 0, true},
+		{"Test #8", args{"x\nfoo\nbar", "foo\nbar", 2, 0}, 1, 0, true},
+		{"Test #9", args{"foo", "", 0, 0}, 0, 0, false},
+		{"Test #10", args{"日本語の日本", "日本", 0, 1}, 0, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := strings.Split(tt.args.text, "\n")
+			lineAt := func(r int) []rune { return []rune(lines[r]) }
+			var needle [][]rune
+			for _, s := range strings.Split(tt.args.needle, "\n") {
+				needle = append(needle, []rune(s))
+			}
+			row, col, ok := FindNext(lineAt, len(lines), needle, tt.args.row, tt.args.col)
+			if row != tt.row || col != tt.col || ok != tt.ok {
+				t.Errorf("FindNext(%q, %q, %d, %d) = (%d, %d, %v), want (%d, %d, %v)", tt.args.text, tt.args.needle, tt.args.row, tt.args.col, row, col, ok, tt.row, tt.col, tt.ok)
+			}
+		})
+	}
+}
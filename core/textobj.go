@@ -0,0 +1,126 @@
+package core
+
+// 次の単語の末尾のインデックス (idxより後、行内にない場合は行の長さ)
+func WordEnd(line []rune, idx int, classOf ClassFunc) int {
+	idx++
+	for idx < len(line) && classOf(line[idx]) == CHAR_SPACE {
+		idx++
+	}
+	if idx >= len(line) {
+		return len(line)
+	}
+	class := classOf(line[idx])
+	for idx+1 < len(line) && classOf(line[idx+1]) == class {
+		idx++
+	}
+	return idx
+}
+
+// 単語のテキストオブジェクトの範囲 [start, end)
+// 指定位置の単語 (空白の場合は空白の連続) を選択し、aroundの場合は続く空白 (ない場合は前の空白) も含める
+func WordObject(line []rune, idx int, classOf ClassFunc, around bool) (start int, end int) {
+	if len(line) == 0 {
+		return 0, 0
+	}
+	if idx >= len(line) {
+		idx = len(line) - 1
+	}
+	class := classOf(line[idx])
+	start, end = idx, idx+1
+	for start > 0 && classOf(line[start-1]) == class {
+		start--
+	}
+	for end < len(line) && classOf(line[end]) == class {
+		end++
+	}
+	if !around {
+		return start, end
+	}
+	if class == CHAR_SPACE { // 空白の場合は続く単語を含める
+		if end < len(line) {
+			next := classOf(line[end])
+			for end < len(line) && classOf(line[end]) == next {
+				end++
+			}
+		}
+		return start, end
+	}
+	if end < len(line) && classOf(line[end]) == CHAR_SPACE {
+		for end < len(line) && classOf(line[end]) == CHAR_SPACE {
+			end++
+		}
+		return start, end
+	}
+	for start > 0 && classOf(line[start-1]) == CHAR_SPACE {
+		start--
+	}
+	return start, end
+}
+
+// 引用符のテキストオブジェクトの範囲 [start, end)
+// 指定位置を囲む引用符の組 (ない場合は後ろにある最初の組) を選択し、aroundの場合は引用符も含める
+// \でエスケープされた引用符は無視する
+func QuoteObject(line []rune, idx int, quote rune, around bool) (start int, end int, ok bool) {
+	quotes := make([]int, 0)
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case quote:
+			quotes = append(quotes, i)
+		}
+	}
+	for i := 0; i+1 < len(quotes); i += 2 {
+		open, close := quotes[i], quotes[i+1]
+		if idx > close {
+			continue
+		}
+		if around {
+			return open, close + 1, true
+		}
+		return open + 1, close, true
+	}
+	return 0, 0, false
+}
+
+// 括弧のテキストオブジェクトの範囲 (行・列は0始まり、終了位置は含まない)
+// 指定位置を囲む最も内側のopen・closeの組を選択し、aroundの場合は括弧も含める (open・closeは()・[]・{}のいずれか)
+func PairObject(lineAt func(row int) []rune, lineCount int, row int, col int, open rune, close rune, around bool) (startRow, startCol, endRow, endCol int, ok bool) {
+	// 指定位置から前に向かって対応の取れていない開き括弧を探す
+	depth := 0
+	r, c := row, col
+	line := lineAt(r)
+	if c < len(line) && line[c] == close {
+		c-- // 閉じ括弧の上の場合はその組を選択
+	}
+	for {
+		if c < 0 {
+			r--
+			if r < 0 {
+				return 0, 0, 0, 0, false
+			}
+			line = lineAt(r)
+			c = len(line) - 1
+			continue
+		}
+		if c < len(line) {
+			if line[c] == close {
+				depth--
+			} else if line[c] == open {
+				if depth == 0 {
+					break
+				}
+				depth++
+			}
+		}
+		c--
+	}
+	er, ec, found := MatchBracket(lineAt, lineCount, r, c)
+	if !found {
+		return 0, 0, 0, 0, false
+	}
+	if around {
+		return r, c, er, ec + 1, true
+	}
+	return r, c + 1, er, ec, true
+}
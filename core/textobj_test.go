@@ -0,0 +1,143 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_TextObj_WordEnd(t *testing.T) {
+	type args struct {
+		line string
+		idx  int
+		big  bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"Test #1", args{"foo bar", 0, false}, 2},
+		{"Test #2", args{"foo bar", 2, false}, 6},
+		{"Test #3", args{"foo.bar baz", 0, false}, 2},
+		{"Test #4", args{"foo.bar baz", 0, true}, 6},
+		{"Test #5", args{"foo   ", 2, false}, 6},
+		{"Test #6", args{"日本語のテキスト", 0, false}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classOf := ClassOf
+			if tt.args.big {
+				classOf = BigClassOf
+			}
+			if got := WordEnd([]rune(tt.args.line), tt.args.idx, classOf); got != tt.want {
+				t.Errorf("WordEnd(%q, %d) = %d, want %d", tt.args.line, tt.args.idx, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TextObj_WordObject(t *testing.T) {
+	type args struct {
+		line   string
+		idx    int
+		around bool
+	}
+	tests := []struct {
+		name  string
+		args  args
+		start int
+		end   int
+	}{
+		{"Test #1", args{"foo bar baz", 5, false}, 4, 7},
+		{"Test #2", args{"foo bar baz", 5, true}, 4, 8},
+		{"Test #3", args{"foo bar", 5, true}, 3, 7},
+		{"Test #4", args{"foo   bar", 4, false}, 3, 6},
+		{"Test #5", args{"foo   bar", 4, true}, 3, 9},
+		{"Test #6", args{"日本語のテキスト", 5, false}, 4, 8},
+		{"Test #7", args{"", 0, false}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := WordObject([]rune(tt.args.line), tt.args.idx, ClassOf, tt.args.around)
+			if start != tt.start || end != tt.end {
+				t.Errorf("WordObject(%q, %d, %v) = %d, %d, want %d, %d", tt.args.line, tt.args.idx, tt.args.around, start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func Test_TextObj_QuoteObject(t *testing.T) {
+	type args struct {
+		line   string
+		idx    int
+		around bool
+	}
+	type want struct {
+		start int
+		end   int
+		ok    bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{`a := "hello"`, 7, false}, want{6, 11, true}},
+		{"Test #2", args{`a := "hello"`, 7, true}, want{5, 12, true}},
+		{"Test #3", args{`a := "hello"`, 0, false}, want{6, 11, true}},
+		{"Test #4", args{`"a\"b" x`, 1, false}, want{1, 5, true}},
+		{"Test #5", args{`"a" "b"`, 5, false}, want{5, 6, true}},
+		{"Test #6", args{`no quotes`, 1, false}, want{0, 0, false}},
+		{"Test #7", args{`"a" x`, 4, false}, want{0, 0, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := QuoteObject([]rune(tt.args.line), tt.args.idx, '"', tt.args.around)
+			if start != tt.want.start || end != tt.want.end || ok != tt.want.ok {
+				t.Errorf("QuoteObject(%q, %d, %v) = %d, %d, %v, want %v", tt.args.line, tt.args.idx, tt.args.around, start, end, ok, tt.want)
+			}
+		})
+	}
+}
+
+func Test_TextObj_PairObject(t *testing.T) {
+	text := "f(a, g(b)) {\n\tx\n}"
+	lines := strings.Split(text, "\n")
+	lineAt := func(row int) []rune { return []rune(lines[row]) }
+	type args struct {
+		row    int
+		col    int
+		open   rune
+		close  rune
+		around bool
+	}
+	type want struct {
+		startRow int
+		startCol int
+		endRow   int
+		endCol   int
+		ok       bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{0, 2, '(', ')', false}, want{0, 2, 0, 9, true}},
+		{"Test #2", args{0, 7, '(', ')', false}, want{0, 7, 0, 8, true}},
+		{"Test #3", args{0, 7, '(', ')', true}, want{0, 6, 0, 9, true}},
+		{"Test #4", args{0, 9, '(', ')', false}, want{0, 2, 0, 9, true}},
+		{"Test #5", args{0, 1, '(', ')', true}, want{0, 1, 0, 10, true}},
+		{"Test #6", args{1, 1, '{', '}', false}, want{0, 12, 2, 0, true}},
+		{"Test #7", args{1, 1, '(', ')', false}, want{0, 0, 0, 0, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sr, sc, er, ec, ok := PairObject(lineAt, len(lines), tt.args.row, tt.args.col, tt.args.open, tt.args.close, tt.args.around)
+			got := want{sr, sc, er, ec, ok}
+			if got != tt.want {
+				t.Errorf("PairObject(%d, %d, %q) = %v, want %v", tt.args.row, tt.args.col, tt.args.open, got, tt.want)
+			}
+		})
+	}
+}
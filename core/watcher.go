@@ -0,0 +1,128 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// 監視対象の変更の種類
+const (
+	WATCH_CREATE  = unix.IN_CREATE | unix.IN_MOVED_TO
+	WATCH_DELETE  = unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_DELETE_SELF | unix.IN_MOVE_SELF
+	WATCH_MODIFY  = unix.IN_CLOSE_WRITE | unix.IN_MODIFY | unix.IN_ATTRIB
+	WATCH_ALL     = WATCH_CREATE | WATCH_DELETE | WATCH_MODIFY
+	WATCH_WRITING = unix.IN_MODIFY // 書き込み途中 (完了はIN_CLOSE_WRITEで通知される)
+)
+
+// 変更通知
+type WatchEvent struct {
+	Dir  string // 監視しているディレクトリ (またはファイル)
+	Name string // 変更のあったエントリ名 (監視対象自体の変更の場合は空)
+	Mask uint32 // 変更の種類
+}
+
+// 変更のあったパス
+func (ev WatchEvent) Path() string {
+	if ev.Name == "" {
+		return ev.Dir
+	}
+	return filepath.Join(ev.Dir, ev.Name)
+}
+
+// inotifyによるファイル変更監視構造体
+type Watcher struct {
+	fd      int      // inotifyのファイルディスクリプタ
+	file    *os.File // 読み取り用 (Fd()はブロッキングモードに戻すため使用しない)
+	mu      sync.Mutex
+	watches map[int32]string // ウォッチディスクリプタ -> パス
+	paths   map[string]int32 // パス -> ウォッチディスクリプタ
+	Events  chan WatchEvent  // 変更通知 (Close時にclose)
+}
+
+// 新しい変更監視の取得
+func NewWatcher() (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := new(Watcher)
+	w.fd = fd
+	w.file = os.NewFile(uintptr(fd), "inotify")
+	w.watches = make(map[int32]string)
+	w.paths = make(map[string]int32)
+	w.Events = make(chan WatchEvent, 64)
+	go w.readEvents()
+	return w, nil
+}
+
+// 監視対象の追加
+func (w *Watcher) Add(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.paths[path]; ok {
+		return nil
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, path, WATCH_ALL)
+	if err != nil {
+		return err
+	}
+	w.watches[int32(wd)] = path
+	w.paths[path] = int32(wd)
+	return nil
+}
+
+// 監視対象の削除
+func (w *Watcher) Remove(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	wd, ok := w.paths[path]
+	if !ok {
+		return
+	}
+	_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+	delete(w.watches, wd)
+	delete(w.paths, path)
+}
+
+// 監視の終了
+func (w *Watcher) Close() {
+	w.file.Close()
+}
+
+// 変更通知の読み取り
+func (w *Watcher) readEvents() {
+	defer close(w.Events)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(raw.Len)]
+			offset += unix.SizeofInotifyEvent + int(raw.Len)
+
+			w.mu.Lock()
+			dir, ok := w.watches[raw.Wd]
+			if ok && raw.Mask&unix.IN_IGNORED != 0 { // 監視対象が削除された
+				delete(w.watches, raw.Wd)
+				delete(w.paths, dir)
+			}
+			w.mu.Unlock()
+			if !ok || raw.Mask&unix.IN_IGNORED != 0 {
+				continue
+			}
+
+			name := string(nameBytes)
+			for len(name) > 0 && name[len(name)-1] == 0 { // 末尾のNULを除去
+				name = name[:len(name)-1]
+			}
+			w.Events <- WatchEvent{dir, name, raw.Mask}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_Watcher_Events(t *testing.T) {
+	tests := []struct {
+		name   string
+		action func(dir string) error
+		want   string
+		mask   uint32
+	}{
+		{"Test #1", func(dir string) error { return os.WriteFile(filepath.Join(dir, "a.txt"), []byte("A"), 0644) }, "a.txt", WATCH_CREATE},
+		{"Test #2", func(dir string) error { return os.Mkdir(filepath.Join(dir, "sub"), 0755) }, "sub", WATCH_CREATE},
+		{"Test #3", func(dir string) error {
+			if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("B"), 0644); err != nil {
+				return err
+			}
+			return os.Remove(filepath.Join(dir, "b.txt"))
+		}, "b.txt", WATCH_DELETE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := NewWatcher()
+			if err != nil {
+				t.Fatalf("NewWatcher() error = %v", err)
+			}
+			defer w.Close()
+			if err := w.Add(dir); err != nil {
+				t.Fatalf("Add() error = %v", err)
+			}
+			if err := tt.action(dir); err != nil {
+				t.Fatal(err)
+			}
+			timeout := time.After(2 * time.Second)
+			for {
+				select {
+				case ev := <-w.Events:
+					if ev.Name == tt.want && ev.Mask&tt.mask != 0 {
+						if got := ev.Path(); got != filepath.Join(dir, tt.want) {
+							t.Errorf("Path() = %v, want %v", got, filepath.Join(dir, tt.want))
+						}
+						return
+					}
+				case <-timeout:
+					t.Fatalf("no event for %v", tt.want)
+				}
+			}
+		})
+	}
+}
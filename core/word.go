@@ -0,0 +1,179 @@
+package core
+
+import "unicode"
+
+// 単語の区切りを判定するための文字の種類
+type CharClass int8
+
+const (
+	CHAR_SPACE    CharClass = iota // 空白
+	CHAR_PUNCT                     // 記号 (全角の句読点・括弧を含む)
+	CHAR_WORD                      // 英数字・アンダースコア・その他の文字
+	CHAR_HIRAGANA                  // ひらがな
+	CHAR_KATAKANA                  // カタカナ (長音記号を含む)
+	CHAR_HAN                       // 漢字
+)
+
+// 文字の種類の取得
+func ClassOf(r rune) CharClass {
+	switch {
+	case unicode.IsSpace(r):
+		return CHAR_SPACE
+	case r == '_':
+		return CHAR_WORD
+	case unicode.Is(unicode.Hiragana, r):
+		return CHAR_HIRAGANA
+	case unicode.Is(unicode.Katakana, r) || r == 'ー':
+		return CHAR_KATAKANA
+	case unicode.Is(unicode.Han, r) || r == '々':
+		return CHAR_HAN
+	case unicode.IsPunct(r) || unicode.IsSymbol(r):
+		return CHAR_PUNCT
+	}
+	return CHAR_WORD
+}
+
+// 文字の種類の判定関数
+type ClassFunc func(r rune) CharClass
+
+// 空白以外を全て同じ種類として扱う文字の種類 (空白区切りの単語用)
+func BigClassOf(r rune) CharClass {
+	if ClassOf(r) == CHAR_SPACE {
+		return CHAR_SPACE
+	}
+	return CHAR_WORD
+}
+
+// 次の単語の先頭のインデックス (行末に達した場合は行の長さ)
+// 現在の単語の残りと続く空白を読み飛ばす
+func NextWordStart(line []rune, idx int) int {
+	return NextWordStartBy(line, idx, ClassOf)
+}
+
+// 文字の種類の判定関数を指定した次の単語の先頭のインデックス
+func NextWordStartBy(line []rune, idx int, classOf ClassFunc) int {
+	if idx >= len(line) {
+		return len(line)
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	class := classOf(line[idx])
+	if class != CHAR_SPACE {
+		for idx < len(line) && classOf(line[idx]) == class {
+			idx++
+		}
+	}
+	for idx < len(line) && classOf(line[idx]) == CHAR_SPACE {
+		idx++
+	}
+	return idx
+}
+
+// 前の単語の先頭のインデックス (行頭に達した場合は0)
+// 直前の空白を読み飛ばし、その単語の先頭まで戻る
+func PrevWordStart(line []rune, idx int) int {
+	return PrevWordStartBy(line, idx, ClassOf)
+}
+
+// 文字の種類の判定関数を指定した前の単語の先頭のインデックス
+func PrevWordStartBy(line []rune, idx int, classOf ClassFunc) int {
+	if idx > len(line) {
+		idx = len(line)
+	}
+	for idx > 0 && classOf(line[idx-1]) == CHAR_SPACE {
+		idx--
+	}
+	if idx == 0 {
+		return 0
+	}
+	class := classOf(line[idx-1])
+	for idx > 0 && classOf(line[idx-1]) == class {
+		idx--
+	}
+	return idx
+}
+
+// 行頭の空白を除いた最初の文字のインデックス (空白のみの行は行の長さ)
+func FirstNonBlank(line []rune) int {
+	for i, r := range line {
+		if ClassOf(r) != CHAR_SPACE {
+			return i
+		}
+	}
+	return len(line)
+}
+
+// 対応する括弧の組
+var bracketPairs = map[rune]rune{
+	'(': ')', '[': ']', '{': '}',
+	')': '(', ']': '[', '}': '{',
+}
+
+// 対応する括弧の位置の取得 (行・列は0始まり)
+// 指定位置の文字が括弧でない場合は直前の文字を使用する
+// lineAtは指定した行の内容を返す関数
+func MatchBracket(lineAt func(row int) []rune, lineCount int, row int, col int) (int, int, bool) {
+	return MatchBracketIn(lineAt, nil, lineCount, row, col)
+}
+
+// 字句の種類を区別した対応する括弧の位置の取得 (kindAtは指定した行の各文字の字句の種類を返す関数)
+// 指定位置の括弧と同じ種類 (コード・文字列・コメント) の括弧のみを対応させる、kindAtがnilの場合は区別しない
+func MatchBracketIn(lineAt func(row int) []rune, kindAt func(row int) []TokenKind, lineCount int, row int, col int) (int, int, bool) {
+	line := lineAt(row)
+	if col >= len(line) || bracketPairs[line[col]] == 0 {
+		col--
+	}
+	if col < 0 || col >= len(line) || bracketPairs[line[col]] == 0 {
+		return 0, 0, false
+	}
+	open := line[col]
+	close := bracketPairs[open]
+	step := 1
+	if open == ')' || open == ']' || open == '}' {
+		step = -1
+	}
+	kindOf := func(kinds []TokenKind, c int) TokenKind {
+		if c < len(kinds) {
+			return kinds[c]
+		}
+		return TOKEN_CODE
+	}
+	var kinds []TokenKind
+	if kindAt != nil {
+		kinds = kindAt(row)
+	}
+	kind := kindOf(kinds, col)
+	depth := 0
+	for r := row; r >= 0 && r < lineCount; r += step {
+		text := line
+		if r != row {
+			text = lineAt(r)
+			if kindAt != nil {
+				kinds = kindAt(r)
+			}
+		}
+		c := col
+		if r != row {
+			c = 0
+			if step < 0 {
+				c = len(text) - 1
+			}
+		}
+		for ; c >= 0 && c < len(text); c += step {
+			if kindOf(kinds, c) != kind {
+				continue
+			}
+			switch text[c] {
+			case open:
+				depth++
+			case close:
+				depth--
+				if depth == 0 {
+					return r, c, true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
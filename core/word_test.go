@@ -0,0 +1,166 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Word_NextWordStart(t *testing.T) {
+	type args struct {
+		line string
+		idx  int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"Test #1", args{"foo bar", 0}, 4},
+		{"Test #2", args{"foo bar", 4}, 7},
+		{"Test #3", args{"foo.bar", 0}, 3},
+		{"Test #4", args{"foo.bar", 3}, 4},
+		{"Test #5", args{"  foo", 0}, 2},
+		{"Test #6", args{"日本語のテキスト", 0}, 3},
+		{"Test #7", args{"日本語のテキスト", 3}, 4},
+		{"Test #8", args{"日本語のテキスト", 4}, 8},
+		{"Test #9", args{"abc、def", 0}, 3},
+		{"Test #10", args{"foo", 3}, 3},
+		{"Test #11", args{"snake_case1 x", 0}, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextWordStart([]rune(tt.args.line), tt.args.idx); got != tt.want {
+				t.Errorf("NextWordStart(%q, %d) = %d, want %d", tt.args.line, tt.args.idx, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Word_PrevWordStart(t *testing.T) {
+	type args struct {
+		line string
+		idx  int
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"Test #1", args{"foo bar", 7}, 4},
+		{"Test #2", args{"foo bar", 4}, 0},
+		{"Test #3", args{"foo bar", 5}, 4},
+		{"Test #4", args{"foo.bar", 4}, 3},
+		{"Test #5", args{"  foo", 2}, 0},
+		{"Test #6", args{"日本語のテキスト", 8}, 4},
+		{"Test #7", args{"日本語のテキスト", 4}, 3},
+		{"Test #8", args{"日本語のテキスト", 3}, 0},
+		{"Test #9", args{"", 0}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PrevWordStart([]rune(tt.args.line), tt.args.idx); got != tt.want {
+				t.Errorf("PrevWordStart(%q, %d) = %d, want %d", tt.args.line, tt.args.idx, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Word_FirstNonBlank(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want int
+	}{
+		{"Test #1", "foo", 0},
+		{"Test #2", "\t  foo", 3},
+		{"Test #3", "   ", 3},
+		{"Test #4", "　全角", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FirstNonBlank([]rune(tt.line)); got != tt.want {
+				t.Errorf("FirstNonBlank(%q) = %d, want %d", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Word_MatchBracket(t *testing.T) {
+	text := "func f(a []int) {\n\tif (a[0]) {\n\t}\n}"
+	lines := strings.Split(text, "\n")
+	lineAt := func(row int) []rune { return []rune(lines[row]) }
+	type args struct {
+		row int
+		col int
+	}
+	type want struct {
+		row int
+		col int
+		ok  bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{0, 6}, want{0, 14, true}},
+		{"Test #2", args{0, 14}, want{0, 6, true}},
+		{"Test #3", args{0, 16}, want{3, 0, true}},
+		{"Test #4", args{3, 0}, want{0, 16, true}},
+		{"Test #5", args{1, 11}, want{2, 1, true}},
+		{"Test #6", args{0, 15}, want{0, 6, true}},
+		{"Test #7", args{0, 1}, want{0, 0, false}},
+		{"Test #8", args{1, 6}, want{1, 8, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, col, ok := MatchBracket(lineAt, len(lines), tt.args.row, tt.args.col)
+			if row != tt.want.row || col != tt.want.col || ok != tt.want.ok {
+				t.Errorf("MatchBracket(%d, %d) = %d, %d, %v, want %d, %d, %v", tt.args.row, tt.args.col, row, col, ok, tt.want.row, tt.want.col, tt.want.ok)
+			}
+		})
+	}
+}
+
+func Test_Word_MatchBracketIn(t *testing.T) {
+	syn := Syntax{"//", [2]string{"/*", "*/"}, "\"", "`"}
+	text := "f(\")\", // )\n/* ( */ `(\n)` )"
+	lines := strings.Split(text, "\n")
+	lineAt := func(row int) []rune { return []rune(lines[row]) }
+	kindAt := func(row int) []TokenKind {
+		var state LexState
+		var kinds []TokenKind
+		for r := 0; r <= row; r++ {
+			kinds, state = Lex(lineAt(r), syn, state)
+		}
+		return kinds
+	}
+	type args struct {
+		row int
+		col int
+	}
+	type want struct {
+		row int
+		col int
+		ok  bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{0, 1}, want{2, 3, true}},
+		{"Test #2", args{2, 3}, want{0, 1, true}},
+		{"Test #3", args{1, 9}, want{2, 0, true}},
+		{"Test #4", args{2, 0}, want{1, 9, true}},
+		{"Test #5", args{1, 3}, want{0, 0, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, col, ok := MatchBracketIn(lineAt, kindAt, len(lines), tt.args.row, tt.args.col)
+			if row != tt.want.row || col != tt.want.col || ok != tt.want.ok {
+				t.Errorf("MatchBracketIn(%d, %d) = %d, %d, %v, want %d, %d, %v", tt.args.row, tt.args.col, row, col, ok, tt.want.row, tt.want.col, tt.want.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+// ディスク上のファイルの状態
+type FileStamp struct {
+	ModTime time.Time         // 更新時刻
+	Size    int64             // サイズ
+	Sum     [sha256.Size]byte // 内容のハッシュ
+}
+
+// ディスク上のファイルの内容
+type DiskFile struct {
+	Stamp FileStamp
+	Data  []byte
+}
+
+// 保存した時点のファイルの状態を記録
+func (b *Buffer) recordDisk() {
+	data := b.Bytes(b.NL)
+	if info, err := os.Stat(b.FilePath); err == nil {
+		b.Disk = FileStamp{info.ModTime(), info.Size(), sha256.Sum256(data)}
+	}
+	b.Base = b.LineStrings()
+	b.DiskChanged = false
+}
+
+// 最後に読み込み・保存した後にファイルが外部で変更されていれば、その内容を返す (変更がない場合はnil)
+// 更新時刻のみ変わって内容が同じ場合は変更として扱わない
+func (b *Buffer) changedOnDisk() (*DiskFile, error) {
+	if b.FilePath == "" {
+		return nil, nil
+	}
+	info, err := os.Stat(b.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.ModTime().Equal(b.Disk.ModTime) && info.Size() == b.Disk.Size {
+		return nil, nil
+	}
+	data, err := os.ReadFile(b.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	stamp := FileStamp{info.ModTime(), info.Size(), sha256.Sum256(data)}
+	if stamp.Sum == b.Disk.Sum {
+		b.Disk = stamp
+		return nil, nil
+	}
+	return &DiskFile{stamp, data}, nil
+}
+
+// ディスク上の内容を行リストとして取得
+func (b *Buffer) diskLines(d *DiskFile) *Buffer {
+	disk := NewBuffer(b.FilePath, b.TabSize)
+	disk.readLines(bytes.NewReader(d.Data))
+	return disk
+}
+
+// ディスク上の内容で置き換え
+func (b *Buffer) loadDisk(d *DiskFile) {
+	disk := b.diskLines(d)
+	b.Lines = disk.Lines
+	b.changedFrom(1)
+	b.NL = disk.NL
+	b.Disk = d.Stamp
+	b.Base = disk.LineStrings()
+	b.IsSaved = true
+	b.IsNew = false
+	b.DiskChanged = false
+}
+
+// 未解決の競合マーカーの数
+func (b *Buffer) ConflictCount() (n int) {
+	for _, line := range b.Lines {
+		if line.Length() == len(core.CONFLICT_OURS) && string(line.GetAll()) == core.CONFLICT_OURS {
+			n++
+		}
+	}
+	return
+}
+
+// ファイルの外部での変更の確認
+// 未編集のバッファは読み込み直し、未保存の変更がある場合は警告して保存時にマージ方法を選択する
+func (v *View) CheckDisk(b *Buffer) {
+	d, err := b.changedOnDisk()
+	if os.IsNotExist(err) {
+		if !b.IsNew { // 削除された場合は新規ファイルとして扱う
+			b.IsNew = true
+			b.IsSaved = false
+			v.UpdateTabBar()
+			v.SetMessage("Warning: %s was deleted on disk", b.Title())
+		}
+		return
+	}
+	if err != nil {
+		return
+	}
+	// 未編集 (削除された後に作り直された場合は、削除前の内容から変更していないもの)
+	unchanged := b.IsSaved || slices.Equal(b.LineStrings(), b.Base)
+	if d == nil {
+		if b.IsNew && unchanged { // 同じ内容で作り直された
+			b.IsNew = false
+			b.IsSaved = true
+			v.UpdateTabBar()
+		}
+		return
+	}
+	if unchanged {
+		b.loadDisk(d)
+		v.RefleshTextField()
+		v.UpdateTabBar()
+		v.SetMessage("Reloaded %s (changed on disk)", b.Title())
+		return
+	}
+	if !b.DiskChanged {
+		b.DiskChanged = true
+		v.SetMessage("Warning: %s changed on disk (choose how to merge on save)", b.Title())
+	}
+}
+
+// 外部で変更されたファイルへの保存方法の選択
+// マージ・こちらの内容を保存・ディスク上の内容を使用のいずれかを選択する (ESCで保存を中止)
+func (v *View) resolveDiskChange(b *Buffer, d *DiskFile, done func(v *View) uint8) {
+	options := []ChoiceOption{{'m', "merge"}, {'k', "keep ours"}, {'t', "take theirs"}}
+	message := fmt.Sprintf("%s changed on disk.", b.Title())
+	v.OpenOverlay(NewChoice(message, options, func(v *View, key rune) uint8 {
+		switch key {
+		case 'm': // 3方向マージ (競合がなければそのまま保存)
+			theirs := b.diskLines(d)
+			merged, conflicts := core.Merge3(b.Base, b.LineStrings(), theirs.LineStrings())
+			b.SetLines(merged)
+			b.NL = theirs.NL
+			b.Disk = d.Stamp
+			b.Base = theirs.LineStrings()
+			b.DiskChanged = false
+			b.IsSaved = false
+			v.Reflesh()
+			if conflicts > 0 {
+				v.SetMessage("Merged with %d conflict(s): resolve the conflict markers and save again", conflicts)
+				return 0
+			}
+			return v.storeTab(b, done)
+		case 'k': // ディスク上の変更を破棄して保存
+			return v.storeTab(b, done)
+		case 't': // 未保存の変更を破棄してディスク上の内容を使用
+			b.loadDisk(d)
+			b.syncSwap()
+			v.Reflesh()
+			v.SetMessage("Took changes of %s from disk", b.Title())
+			if done != nil {
+				return done(v)
+			}
+		}
+		return 0
+	}))
+}
+
+// 開いているファイルの外部での変更を監視 (ファイルのあるディレクトリを監視する)
+func (v *View) watchFile(b *Buffer) {
+	if b.FilePath == "" {
+		return
+	}
+	if v.FileWatcher == nil {
+		w, err := core.NewWatcher()
+		if err != nil {
+			return
+		}
+		v.FileWatcher = w
+	}
+	if dir, err := filepath.Abs(filepath.Dir(b.FilePath)); err == nil {
+		v.FileWatcher.Add(dir)
+	}
+}
+
+// 同じディレクトリのファイルを開いているタブがなければ監視を終了
+func (v *View) unwatchFile(b *Buffer) {
+	if v.FileWatcher == nil || b.FilePath == "" {
+		return
+	}
+	dir, err := filepath.Abs(filepath.Dir(b.FilePath))
+	if err != nil {
+		return
+	}
+	for _, tab := range v.Buffers.All() {
+		if tab == b || tab.FilePath == "" {
+			continue
+		}
+		if d, err := filepath.Abs(filepath.Dir(tab.FilePath)); err == nil && d == dir {
+			return
+		}
+	}
+	v.FileWatcher.Remove(dir)
+}
+
+// 変更通知を受け取ったファイルを開いているタブの確認
+func (v *View) ReceiveFileEvent(ev core.WatchEvent, ok bool) {
+	if !ok {
+		v.FileWatcher = nil
+		return
+	}
+	if ev.Mask&^core.WATCH_WRITING == 0 { // 書き込みの完了を待つ
+		return
+	}
+	for _, b := range v.Buffers.All() {
+		if abs, err := filepath.Abs(b.FilePath); err == nil && b.FilePath != "" && abs == ev.Path() {
+			v.CheckDisk(b)
+		}
+	}
+}
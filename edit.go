@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+// 指定した行の内容 (1始まり)
+func (b *Buffer) LineRunes(row uint) []rune {
+	return b.Lines[row-1].GetAll()
+}
+
+// 指定位置への文字列の挿入 (\nで改行し、挿入した文字列の直後の位置を返す)
+func (b *Buffer) InsertText(at Cursor, text []rune) Cursor {
+	parts := strings.Split(string(text), "\n")
+	line := b.Lines[at.Row-1]
+	b.changedFrom(at.Row)
+	if len(parts) == 1 {
+		line.InsertAll(int(at.Col-1), text)
+		b.IsSaved = false
+		return Cursor{Row: at.Row, Col: at.Col + uint(len(text))}
+	}
+	tail := line.GetFrom(int(at.Col-1), line.Length())
+	line.EraseFrom(int(at.Col-1), line.Length())
+	line.AppendAll([]rune(parts[0]))
+	row := at.Row
+	for _, part := range parts[1:] {
+		b.InsertLine(row)
+		b.Lines[row].AppendAll([]rune(part))
+		row++
+	}
+	last := b.Lines[row-1]
+	end := Cursor{Row: row, Col: uint(last.Length()) + 1}
+	last.AppendAll(tail)
+	b.IsSaved = false
+	return end
+}
+
+// 指定した行の前への行の挿入 (rowが行数+1の場合は末尾に追加)
+func (b *Buffer) InsertLines(row uint, lines []string) {
+	for i, text := range lines {
+		idx := row - 1 + uint(i)
+		b.InsertLine(idx)
+		b.Lines[idx].AppendAll([]rune(text))
+	}
+	b.IsSaved = false
+}
+
+// 行の範囲[from, to]の削除 (全ての行を削除した場合は空の行を1行残す)
+func (b *Buffer) DeleteLines(from uint, to uint) {
+	for row := to; row >= from; row-- {
+		b.DeleteLine(row - 1)
+	}
+	if len(b.Lines) == 0 {
+		b.Lines = []*core.GapBuffer{core.NewGapBuffer([]rune{}, LINE_BUF_MAX)}
+	}
+	b.IsSaved = false
+}
+
+// 行の範囲[from, to]の内容
+func (b *Buffer) LinesText(from uint, to uint) []string {
+	lines := make([]string, 0, to-from+1)
+	for row := from; row <= to; row++ {
+		lines = append(lines, string(b.LineRunes(row)))
+	}
+	return lines
+}
+
+// 行の内容の置き換え
+func (b *Buffer) ReplaceLine(row uint, text []rune) {
+	line := b.Lines[row-1]
+	line.EraseFrom(0, line.Length())
+	line.AppendAll(text)
+	b.changedFrom(row)
+	b.IsSaved = false
+}
+
+// 各行の表示位置cellへの矩形の挿入 (行が足りない場合は追加し、短い行は空白で埋める)
+// 挿入位置の後ろに文字が続く行では矩形の幅に揃えるため空白を補う
+func (b *Buffer) InsertBlock(row uint, cell int, lines []string) {
+	tabSize := int(b.TabSize)
+	width := 0
+	for _, text := range lines {
+		seg := []rune(text)
+		width = max(width, utils.CellsBefore(seg, len(seg), tabSize))
+	}
+	for i, text := range lines {
+		r := row + uint(i)
+		if !b.hasRow(r) {
+			b.InsertLines(r, []string{""})
+		}
+		line := b.LineRunes(r)
+		seg := []rune(text)
+		idx := len(line)
+		if end := utils.CellsBefore(line, len(line), tabSize); end < cell {
+			seg = append([]rune(strings.Repeat(" ", cell-end)), seg...)
+		} else if idx = utils.IndexAtCell(line, cell, tabSize); idx < len(line) {
+			seg = append(seg, []rune(strings.Repeat(" ", width-utils.CellsBefore(seg, len(seg), tabSize)))...)
+		}
+		b.Lines[r-1].InsertAll(idx, seg)
+		b.changedFrom(r)
+	}
+	b.IsSaved = false
+}
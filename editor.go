@@ -1,250 +1,406 @@
-package main
-
-import (
-	"bufio"
-	"fmt"
-	"io"
-	"os"
-	"strings"
-
-	"github.com/broccolingual/Xanadu/core"
-	"github.com/broccolingual/Xanadu/utils"
-)
-
-const LINE_BUF_MAX = 256 // 1行のバッファサイズ
-
-// エディタ構造体
-type Editor struct {
-	FilePath    string          // ファイルのパス
-	Cursor      *Cursor         // 現在のカーソル位置
-	Lines       []*core.GapBuffer // 行リスト TODO: ファイル全体をGapBufferで管理する
-	TabSize     uint8           // タブサイズ (0~255)
-	NL          utils.NLCode          // 改行文字識別番号
-	IsSaved     bool            // セーブ済みフラグ
-	ScrollRow   uint            // 現在表示中の最上行
-}
-
-// カーソル構造体
-type Cursor struct {
-	Row uint
-	Col uint
-}
-
-// 新しいカーソルの取得
-func NewCursor() (cursor *Cursor) {
-	cursor = new(Cursor)
-	cursor.Row = 1
-	cursor.Col = 1
-	return
-}
-
-// 新しいエディタの取得
-func NewEditor(filePath string, tabSize uint8) (editor *Editor) {
-	editor = new(Editor)
-	editor.FilePath = filePath
-	editor.Cursor = NewCursor()
-	editor.Lines = make([]*core.GapBuffer, 0)
-	editor.TabSize = tabSize
-	editor.NL = -1
-	editor.IsSaved = true
-	editor.ScrollRow = 1
-	return
-}
-
-func (e *Editor) InsertLine(idx uint) {
-	e.Lines = append(e.Lines[:idx], append([]*core.GapBuffer{core.NewGapBuffer([]rune{}, LINE_BUF_MAX)}, e.Lines[idx:]...)...)
-}
-
-func (e *Editor) DeleteLine(idx uint) {
-	if idx < uint(len(e.Lines)-1) {
-		copy(e.Lines[idx:], e.Lines[idx+1:])
-	}
-	e.Lines[len(e.Lines)-1] = nil
-	e.Lines = e.Lines[:len(e.Lines)-1]
-}
-
-func (e *Editor) IsFirstRow() bool {
-	return e.Cursor.Row <= 1
-}
-
-func (e *Editor) IsLastRow() bool {
-	return e.Cursor.Row >= uint(len(e.Lines)) - 1
-}
-
-func (e *Editor) MoveNextRow() {
-	if !e.IsLastRow() {
-		e.Cursor.Row++
-	}
-}
-
-func (e *Editor) MovePrevRow() {
-	if !e.IsFirstRow() {
-		e.Cursor.Row--
-	}
-}
-
-func (e *Editor) MoveTargetRow(row uint) {
-	e.Cursor.Row = row
-}
-
-func (e *Editor) MoveHeadRow() {
-	e.MoveTargetRow(1)
-}
-
-func (e *Editor) MoveTailRow() {
-	e.MoveTargetRow(uint(len(e.Lines))-1)
-}
-
-func (e *Editor) IsTargetRow(rowNum uint) bool {
-	return rowNum == e.Cursor.Row
-}
-
-func (e *Editor) ScrollDown() {
-	if !e.IsLastRow() {
-		e.ScrollRow++
-	}
-}
-
-func (e *Editor) ScrollUp() {
-	if !e.IsFirstRow() {
-		e.ScrollRow--
-	}
-}
-
-func (e *Editor) ScrollTargetRow(row uint) {
-	e.ScrollRow = row
-}
-
-func (e *Editor) ScrollHead() {
-	e.ScrollTargetRow(1)
-}
-
-func (e *Editor) ScrollTail() {
-	e.ScrollTargetRow(uint(len(e.Lines))-1)
-}
-
-func (e *Editor) IsFirstCol() bool {
-	return e.Cursor.Col <= 1
-}
-
-func (e *Editor) IsLastCol() bool {
-	return e.Cursor.Col > uint(e.Lines[e.Cursor.Row-1].Length())
-}
-
-func (e *Editor) MoveNextCol() {
-	if !e.IsLastCol() {
-		e.Cursor.Col++
-	}
-}
-
-func (e *Editor) MovePrevCol() {
-	if !e.IsFirstCol() {
-		e.Cursor.Col--
-	}
-}
-
-func (e *Editor) MoveTargetCol(col uint) {
-	e.Cursor.Col = col
-}
-
-func (e *Editor) MoveHeadCol() {
-	e.MoveTargetCol(1)
-}
-
-func (e *Editor) MoveTailCol() {
-	e.MoveTargetCol(uint(e.Lines[e.Cursor.Row-1].Length())+1)
-}
-
-func (e *Editor) GetCurrentMaxCol() uint {
-	return uint(e.Lines[e.Cursor.Row-1].Length())
-}
-
-// エディタに指定されたパスのファイルをロードして、行ノードを構成
-func (e *Editor) LoadFile() {
-	fp, err := os.Open(e.FilePath)
-	if err != nil {
-		panic(err)
-	}
-	defer fp.Close()
-
-	// conv tab to string
-	var tabStr string
-	for i := 0; i < int(e.TabSize); i++ {
-		tabStr += " "
-	}
-
-	reader := bufio.NewReaderSize(fp, LINE_BUF_MAX)
-	cnt := 0
-	for {
-		line, err := reader.ReadString(byte('\n'))                    // '\n'で分割
-		replacedStr := strings.ReplaceAll(string(line), "\t", tabStr) // タブをスペースに変換
-		replacedRune := []rune(replacedStr)
-		if cnt == 0 { // 改行文字の判定
-			e.NL = utils.GetNLCode(replacedRune)
-		}
-		switch e.NL { // 改行文字の削除
-		case utils.CRLF:
-			if len(replacedRune) >= 2 {
-				replacedRune = replacedRune[:len(replacedRune)-2]
-			}
-		case utils.CR:
-		case utils.LF:
-			if len(replacedRune) >= 1 {
-				replacedRune = replacedRune[:len(replacedRune)-1]
-			}
-		}
-
-		e.Lines = append(e.Lines, core.NewGapBuffer(replacedRune, LINE_BUF_MAX))
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			panic(err)
-		}
-		cnt++
-	}
-}
-
-// エディタに指定されたパスで上書き保存
-func (e *Editor) SaveOverwrite(nl utils.NLCode) (saveBytes int) {
-	saveBytes = e.saveFile(e.FilePath, nl)
-	return
-}
-
-// 新しくファイルを保存
-func (e *Editor) SaveNew(filePath string, nl utils.NLCode) (saveBytes int) {
-	saveBytes = e.saveFile(filePath, nl)
-	return
-}
-
-// ファイルを保存
-func (e *Editor) saveFile(filePath string, nl utils.NLCode) (saveBytes int) {
-	fp, err := os.Create(filePath)
-	if err != nil {
-		panic(err)
-	}
-	defer fp.Close()
-
-	var buf string
-
-	cnt := 0
-	for _, row := range e.Lines {
-		cnt++
-		buf += fmt.Sprintf("%s", string(row.GetAll()))
-		switch nl {
-		case utils.CRLF:
-			buf += "\r\n"
-		case utils.CR:
-			buf += "\r"
-		case utils.LF:
-			buf += "\n"
-		default:
-			buf += "\n"
-		}
-	}
-
-	saveBytes, err = fp.Write([]byte(buf))
-	if err != nil {
-		panic(err)
-	}
-	return
-}
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const LINE_BUF_MAX = 256 // 1行のバッファサイズ
+
+// バッファ構造体 (ファイルの内容と状態、同じバッファを複数のペインで共有する)
+type Buffer struct {
+	FilePath    string          // ファイルのパス
+	Lines       []*core.GapBuffer // 行リスト TODO: ファイル全体をGapBufferで管理する
+	TabSize     uint8           // タブサイズ (0~255)
+	NL          utils.NLCode          // 改行文字識別番号
+	IsSaved     bool            // セーブ済みフラグ
+	IsNew       bool            // ファイルがまだ存在しないフラグ (初回の保存時に作成)
+	Label       string          // ファイルを持たないバッファの表示名 (空の場合は"untitled")
+	SwapPath    string          // 使用中のスワップファイルのパス (使用していない場合は空)
+	key         string          // バッファの登録簿での正規化したパス (無題の場合は空)
+	swapSum     [sha256.Size]byte // 最後にスワップファイルに書き出した内容のハッシュ
+	autosaveInput time.Time     // 最後に自動保存を試みた時点の最終入力時刻
+	Disk        FileStamp       // 最後に読み込み・保存した時点のファイルの状態
+	Base        []string        // 最後に読み込み・保存した時点の内容 (3方向マージの共通祖先)
+	DiskChanged bool            // 未保存の変更がある間にファイルが外部で変更されたフラグ
+	undo        []undoState     // 取り消し用に記録した変更前の状態 (古い順)
+	redo        []undoState     // やり直し用に記録した取り消し前の状態 (古い順)
+	undoDepth   int             // 変更をまとめて記録中の入れ子の深さ
+	undoSaved   bool            // まとめて記録中の変更前の状態を記録済みかどうか
+	folds       map[uint]bool   // 閉じている折りたたみ範囲の開始行
+	lexed       []lexedLine     // 先頭の行から順に字句解析した結果 (編集した行以降は破棄する)
+	foldRanges  []core.FoldRange // 最後に求めた折りたたみ範囲 (編集するまで再利用する)
+	foldsStale  bool            // 編集後に折りたたみ範囲をまだ求め直していないかどうか
+}
+
+// エディタ構造体 (バッファ上のカーソルとスクロール位置、ペインごとに持つ)
+type Editor struct {
+	*Buffer
+	Cursor      *Cursor         // 現在のカーソル位置
+	ScrollRow   uint            // 現在表示中の最上行
+	Anchor      *Cursor         // 選択範囲の起点 (選択していない場合はnil)
+	SelMode     SelectionMode   // 選択範囲の種類
+	Carets      []Caret         // 追加のカーソル (複数カーソルでの編集、主カーソルはCursor)
+}
+
+// カーソル構造体
+type Cursor struct {
+	Row  uint
+	Col  uint
+	Want uint // 上下移動で戻ろうとする表示上の列 (1始まり、0の場合は未設定)
+}
+
+// 新しいカーソルの取得
+func NewCursor() (cursor *Cursor) {
+	cursor = new(Cursor)
+	cursor.Row = 1
+	cursor.Col = 1
+	return
+}
+
+// 新しいバッファの取得
+func NewBuffer(filePath string, tabSize uint8) (buffer *Buffer) {
+	buffer = new(Buffer)
+	buffer.FilePath = filePath
+	buffer.Lines = make([]*core.GapBuffer, 0)
+	buffer.TabSize = tabSize
+	buffer.NL = -1
+	buffer.IsSaved = true
+	buffer.IsNew = false
+	return
+}
+
+// バッファを表示する新しいエディタの取得
+func NewEditor(buffer *Buffer) (editor *Editor) {
+	editor = new(Editor)
+	editor.Buffer = buffer
+	editor.Cursor = NewCursor()
+	editor.ScrollRow = 1
+	return
+}
+
+// タブなどに表示する名前
+func (b *Buffer) Title() string {
+	if b.FilePath == "" {
+		if b.Label != "" {
+			return b.Label
+		}
+		return "untitled"
+	}
+	return b.FilePath
+}
+
+func (b *Buffer) InsertLine(idx uint) {
+	b.Lines = append(b.Lines[:idx], append([]*core.GapBuffer{core.NewGapBuffer([]rune{}, LINE_BUF_MAX)}, b.Lines[idx:]...)...)
+	b.changedFrom(idx + 1)
+	b.shiftFolds(idx+1, 1)
+}
+
+func (b *Buffer) DeleteLine(idx uint) {
+	if idx < uint(len(b.Lines)-1) {
+		copy(b.Lines[idx:], b.Lines[idx+1:])
+	}
+	b.Lines[len(b.Lines)-1] = nil
+	b.Lines = b.Lines[:len(b.Lines)-1]
+	b.changedFrom(idx + 1)
+	b.shiftFolds(idx+1, -1)
+}
+
+func (e *Editor) IsFirstRow() bool {
+	return e.Cursor.Row <= 1
+}
+
+func (e *Editor) IsLastRow() bool {
+	return e.Cursor.Row >= uint(len(e.Lines))
+}
+
+// 次の表示される行へ移動 (閉じている折りたたみ範囲は飛ばす)
+func (e *Editor) MoveNextRow() {
+	if row := e.stepVisible(e.Cursor.Row, 1); row != e.Cursor.Row {
+		e.MoveTargetRow(row)
+	}
+}
+
+// 前の表示される行へ移動 (閉じている折りたたみ範囲は最初の行へ)
+func (e *Editor) MovePrevRow() {
+	if row := e.stepVisible(e.Cursor.Row, -1); row != e.Cursor.Row {
+		e.MoveTargetRow(row)
+	}
+}
+
+// 行の移動 (移動前の表示上の列をできるだけ保つ)
+func (e *Editor) MoveTargetRow(row uint) {
+	if e.Cursor.Want == 0 && e.hasRow(e.Cursor.Row) {
+		e.Cursor.Want = e.DisplayCol()
+	}
+	e.Cursor.Row = row
+	if e.hasRow(row) {
+		e.Cursor.Col = uint(utils.IndexAtCell(e.Lines[row-1].GetAll(), int(e.Cursor.Want-1), int(e.TabSize))) + 1
+	}
+}
+
+func (e *Editor) MoveHeadRow() {
+	e.MoveTargetRow(1)
+}
+
+func (e *Editor) MoveTailRow() {
+	e.MoveTargetRow(uint(len(e.Lines)))
+}
+
+// 行番号がバッファの範囲内かどうか
+func (b *Buffer) hasRow(row uint) bool {
+	return row >= 1 && row <= uint(len(b.Lines))
+}
+
+func (e *Editor) IsTargetRow(rowNum uint) bool {
+	return rowNum == e.Cursor.Row
+}
+
+func (e *Editor) ScrollDown() {
+	if !e.IsLastRow() {
+		e.ScrollRow = e.stepVisible(e.ScrollRow, 1)
+	}
+}
+
+func (e *Editor) ScrollUp() {
+	if !e.IsFirstRow() {
+		e.ScrollRow = e.stepVisible(e.ScrollRow, -1)
+	}
+}
+
+func (e *Editor) ScrollTargetRow(row uint) {
+	e.ScrollRow = row
+}
+
+func (e *Editor) ScrollHead() {
+	e.ScrollTargetRow(1)
+}
+
+func (e *Editor) ScrollTail() {
+	e.ScrollTargetRow(uint(len(e.Lines)))
+}
+
+func (e *Editor) IsFirstCol() bool {
+	return e.Cursor.Col <= 1
+}
+
+func (e *Editor) IsLastCol() bool {
+	return e.Cursor.Col > uint(e.Lines[e.Cursor.Row-1].Length())
+}
+
+func (e *Editor) MoveNextCol() {
+	if !e.IsLastCol() {
+		e.MoveTargetCol(e.Cursor.Col + 1)
+	}
+}
+
+func (e *Editor) MovePrevCol() {
+	if !e.IsFirstCol() {
+		e.MoveTargetCol(e.Cursor.Col - 1)
+	}
+}
+
+// 列の移動 (上下移動で戻ろうとする列を解除する)
+func (e *Editor) MoveTargetCol(col uint) {
+	e.Cursor.Col = col
+	e.Cursor.Want = 0
+}
+
+func (e *Editor) MoveHeadCol() {
+	e.MoveTargetCol(1)
+}
+
+func (e *Editor) MoveTailCol() {
+	e.MoveTargetCol(uint(e.Lines[e.Cursor.Row-1].Length())+1)
+}
+
+func (e *Editor) GetCurrentMaxCol() uint {
+	return uint(e.Lines[e.Cursor.Row-1].Length())
+}
+
+// カーソルの表示上の列 (1始まり、全角文字は2列として数える)
+func (e *Editor) DisplayCol() uint {
+	return uint(utils.CellsBefore(e.Lines[e.Cursor.Row-1].GetAll(), int(e.Cursor.Col-1), int(e.TabSize))) + 1
+}
+
+// 内容の変更後にカーソル位置とスクロール位置を範囲内に収める
+// (他のペインで同じバッファを編集した場合など)
+func (e *Editor) clampCursor() {
+	if e.Cursor.Row > uint(len(e.Lines)) {
+		e.Cursor.Row = uint(len(e.Lines))
+	}
+	if e.Cursor.Row < 1 {
+		e.Cursor.Row = 1
+	}
+	if e.Cursor.Col > e.GetCurrentMaxCol()+1 {
+		e.MoveTailCol()
+	}
+	e.revealRow(e.Cursor.Row)
+	if e.ScrollRow > e.Cursor.Row {
+		e.ScrollRow = e.Cursor.Row
+	}
+	e.ScrollRow = visibleRow(e.closedFolds(), e.ScrollRow)
+	if e.Anchor != nil {
+		if e.Anchor.Row > uint(len(e.Lines)) {
+			e.Anchor.Row = uint(len(e.Lines))
+		}
+		if max := uint(e.Lines[e.Anchor.Row-1].Length()) + 1; e.Anchor.Col > max {
+			e.Anchor.Col = max
+		}
+	}
+}
+
+// 空のバッファで初期化 (ファイルを読み込まない場合)
+func (b *Buffer) InitEmpty() {
+	b.Lines = []*core.GapBuffer{core.NewGapBuffer([]rune{}, LINE_BUF_MAX)}
+	b.changedFrom(1)
+	b.NL = utils.LF
+}
+
+// 文字列の行リストで初期化
+func (b *Buffer) SetLines(lines []string) {
+	if len(lines) == 0 {
+		b.InitEmpty()
+		return
+	}
+	b.Lines = make([]*core.GapBuffer, len(lines))
+	for i, line := range lines {
+		b.Lines[i] = core.NewGapBuffer([]rune(line), LINE_BUF_MAX)
+	}
+	b.changedFrom(1)
+	b.NL = utils.LF
+	b.folds = nil // 内容を置き換えた場合は折りたたみを解除
+}
+
+// 行リストを文字列として取得
+func (b *Buffer) LineStrings() []string {
+	lines := make([]string, len(b.Lines))
+	for i, row := range b.Lines {
+		lines[i] = string(row.GetAll())
+	}
+	return lines
+}
+
+// ファイルのパスの変更 (スワップファイルも新しいパスのものに切り替える)
+func (b *Buffer) SetFilePath(filePath string) {
+	b.removeSwap()
+	b.FilePath = filePath
+	b.changedFrom(1) // 拡張子により言語が変わる
+	b.claimSwap()
+}
+
+// エディタに指定されたパスのファイルをロードして、行ノードを構成
+// ファイルが存在しない場合は空のバッファとして開く
+func (b *Buffer) LoadFile() error {
+	info, err := os.Stat(b.FilePath)
+	if os.IsNotExist(err) {
+		b.InitEmpty()
+		b.IsNew = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", b.FilePath)
+	}
+	data, err := os.ReadFile(b.FilePath)
+	if err != nil {
+		return err
+	}
+	if err := b.readLines(bytes.NewReader(data)); err != nil {
+		return err
+	}
+	b.Disk = FileStamp{info.ModTime(), info.Size(), sha256.Sum256(data)}
+	b.Base = b.LineStrings()
+	return nil
+}
+
+// 読み込んだ内容を改行で分割して行ノードを構成
+func (b *Buffer) readLines(r io.Reader) error {
+	b.changedFrom(1)
+	// conv tab to string
+	var tabStr string
+	for i := 0; i < int(b.TabSize); i++ {
+		tabStr += " "
+	}
+
+	reader := bufio.NewReaderSize(r, LINE_BUF_MAX)
+	cnt := 0
+	for {
+		line, err := reader.ReadString(byte('\n'))                    // '\n'で分割
+		if line == "" && err == io.EOF && cnt > 0 { // 末尾の改行の後には行を追加しない
+			break
+		}
+		replacedStr := strings.ReplaceAll(string(line), "\t", tabStr) // タブをスペースに変換
+		replacedRune := []rune(replacedStr)
+		if cnt == 0 { // 改行文字の判定
+			b.NL = utils.GetNLCode(replacedRune)
+			if b.NL < 0 {
+				b.NL = utils.LF
+			}
+		}
+		replacedRune = utils.TrimNL(replacedRune, b.NL) // 改行文字の削除
+
+		b.Lines = append(b.Lines, core.NewGapBuffer(replacedRune, LINE_BUF_MAX))
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			b.Lines = make([]*core.GapBuffer, 0)
+			return err
+		}
+		cnt++
+	}
+	return nil
+}
+
+// エディタに指定されたパスで上書き保存
+func (b *Buffer) SaveOverwrite(nl utils.NLCode) (saveBytes int, err error) {
+	return b.saveFile(b.FilePath, nl)
+}
+
+// 新しくファイルを保存
+func (b *Buffer) SaveNew(filePath string, nl utils.NLCode) (saveBytes int, err error) {
+	return b.saveFile(filePath, nl)
+}
+
+// バッファの内容を改行文字で連結したバイト列
+func (b *Buffer) Bytes(nl utils.NLCode) []byte {
+	var buf strings.Builder
+	for _, row := range b.Lines {
+		buf.WriteString(string(row.GetAll()))
+		switch nl {
+		case utils.CRLF:
+			buf.WriteString("\r\n")
+		case utils.CR:
+			buf.WriteString("\r")
+		case utils.LF:
+			buf.WriteString("\n")
+		default:
+			buf.WriteString("\n")
+		}
+	}
+	return []byte(buf.String())
+}
+
+// ファイルを保存
+func (b *Buffer) saveFile(filePath string, nl utils.NLCode) (saveBytes int, err error) {
+	fp, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	saveBytes, err = fp.Write(b.Bytes(nl))
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
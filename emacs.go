@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Emacs風のキー割り当て (キー列 -> アクション名)
+var emacsKeys = map[string]string{
+	chord(CTRL_A):                   "Cursor Line Start",
+	chord(CTRL_E):                   "Cursor Line End",
+	chord(CTRL_F):                   "Cursor Right",
+	chord(CTRL_B):                   "Cursor Left",
+	chord(CTRL_N):                   "Cursor Down",
+	chord(CTRL_P):                   "Cursor Up",
+	chord(KEY_ALT | 'f'):            "Cursor Word Right",
+	chord(KEY_ALT | 'b'):            "Cursor Word Left",
+	chord(KEY_ALT | '{'):            "Cursor Paragraph Up",
+	chord(KEY_ALT | '}'):            "Cursor Paragraph Down",
+	chord(CTRL_V):                   "Cursor Page Down",
+	chord(KEY_ALT | 'v'):            "Cursor Page Up",
+	chord(KEY_ALT | '<'):            "Move Top",
+	chord(KEY_ALT | '>'):            "Move Bottom",
+	chord(KEY_ALT|'g', 'g'):         "Go to Line",
+	chord(KEY_ALT|'g', KEY_ALT|'g'): "Go to Line",
+	chord(CTRL_D):                   "Delete Forward",
+	chord(CTRL_K):                   "Kill Line",
+	chord(CTRL_W):                   "Kill Region",
+	chord(KEY_ALT | 'w'):            "Copy Region",
+	chord(CTRL_Y):                   "Yank",
+	chord(CTRL_SPACE):               "Set Mark",
+	chord(CTRL_G):                   "Cancel",
+	chord(CTRL_UNDERSCORE):          "Undo",
+	chord(KEY_ALT | 'x'):            "Command Palette",
+	chord(KEY_ALT | ';'):            "Toggle Comment",
+	chord(CTRL_X, CTRL_S):           "Save",
+	chord(CTRL_X, CTRL_F):           "Open File",
+	chord(CTRL_X, CTRL_C):           "Exit",
+	chord(CTRL_X, CTRL_X):           "Exchange Point and Mark",
+	chord(CTRL_X, 'b'):              "Buffer List",
+	chord(CTRL_X, 'k'):              "Close Tab",
+	chord(CTRL_X, 'u'):              "Undo",
+	chord(CTRL_X, 'o'):              "Focus Next Pane",
+	chord(CTRL_X, '0'):              "Close Pane",
+	chord(CTRL_X, '2'):              "Split Down",
+	chord(CTRL_X, '3'):              "Split Right",
+	chord(CTRL_X, KEY_RIGHT):        "Next Tab",
+	chord(CTRL_X, KEY_LEFT):         "Previous Tab",
+	chord(CTRL_X, '('):              "Record Macro",
+	chord(CTRL_X, ')'):              "Record Macro",
+	chord(CTRL_X, 'e'):              "Play Macro",
+}
+
+// 複数のキーの組み合わせのキー列
+func chord(keys ...rune) string {
+	return string(keys)
+}
+
+// キー割り当ての途中までのキー列 (続きの入力を待つプレフィックス)
+func chordPrefixes(keymap map[string]string) map[string]bool {
+	prefixes := make(map[string]bool)
+	for seq := range keymap {
+		keys := []rune(seq)
+		for n := 1; n < len(keys); n++ {
+			prefixes[string(keys[:n])] = true
+		}
+	}
+	return prefixes
+}
+
+var emacsPrefixes = chordPrefixes(emacsKeys)
+
+// マークを設定している間に選択範囲を広げる移動のアクション ("Cursor <名前>"は"Select <名前>")
+var emacsSelectMotions = map[string]string{
+	"Move Top":    "Select to Top",
+	"Move Bottom": "Select to Bottom",
+}
+
+// Emacs風のキー割り当てのレイヤー
+// C-x C-sのようなプレフィックスに続くキー入力に対応し、入力中のプレフィックスをステータスバーに表示する
+// マーク (C-SPC) は選択範囲の開始位置として扱う
+type Emacs struct {
+	keys []rune // 入力中のプレフィックス
+}
+
+// 新しいEmacsのレイヤーの取得
+func NewEmacs() *Emacs {
+	return &Emacs{}
+}
+
+func (m *Emacs) ModeName() string {
+	if len(m.keys) == 0 {
+		return ""
+	}
+	return chordString(m.keys) + "-"
+}
+
+func (m *Emacs) Pending() bool {
+	return len(m.keys) > 0
+}
+
+func (m *Emacs) HandleKey(v *View, r rune) (bool, uint8) {
+	pending := len(m.keys) > 0
+	keys := append(append([]rune{}, m.keys...), r)
+	m.keys = nil
+	if name, ok := emacsKeys[string(keys)]; ok {
+		exitCode := m.run(v, name)
+		if pending {
+			v.UpdateStatusBar()
+		}
+		return true, exitCode
+	}
+	if emacsPrefixes[string(keys)] {
+		m.keys = keys
+		v.UpdateStatusBar()
+		return true, 0
+	}
+	if !pending { // 割り当てのないキーはデフォルトのキー割り当て・文字の入力として処理
+		return false, 0
+	}
+	if r == CTRL_G {
+		v.SetMessage("Quit")
+	} else {
+		v.SetMessage("%s is undefined", chordString(keys))
+	}
+	v.UpdateStatusBar()
+	return true, 0
+}
+
+// アクションの実行 (マークを設定している場合は移動を選択範囲を広げる移動に置き換える)
+func (m *Emacs) run(v *View, name string) uint8 {
+	if v.GetCurrentTab().Anchor != nil {
+		selectName, ok := emacsSelectMotions[name]
+		if rest, found := strings.CutPrefix(name, "Cursor "); found {
+			selectName, ok = "Select "+rest, true
+		}
+		if ok && v.FindAction(selectName) != nil {
+			name = selectName
+		}
+	}
+	return v.RunAction(name)
+}
+
+// Emacsの表記でのキー列の表示文字列 (C-x C-sなど)
+func chordString(keys []rune) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = emacsKeyName(k)
+	}
+	return strings.Join(names, " ")
+}
+
+// Emacsの表記でのキーの名前 (C-x・M-f・C-SPCなど)
+func emacsKeyName(r rune) string {
+	switch {
+	case r == CTRL_SPACE:
+		return "C-SPC"
+	case r == CTRL_UNDERSCORE:
+		return "C-/"
+	case r == SPACE:
+		return "SPC"
+	case r >= CTRL_A && r <= CTRL_Z && r != CTRL_I && r != CTRL_M:
+		return fmt.Sprintf("C-%c", 'a'+r-CTRL_A)
+	case r&KEY_ALT != 0:
+		return "M-" + emacsKeyName(r&^KEY_ALT)
+	}
+	return keyName(r)
+}
@@ -8,40 +8,87 @@ import (
 
 type Event struct {
 	Key chan rune
+	Mouse chan MouseEvent
 	Signal chan os.Signal
 }
 
+// マウスイベント (SGR形式の通知)
+type MouseEvent struct {
+	Button int  // ボタン番号 (MOUSE_LEFTなど)
+	Col    uint // 列 (1始まり)
+	Row    uint // 行 (1始まり)
+	Press  bool // 押した場合はtrue、離した場合はfalse
+	Mod    int  // 同時に押していた修飾キー (MOUSE_SHIFTなどの組み合わせ)
+}
+
+const (
+	MOUSE_LEFT       = 0
+	MOUSE_MIDDLE     = 1
+	MOUSE_RIGHT      = 2
+	MOUSE_WHEEL_UP   = 64
+	MOUSE_WHEEL_DOWN = 65
+
+	// 修飾キーのビット
+	MOUSE_SHIFT = 0x04
+	MOUSE_ALT   = 0x08
+	MOUSE_CTRL  = 0x10
+)
+
 func NewEvent() *Event {
 	e := new(Event)
 	e.Key = make(chan rune, 1)
+	e.Mouse = make(chan MouseEvent, 1)
 	e.Signal = make(chan os.Signal, 1)
 	return e
 }
 
+// シグナルの通知の停止
+// 入力のチャネルは読み取り中のScanInputが送信することがあるため閉じない (ScanInputはexitで終了する)
 func (e *Event) Close() {
-	close(e.Key)
-	close(e.Signal)
+	signal.Stop(e.Signal)
 }
 
 // 入力キーの読み取り
 // TODO: 入力が早すぎる場合にチャネルが閉じる問題を解決する
 func (e *Event) ScanInput(exit <-chan interface{}) error {
-	buf := make([]byte, 8)
+	buf := make([]byte, 64)
+	rest := 0 // 前回の読み取りの末尾の途中までのキー入力の長さ (bufの先頭に移して続きと合わせて解析する)
 	for {
 		select {
 			case <-exit:
 				return nil
 			default:
-				if n, err := os.Stdin.Read(buf); err == nil {
-					b := buf[:n]
+				if n, err := os.Stdin.Read(buf[rest:]); err == nil {
+					b := buf[:rest+n]
+					keep := partialKeyLength(b, len(b) == len(buf))
+					if keep == len(buf) { // バッファに収まらない長さのシーケンスは解析せずに破棄
+						keep = 0
+						b = b[:0]
+					}
+					tail := b[len(b)-keep:]
+					b = b[:len(b)-keep]
 					for {
+						if m, n := parseMouse(b); n > 0 { // マウスイベントは別のチャネルに送る
+							select {
+							case e.Mouse <- m:
+							case <-exit: // メインループの終了後は受け取られないため送信せずに終了
+								return nil
+							}
+							b = b[n:]
+							continue
+						}
 						r, n := parseKey(b)
 						if n == 0 {
 							break
 						}
-						e.Key <- r
+						select {
+						case e.Key <- r:
+						case <-exit:
+							return nil
+						}
 						b = b[n:]
 					}
+					rest = copy(buf, tail)
 				} else {
 					return err
 				}
@@ -51,5 +98,5 @@ func (e *Event) ScanInput(exit <-chan interface{}) error {
 
 // OSシグナルの通知
 func (e *Event) NotifySignal() {
-	signal.Notify(e.Signal, os.Interrupt, syscall.SIGWINCH)
+	signal.Notify(e.Signal, os.Interrupt, syscall.SIGWINCH, syscall.SIGHUP, syscall.SIGTERM)
 }
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+var errExRange = errors.New("E16: Invalid range")
+
+// exコマンドの入力 (initialは入力欄の初期値)
+func (m *Vim) promptEx(v *View, initial string) {
+	v.OpenOverlay(NewPrompt(":", initial, func(v *View, input string) uint8 {
+		return m.runEx(v, input)
+	}))
+}
+
+// exコマンドの実行
+// [範囲]コマンド[!] [引数] (範囲は N・.・$・'<・'>と+N・-N、%は全体、コマンドのない範囲はその行へ移動)
+func (m *Vim) runEx(v *View, input string) uint8 {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return 0
+	}
+	e := v.GetCurrentTab()
+	from, to, hasRange, rest, err := m.exRange(e, input)
+	if err != nil {
+		v.SetMessage("%v", err)
+		return 0
+	}
+	name := rest
+	if i := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+		name = rest[:i]
+	}
+	arg := rest[len(name):]
+	force := strings.HasPrefix(arg, "!")
+	arg = strings.TrimSpace(strings.TrimPrefix(arg, "!"))
+
+	switch name {
+	case "":
+		if !hasRange {
+			break
+		}
+		v.PushJump()
+		e.MoveTargetRow(to)
+		e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+		m.refresh(v)
+	case "w", "write":
+		return m.exWrite(v, arg, nil)
+	case "wq", "x", "xit", "exit":
+		b := e.Buffer
+		if name != "wq" && b.IsSaved && b.FilePath != "" && arg == "" {
+			return m.exQuit(v, force)
+		}
+		return m.exWrite(v, arg, func(v *View) uint8 {
+			return m.exQuit(v, force)
+		})
+	case "q", "quit", "clo", "close":
+		return m.exQuit(v, force)
+	case "qa", "qall", "quita", "quitall":
+		if force {
+			return 1
+		}
+		return v.RunAction("Exit")
+	case "wa", "wall":
+		return v.saveAll(v.dirtyTabs(), nil)
+	case "wqa", "wqall", "xa", "xall":
+		return v.RunAction("Save All and Quit")
+	case "e", "edit":
+		if arg == "" {
+			v.SetMessage("E32: No file name")
+			break
+		}
+		v.OpenFile(expandPath(arg))
+		v.Reflesh()
+	case "s", "substitute":
+		m.exSubstitute(v, from, to, arg)
+	case "d", "delete", "y", "yank":
+		reg := '"'
+		if arg != "" {
+			reg = []rune(arg)[0]
+		}
+		c := &vimCmd{Reg: reg, Start: Cursor{Row: from, Col: 1}, End: Cursor{Row: to, Col: 1}, Type: MOTION_LINEWISE}
+		if name[0] == 'd' {
+			m.opDelete(v, c)
+		} else {
+			m.opYank(v, c)
+		}
+		m.refresh(v)
+	case "sp", "split":
+		return v.RunAction("Split Down")
+	case "vs", "vsplit":
+		return v.RunAction("Split Right")
+	case "bn", "bnext":
+		return v.RunAction("Next Tab")
+	case "bp", "bprevious", "bN", "bNext":
+		return v.RunAction("Previous Tab")
+	case "bd", "bdelete":
+		return v.RunAction("Close Tab")
+	default:
+		v.SetMessage("E492: Not an editor command: %s", input)
+	}
+	return 0
+}
+
+// exコマンドの先頭の範囲の解析 (省略した場合は現在の行)
+func (m *Vim) exRange(e *Editor, input string) (from uint, to uint, hasRange bool, rest string, err error) {
+	cur, last := int(e.Cursor.Row), len(e.Lines)
+	i := 0
+	// 1つの行の指定 (省略した場合はfalse)
+	address := func() (int, bool) {
+		n, ok := cur, true
+		switch {
+		case i >= len(input):
+			return cur, false
+		case input[i] == '.':
+			i++
+		case input[i] == '$':
+			n = last
+			i++
+		case strings.HasPrefix(input[i:], "'<"):
+			n = int(m.visualRows[0])
+			i += 2
+		case strings.HasPrefix(input[i:], "'>"):
+			n = int(m.visualRows[1])
+			i += 2
+		case input[i] >= '0' && input[i] <= '9':
+			j := i
+			for j < len(input) && input[j] >= '0' && input[j] <= '9' {
+				j++
+			}
+			n, _ = strconv.Atoi(input[i:j])
+			i = j
+		default:
+			ok = false
+		}
+		for i < len(input) && (input[i] == '+' || input[i] == '-') { // +N・-N (数を省略した場合は1)
+			sign := 1
+			if input[i] == '-' {
+				sign = -1
+			}
+			i++
+			j := i
+			for j < len(input) && input[j] >= '0' && input[j] <= '9' {
+				j++
+			}
+			delta := 1
+			if j > i {
+				delta, _ = strconv.Atoi(input[i:j])
+			}
+			n += sign * delta
+			i = j
+			ok = true
+		}
+		return n, ok
+	}
+
+	start, end := cur, cur
+	if strings.HasPrefix(input, "%") {
+		start, end, hasRange = 1, last, true
+		i = 1
+	} else if n, ok := address(); ok {
+		start, end, hasRange = n, n, true
+		if i < len(input) && input[i] == ',' {
+			i++
+			if n, ok := address(); ok {
+				end = n
+			}
+		}
+	}
+	if start > end {
+		start, end = end, start
+	}
+	if start < 1 || end > last {
+		return 0, 0, false, "", errExRange
+	}
+	return uint(start), uint(end), hasRange, strings.TrimSpace(input[i:]), nil
+}
+
+// 保存 (:w、引数のパスは無題の場合は保存先とし、それ以外はそのパスへの書き出しとする)
+func (m *Vim) exWrite(v *View, arg string, done func(v *View) uint8) uint8 {
+	b := v.GetCurrentTab().Buffer
+	if arg == "" {
+		return v.Save(b, done)
+	}
+	target := expandPath(arg)
+	if other := v.FindBuffer(target); other != nil && other != b {
+		v.SetMessage("Error: %s is already open in another buffer", target)
+		return 0
+	}
+	if b.FilePath == "" || canonicalPath(target) == canonicalPath(b.FilePath) {
+		v.Buffers.SetFilePath(b, target)
+		return v.Save(b, done)
+	}
+	content := b.Bytes(b.NL)
+	if err := os.WriteFile(target, content, 0644); err != nil {
+		v.SetMessage("Error: %v", err)
+		return 0
+	}
+	v.SetMessage("Written %s (%d bytes)", target, len(content))
+	if done != nil {
+		return done(v)
+	}
+	return 0
+}
+
+// 閉じる (:q、分割している場合はペイン、それ以外はタブを閉じ、最後のタブの場合は終了する)
+func (m *Vim) exQuit(v *View, force bool) uint8 {
+	if len(v.Panes()) > 1 {
+		return v.RunAction("Close Pane")
+	}
+	b := v.GetCurrentTab().Buffer
+	if !force && !b.IsSaved {
+		v.SetMessage("E37: No write since last change (add ! to override)")
+		return 0
+	}
+	if !v.DeleteTab() {
+		return 1
+	}
+	v.Reflesh()
+	return 0
+}
+
+// 置換 (:s/pattern/replacement/flags、flagsはg (行内の全て)・i (大文字と小文字を区別しない))
+// パターンはGoの正規表現で、置換後の文字列の&と\1~\9は一致した文字列・グループに置き換える
+func (m *Vim) exSubstitute(v *View, from uint, to uint, arg string) {
+	if arg == "" {
+		v.SetMessage("E35: No previous regular expression")
+		return
+	}
+	sep, size := []rune(arg)[0], len(string([]rune(arg)[0]))
+	parts := splitEscaped(arg[size:], sep, 3)
+	pattern, replacement, flags := parts[0], "", ""
+	if len(parts) > 1 {
+		replacement = parts[1]
+	}
+	if len(parts) > 2 {
+		flags = parts[2]
+	}
+	if strings.Contains(flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		v.SetMessage("Error: %v", err)
+		return
+	}
+	template := exTemplate(replacement)
+	global := strings.Contains(flags, "g")
+
+	e := v.GetCurrentTab()
+	count, lines, lastRow := 0, 0, uint(0)
+	for row := from; row <= to; row++ {
+		line := string(e.LineRunes(row))
+		matches := re.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		if !global {
+			matches = matches[:1]
+		}
+		var out []byte
+		prev := 0
+		for _, loc := range matches {
+			out = append(out, line[prev:loc[0]]...)
+			out = re.ExpandString(out, template, line, loc)
+			prev = loc[1]
+		}
+		out = append(out, line[prev:]...)
+		if count == 0 {
+			e.SaveUndo()
+		}
+		count += len(matches)
+		lines++
+		lastRow = row
+		e.ReplaceLine(row, []rune(string(out)))
+	}
+	if count == 0 {
+		v.SetMessage("E486: Pattern not found: %s", parts[0])
+		return
+	}
+	e.MoveTargetRow(lastRow)
+	e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+	if lines > 1 || count > 1 {
+		v.SetMessage("%d substitutions on %d lines", count, lines)
+	}
+	m.refresh(v)
+}
+
+// 区切り文字での分割 (最大n個、\に続く区切り文字は区切り文字そのものとし、それ以外の\はそのまま残す)
+func splitEscaped(s string, sep rune, n int) []string {
+	parts := make([]string, 0, n)
+	var cur strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != sep {
+				cur.WriteRune('\\')
+			}
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == sep && len(parts) < n-1:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if escaped {
+		cur.WriteRune('\\')
+	}
+	return append(parts, cur.String())
+}
+
+// Vimの置換後の文字列をregexp.Expandのテンプレートに変換 (&・\0~\9・\&・\\)
+func exTemplate(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			b.WriteString("$$")
+		case c == '&':
+			b.WriteString("${0}")
+		case c == '\\' && i+1 < len(s):
+			i++
+			if s[i] >= '0' && s[i] <= '9' {
+				fmt.Fprintf(&b, "${%c}", s[i])
+			} else {
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const (
+	FINDER_BATCH_SIZE  = 256     // 1回に通知するパスの数
+	FINDER_RESULT_MAX  = 1000    // 保持する候補の最大数
+	FINDER_ITEM_MAX    = 10      // 表示する候補の最大数
+	FINDER_PREVIEW_MAX = 1 << 16 // プレビューで読み込む最大バイト数
+)
+
+// プロジェクト内のファイル一覧構造体
+type FileIndex struct {
+	Root    string        // 走査するディレクトリ
+	Paths   []string      // 走査済みのファイルパス (Rootからの相対パス)
+	Done    bool          // 走査完了フラグ
+	Updates chan []string // 走査中に見つかったパスの通知 (完了時にclose)
+	pending []string      // 再走査中に見つかったパス (完了時にPathsと置き換える、再走査中でなければnil)
+}
+
+// 新しいファイル一覧の取得 (バックグラウンドで走査を開始)
+func NewFileIndex(root string) (idx *FileIndex) {
+	idx = new(FileIndex)
+	idx.Root = root
+	idx.Paths = make([]string, 0)
+	idx.Done = false
+	idx.Updates = make(chan []string, 4)
+	go scanFiles(root, idx.Updates)
+	return
+}
+
+// ディレクトリを再帰的に走査 (.gitと.gitignoreの対象は除外)
+func scanFiles(root string, out chan<- []string) {
+	defer close(out)
+	ignores := make(utils.GitIgnoreList, 0)
+	batch := make([]string, 0, FINDER_BATCH_SIZE)
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." {
+				if d.Name() == ".git" || ignores.IsIgnored(rel, true) {
+					return filepath.SkipDir
+				}
+			}
+			if data, err := os.ReadFile(filepath.Join(p, ".gitignore")); err == nil {
+				ignores = append(ignores, utils.ParseGitIgnore(rel, string(data)))
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || ignores.IsIgnored(rel, false) {
+			return nil
+		}
+		batch = append(batch, rel)
+		if len(batch) >= FINDER_BATCH_SIZE {
+			out <- batch
+			batch = make([]string, 0, FINDER_BATCH_SIZE)
+		}
+		return nil
+	})
+	if len(batch) > 0 {
+		out <- batch
+	}
+}
+
+// 走査済みの一覧を残したままの再走査 (走査中の場合は何もしない)
+func (idx *FileIndex) Rescan() {
+	if !idx.Done {
+		return
+	}
+	idx.Done = false
+	idx.pending = make([]string, 0, len(idx.Paths))
+	idx.Updates = make(chan []string, 4)
+	go scanFiles(idx.Root, idx.Updates)
+}
+
+// 走査結果の受け取り
+// 一覧に追加したパスを返し、再走査が完了して一覧を置き換えた場合はreplaced=true
+func (idx *FileIndex) Receive(paths []string, ok bool) (added []string, replaced bool) {
+	if !ok {
+		idx.Done = true
+		idx.Updates = nil
+		if idx.pending != nil {
+			idx.Paths, idx.pending = idx.pending, nil
+			return nil, true
+		}
+		return nil, false
+	}
+	if idx.pending != nil {
+		idx.pending = append(idx.pending, paths...)
+		return nil, false
+	}
+	idx.Paths = append(idx.Paths, paths...)
+	return paths, false
+}
+
+// ファイル検索の候補
+type finderItem struct {
+	Path      string
+	Score     int
+	Positions []int
+}
+
+// ファイル検索構造体
+type Finder struct {
+	Query       []rune
+	Items       []finderItem // 絞り込み後の候補 (スコア順)
+	Selected    int
+	matched     [][]string   // matched[i]はQuery[:i+1]に一致するパス (入力した文字では前回の一致から絞り込む)
+	preview     []string // 選択中の候補のプレビュー
+	previewPath string
+}
+
+// 新しいファイル検索の取得
+func NewFinder(v *View) (f *Finder) {
+	f = new(Finder)
+	f.Query = make([]rune, 0)
+	f.refresh(v)
+	return
+}
+
+// パスの一致度の計算 (ファイル名部分での一致を優先)
+func (f *Finder) score(p string) (item finderItem, ok bool) {
+	text := []rune(p)
+	score, positions, ok := core.FuzzyMatch(f.Query, text)
+	if !ok {
+		return
+	}
+	base := len([]rune(p)) - len([]rune(path.Base(p)))
+	for _, pos := range positions {
+		if pos >= base {
+			score += 4
+		}
+	}
+	return finderItem{p, score, positions}, true
+}
+
+// 候補の並び替えと件数の制限
+func (f *Finder) sortItems() {
+	sort.SliceStable(f.Items, func(i, j int) bool {
+		if f.Items[i].Score != f.Items[j].Score {
+			return f.Items[i].Score > f.Items[j].Score
+		}
+		return len(f.Items[i].Path) < len(f.Items[j].Path)
+	})
+	if len(f.Items) > FINDER_RESULT_MAX {
+		f.Items = f.Items[:FINDER_RESULT_MAX]
+	}
+}
+
+// 一致するパスのみを抽出 (スコアは求めない)
+func matchPaths(query []rune, paths []string) []string {
+	matched := make([]string, 0)
+	for _, p := range paths {
+		if core.FuzzyContains(query, p) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+// 現在の入力に一致するパス
+func (f *Finder) candidates(v *View) []string {
+	if n := len(f.matched); n > 0 {
+		return f.matched[n-1]
+	}
+	return v.Files.Paths
+}
+
+// 入力した文字で前回の一致から絞り込み
+func (f *Finder) narrow(v *View) {
+	f.matched = append(f.matched, matchPaths(f.Query, f.candidates(v)))
+	f.rank(v)
+}
+
+// 削除した文字の分だけ前の一致に戻す
+func (f *Finder) widen(v *View) {
+	f.matched = f.matched[:len(f.Query)]
+	f.rank(v)
+}
+
+// 全てのパスから絞り込み直す (一覧を置き換えた場合)
+func (f *Finder) refresh(v *View) {
+	f.matched = make([][]string, 0, len(f.Query))
+	for i := range f.Query {
+		f.matched = append(f.matched, matchPaths(f.Query[:i+1], f.candidates(v)))
+	}
+	f.rank(v)
+}
+
+// 一致したパスのスコアを求めて候補にする
+func (f *Finder) rank(v *View) {
+	f.Items = make([]finderItem, 0)
+	f.addItems(f.candidates(v))
+	f.Selected = 0
+}
+
+// パスのスコアを求めて候補に追加
+func (f *Finder) addItems(paths []string) {
+	for _, p := range paths {
+		if item, ok := f.score(p); ok {
+			f.Items = append(f.Items, item)
+		}
+	}
+	f.sortItems()
+}
+
+// 新しく見つかったパスを各段階の一致と候補に追加
+func (f *Finder) addPaths(paths []string) {
+	for i := range f.matched {
+		paths = matchPaths(f.Query[:i+1], paths)
+		f.matched[i] = append(f.matched[i], paths...)
+	}
+	f.addItems(paths)
+}
+
+// 選択中の候補のプレビューを読み込み
+func (f *Finder) loadPreview(v *View, maxRows int) {
+	if len(f.Items) == 0 {
+		f.preview, f.previewPath = nil, ""
+		return
+	}
+	p := f.Items[f.Selected].Path
+	if p == f.previewPath {
+		return
+	}
+	f.previewPath = p
+	f.preview = make([]string, 0, maxRows)
+
+	fp, err := os.Open(filepath.Join(v.Files.Root, p))
+	if err != nil {
+		f.preview = append(f.preview, err.Error())
+		return
+	}
+	defer fp.Close()
+	buf := make([]byte, FINDER_PREVIEW_MAX)
+	n, _ := fp.Read(buf)
+	buf = buf[:n]
+	if bytes.IndexByte(buf, 0) >= 0 {
+		f.preview = append(f.preview, "(binary file)")
+		return
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() && len(f.preview) < maxRows {
+		line := strings.ReplaceAll(scanner.Text(), "\t", "    ")
+		line = strings.Map(func(r rune) rune {
+			if r < SPACE {
+				return -1
+			}
+			return r
+		}, line)
+		f.preview = append(f.preview, line)
+	}
+}
+
+// 表示領域 (左端の列・上端の行・幅)
+func (f *Finder) bounds(v *View) (left uint, top uint, width uint) {
+	return 3, 2, uint(max(int(v.WinCol)-4, 1)) // 狭い端末でも1文字分は確保
+}
+
+// 候補一覧の行数
+func (f *Finder) listRows(v *View) int {
+	n := FINDER_ITEM_MAX
+	if int(v.WinRow)/2-3 < n {
+		n = int(v.WinRow)/2 - 3
+	}
+	return n
+}
+
+// 表示可能な候補数
+func (f *Finder) visibleItems(v *View) int {
+	n := f.listRows(v)
+	if len(f.Items) < n {
+		n = len(f.Items)
+	}
+	return n
+}
+
+func (f *Finder) Draw(v *View) {
+	if v.suspended {
+		return
+	}
+	defer v.Term.ResetStyle()
+	left, top, width := f.bounds(v)
+
+	// 入力行
+	status := fmt.Sprintf("%d/%d", len(f.Items), len(v.Files.Paths))
+	if !v.Files.Done {
+		status += " (scanning...)"
+	}
+	v.Term.MoveCursorPos(left, top)
+	v.Term.SetBGColor(237)
+	input := fmt.Sprintf(" Open: %s", string(f.Query))
+	fmt.Print(padRight(input, int(width)-len(status)-1))
+	v.Term.SetColor(245)
+	fmt.Printf("%s ", status)
+
+	// 候補の一覧
+	n := f.visibleItems(v)
+	offset := 0
+	if f.Selected >= n {
+		offset = f.Selected - n + 1
+	}
+	row := top + 1
+	for i := 0; i < f.listRows(v); i++ {
+		v.Term.MoveCursorPos(left, row)
+		v.Term.ResetStyle()
+		bg := uint8(235)
+		if i < n && offset+i == f.Selected {
+			bg = 25
+		}
+		v.Term.SetBGColor(bg)
+		if i < n {
+			item := f.Items[offset+i]
+			fmt.Print(" ")
+			drawMatched(v, []rune(padRight(item.Path, int(width)-2)), item.Positions, bg)
+			fmt.Print(" ")
+		} else {
+			fmt.Print(padRight("", int(width)))
+		}
+		row++
+	}
+
+	// 選択中の候補のプレビュー
+	v.Term.ResetStyle()
+	v.Term.MoveCursorPos(left, row)
+	v.Term.SetBGColor(237)
+	v.Term.SetColor(245)
+	fmt.Print(padRight(" Preview", int(width)))
+	row++
+	maxRows := int(v.WinRow) - int(row) - 1
+	f.loadPreview(v, maxRows)
+	for i := 0; i < maxRows; i++ {
+		v.Term.ResetStyle()
+		v.Term.MoveCursorPos(left, row+uint(i))
+		v.Term.SetBGColor(233)
+		line := ""
+		if i < len(f.preview) {
+			line = f.preview[i]
+		}
+		fmt.Printf(" %s", padRight(line, int(width)-1))
+	}
+}
+
+func (f *Finder) CursorPos(v *View) (col uint, row uint) {
+	left, top, _ := f.bounds(v)
+	return left + 7 + uint(len(f.Query)), top
+}
+
+func (f *Finder) HandleKey(v *View, r rune) uint8 {
+	switch r {
+	case ESC: // Close
+		v.CloseOverlay()
+		return 0
+	case CTRL_M: // Open
+		if len(f.Items) == 0 {
+			return 0
+		}
+		p := filepath.Join(v.Files.Root, f.Items[f.Selected].Path)
+		v.CloseOverlay()
+		v.OpenFile(p)
+		return 0
+	case KEY_UP, CTRL_P:
+		if f.Selected > 0 {
+			f.Selected--
+		}
+	case KEY_DOWN, CTRL_N:
+		if f.Selected < len(f.Items)-1 {
+			f.Selected++
+		}
+	case BACKSPACE:
+		if len(f.Query) == 0 {
+			return 0
+		}
+		f.Query = f.Query[:len(f.Query)-1]
+		f.widen(v)
+	default:
+		if !isInsertable(r) {
+			return 0
+		}
+		f.Query = append(f.Query, r)
+		f.narrow(v)
+	}
+	f.Draw(v)
+	v.RefleshCursor()
+	return 0
+}
+
+// バックグラウンドで見つかったパスを反映 (replacedの場合は再走査した一覧から絞り込み直す)
+func (f *Finder) Update(v *View, paths []string, replaced bool) {
+	if replaced {
+		f.refresh(v)
+	} else {
+		f.addPaths(paths)
+	}
+	f.Draw(v)
+	v.RefleshCursor()
+}
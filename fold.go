@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+const FOLD_MARKER = '▸' // 閉じている折りたたみ範囲の行番号の横に表示する記号
+
+// バッファの折りたたみ範囲 (行は1始まり、編集後に最初に参照した時点で求め直す)
+func (b *Buffer) FoldRanges() []core.FoldRange {
+	if b.foldsStale {
+		b.foldRanges = b.computeFoldRanges()
+		b.foldsStale = false
+		b.pruneFolds()
+	}
+	return b.foldRanges
+}
+
+// 折りたたみ範囲の計算 (言語に合わせてインデントまたは括弧の対応から求める)
+func (b *Buffer) computeFoldRanges() []core.FoldRange {
+	lang, _ := languageOf(b.FilePath)
+	lineAt := func(r int) []rune { return b.Lines[r].GetAll() }
+	var folds []core.FoldRange
+	if lang.Fold == FOLD_SYNTAX {
+		folds = core.BracketFolds(lineAt, b.tokenKinds(), len(b.Lines))
+	} else {
+		folds = core.IndentFolds(lineAt, len(b.Lines))
+	}
+	for i := range folds {
+		folds[i].Start++
+		folds[i].End++
+	}
+	return folds
+}
+
+// 編集により範囲の開始行でなくなった行を閉じている範囲から取り除く
+func (b *Buffer) pruneFolds() {
+	if len(b.folds) == 0 {
+		return
+	}
+	valid := make(map[uint]bool, len(b.foldRanges))
+	for _, f := range b.foldRanges {
+		valid[uint(f.Start)] = true
+	}
+	for row := range b.folds {
+		if !valid[row] {
+			delete(b.folds, row)
+		}
+	}
+}
+
+// 閉じている折りたたみ範囲 (他の閉じている範囲に含まれるものを除く、開始行の順)
+func (b *Buffer) closedFolds() []core.FoldRange {
+	if len(b.folds) == 0 {
+		return nil
+	}
+	closed := make([]core.FoldRange, 0)
+	for _, f := range b.FoldRanges() {
+		if !b.folds[uint(f.Start)] {
+			continue
+		}
+		if n := len(closed); n > 0 && f.Start <= closed[n-1].End {
+			continue
+		}
+		closed = append(closed, f)
+	}
+	return closed
+}
+
+// 指定した行を含む閉じている折りたたみ範囲
+func foldAt(folds []core.FoldRange, row uint) (core.FoldRange, bool) {
+	i := sort.Search(len(folds), func(i int) bool { return folds[i].End >= int(row) })
+	if i < len(folds) && folds[i].Start <= int(row) {
+		return folds[i], true
+	}
+	return core.FoldRange{}, false
+}
+
+// 次に表示される行 (閉じている範囲は最後の行の次、ファイルの末尾より後も1行ずつ進む)
+func nextVisibleRow(folds []core.FoldRange, row uint) uint {
+	if f, ok := foldAt(folds, row); ok {
+		return uint(f.End) + 1
+	}
+	return row + 1
+}
+
+// 前に表示される行 (閉じている範囲は最初の行)
+func prevVisibleRow(folds []core.FoldRange, row uint) uint {
+	if row <= 1 {
+		return 1
+	}
+	if f, ok := foldAt(folds, row-1); ok {
+		return uint(f.Start)
+	}
+	return row - 1
+}
+
+// 表示される行 (閉じている範囲内の行は範囲の最初の行)
+func visibleRow(folds []core.FoldRange, row uint) uint {
+	if f, ok := foldAt(folds, row); ok {
+		return uint(f.Start)
+	}
+	return row
+}
+
+// 表示される行単位でn行移動した行 (バッファの範囲内に収める)
+func (b *Buffer) stepVisible(row uint, n int) uint {
+	folds := b.closedFolds()
+	row = visibleRow(folds, row)
+	for ; n > 0; n-- {
+		next := nextVisibleRow(folds, row)
+		if next > uint(len(b.Lines)) {
+			break
+		}
+		row = next
+	}
+	for ; n < 0 && row > 1; n++ {
+		row = prevVisibleRow(folds, row)
+	}
+	return row
+}
+
+// 表示上の行数 [from, to) (閉じている範囲は1行として数える)
+func visibleCount(folds []core.FoldRange, from uint, to uint) int {
+	count := 0
+	for row := visibleRow(folds, from); row < to; row = nextVisibleRow(folds, row) {
+		count++
+	}
+	return count
+}
+
+// 行の画面上の位置 (スクロール位置からの表示上の行数)
+func (e *Editor) screenOffset(folds []core.FoldRange, row uint) int {
+	if len(folds) == 0 {
+		return int(row) - int(e.ScrollRow)
+	}
+	if row < e.ScrollRow {
+		return -visibleCount(folds, row, e.ScrollRow)
+	}
+	return visibleCount(folds, e.ScrollRow, visibleRow(folds, row))
+}
+
+// 画面上の位置にある行 (スクロール位置からの表示上の行数、バッファの範囲内に収める)
+func (e *Editor) rowAtScreen(offset int) uint {
+	return e.stepVisible(e.ScrollRow, offset)
+}
+
+// 指定した行を隠している閉じている範囲を開く (開いた場合はtrue)
+func (b *Buffer) revealRow(row uint) bool {
+	opened := false
+	for {
+		f, ok := foldAt(b.closedFolds(), row)
+		if !ok || f.Start == int(row) {
+			return opened
+		}
+		delete(b.folds, uint(f.Start))
+		opened = true
+	}
+}
+
+// 編集で範囲が変わりカーソルが隠れた場合は範囲を開いて再描画
+func (v *View) revealCursor() {
+	if cTab := v.GetCurrentTab(); cTab.revealRow(cTab.Cursor.Row) {
+		v.Reflesh()
+	}
+}
+
+// カーソルを含む閉じている範囲の最初の行へ移動
+func (e *Editor) moveOutOfFolds() {
+	if f, ok := foldAt(e.closedFolds(), e.Cursor.Row); ok && f.Start != int(e.Cursor.Row) {
+		e.MoveTargetRow(uint(f.Start))
+	}
+}
+
+// 行の挿入・削除に合わせた閉じている範囲の開始行の移動 (rowより後の行をdelta行ずらし、削除した行から始まる範囲は開く)
+func (b *Buffer) shiftFolds(row uint, delta int) {
+	if len(b.folds) == 0 {
+		return
+	}
+	folds := make(map[uint]bool, len(b.folds))
+	for start := range b.folds {
+		switch {
+		case start < row:
+			folds[start] = true
+		case delta < 0 && start == row:
+		default:
+			folds[uint(int(start)+delta)] = true
+		}
+	}
+	b.folds = folds
+}
+
+// カーソル位置の範囲を閉じる (閉じている範囲にいる場合はそれを囲む範囲を閉じる、閉じた場合はtrue)
+func (e *Editor) CloseFold() bool {
+	row := int(e.Cursor.Row)
+	var target *core.FoldRange
+	for _, f := range e.FoldRanges() {
+		if f.Start <= row && row <= f.End && !e.folds[uint(f.Start)] && (target == nil || f.Level > target.Level) {
+			f := f
+			target = &f
+		}
+	}
+	if target == nil {
+		return false
+	}
+	if e.folds == nil {
+		e.folds = make(map[uint]bool)
+	}
+	e.folds[uint(target.Start)] = true
+	e.moveOutOfFolds()
+	return true
+}
+
+// カーソル位置の閉じている範囲を開く (開いた場合はtrue)
+func (e *Editor) OpenFold() bool {
+	f, ok := foldAt(e.closedFolds(), e.Cursor.Row)
+	if !ok {
+		return false
+	}
+	delete(e.folds, uint(f.Start))
+	return true
+}
+
+// 入れ子の深さがlevelより深い範囲を閉じ、それ以外の範囲を開く (0の場合は全て閉じる)
+func (e *Editor) FoldToLevel(level int) {
+	e.folds = make(map[uint]bool)
+	for _, f := range e.FoldRanges() {
+		if f.Level > level {
+			e.folds[uint(f.Start)] = true
+		}
+	}
+	e.moveOutOfFolds()
+}
+
+// 全ての範囲を開く
+func (e *Editor) OpenAllFolds() {
+	e.folds = nil
+}
+
+// 閉じている範囲の1行の表示 (最初の行の後ろに隠している行数を付ける)
+func foldSummary(f core.FoldRange) string {
+	if f.End-f.Start == 1 {
+		return " ··· 1 line "
+	}
+	return fmt.Sprintf(" ··· %d lines ", f.End-f.Start)
+}
+
+// 折りたたむ深さの入力 (0で全て閉じる)
+func (v *View) promptFoldLevel() {
+	v.OpenOverlay(NewPrompt("Fold to level (0-): ", "", func(v *View, input string) uint8 {
+		if input == "" {
+			return 0
+		}
+		level, err := strconv.Atoi(input)
+		if err != nil || level < 0 {
+			v.SetMessage("Error: invalid level %q", input)
+			return 0
+		}
+		v.GetCurrentTab().FoldToLevel(level)
+		v.ScrollToCursor()
+		v.Reflesh()
+		return 0
+	}))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+// インデントの1段階の幅 (タブは読み込み時にスペースに変換するため、タブサイズ分のスペース)
+func (b *Buffer) indentUnit() int {
+	return max(int(b.TabSize), 1)
+}
+
+// 行末にある場合に次の行のインデントを深くする文字 (ファイルの言語で決まる)
+func (b *Buffer) indentOpeners() string {
+	lang, _ := languageOf(b.FilePath)
+	return lang.Openers
+}
+
+// 行のインデントを指定した幅のスペースに置き換え (インデントの文字数の増減を返す)
+func (b *Buffer) SetIndent(row uint, width int) int {
+	line := b.LineRunes(row)
+	indent := core.FirstNonBlank(line)
+	if indent == width {
+		return 0
+	}
+	b.ReplaceLine(row, append([]rune(strings.Repeat(" ", width)), line[indent:]...))
+	return width - indent
+}
+
+// インデントの変更に合わせた位置の調整 (インデント内の位置は新しいインデントの末尾までに収める)
+func shiftPos(pos *Cursor, row uint, indent int, delta int) {
+	if pos == nil || pos.Row != row || delta == 0 {
+		return
+	}
+	if int(pos.Col) > indent {
+		pos.Col = uint(max(int(pos.Col)+delta, 1))
+	} else {
+		pos.Col = uint(min(int(pos.Col), indent+delta+1))
+	}
+	pos.Want = 0
+}
+
+// 行の範囲のインデントを段数分増減 (インデントの幅に揃え、空の行は変更しない)
+func (e *Editor) IndentLines(top uint, bottom uint, levels int) {
+	unit := e.indentUnit()
+	for row := top; row <= bottom; row++ {
+		line := e.LineRunes(row)
+		if len(line) == 0 {
+			continue
+		}
+		indent := core.FirstNonBlank(line)
+		delta := e.SetIndent(row, core.ShiftIndent(indent, unit, levels))
+		shiftPos(e.Cursor, row, indent, delta)
+		shiftPos(e.Anchor, row, indent, delta)
+	}
+}
+
+// 選択範囲の行 (選択していない場合はカーソルの行、次の行の先頭までの選択は前の行まで)
+func (e *Editor) selectedRows() (top uint, bottom uint) {
+	start, end, ok := e.Selection()
+	if !ok {
+		return e.Cursor.Row, e.Cursor.Row
+	}
+	if e.SelMode != SELECT_BLOCK && end.Col == 1 && end.Row > start.Row {
+		end.Row--
+	}
+	return start.Row, end.Row
+}
+
+// カーソル位置での改行 (次の行は前の行のインデントを引き継ぎ、言語の開き括弧などの後では1段階深くする)
+// 開き括弧と閉じ括弧の間での改行は閉じ括弧を元のインデントの行に移す
+func (e *Editor) NewlineIndented() {
+	row := e.Cursor.Row
+	line := e.currentLine()
+	col := min(int(e.Cursor.Col-1), len(line))
+	before, after := line[:col], line[col:]
+	after = after[core.FirstNonBlank(after):]
+	width, opened := core.NextIndent(before, e.indentOpeners(), e.indentUnit())
+	lines := []string{strings.Repeat(" ", width) + string(after)}
+	if opened && len(after) > 0 && core.IsClosingBracket(after[0]) {
+		indent := strings.Repeat(" ", core.FirstNonBlank(before))
+		lines = []string{strings.Repeat(" ", width), indent + string(after)}
+	}
+	end := len(before) // 改行する位置の前の空白は取り除く
+	for end > 0 && core.ClassOf(before[end-1]) == core.CHAR_SPACE {
+		end--
+	}
+	e.ReplaceLine(row, append([]rune{}, before[:end]...))
+	e.InsertLines(row+1, lines)
+	e.MoveTargetRow(row + 1)
+	e.MoveTargetCol(uint(width) + 1)
+}
+
+// 行頭に入力した閉じ括弧のインデントを対応する開き括弧の行に揃える (変更した場合はtrue)
+func (e *Editor) dedentClosing() bool {
+	line := e.currentLine()
+	idx := int(e.Cursor.Col) - 2
+	if idx < 0 || idx >= len(line) || !core.IsClosingBracket(line[idx]) || core.FirstNonBlank(line) != idx {
+		return false
+	}
+	row, _, ok := e.matchBracket(int(e.Cursor.Row-1), idx)
+	if !ok || uint(row)+1 == e.Cursor.Row {
+		return false
+	}
+	delta := e.SetIndent(e.Cursor.Row, core.FirstNonBlank(e.LineRunes(uint(row)+1)))
+	e.Cursor.Col = uint(int(e.Cursor.Col) + delta)
+	return delta != 0
+}
+
+// 次のタブ位置までのスペースの入力
+func (v *View) insertSoftTab() {
+	e := v.GetCurrentTab()
+	unit := e.indentUnit()
+	for n := unit - (int(e.DisplayCol())-1)%unit; n > 0; n-- {
+		v.InsertRune(SPACE)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const JUMP_LIST_MAX = 100 // ジャンプリストに記録する最大数
+
+// ジャンプリストに記録する位置
+type JumpPos struct {
+	Buffer *Buffer
+	Row    uint
+	Col    uint
+}
+
+// 現在のペインのカーソル位置
+func (v *View) currentPos() JumpPos {
+	e := v.GetCurrentTab()
+	return JumpPos{e.Buffer, e.Cursor.Row, e.Cursor.Col}
+}
+
+// 大きな移動の前に現在の位置をジャンプリストに記録 (戻った後の位置より先の記録は破棄する)
+func (v *View) PushJump() {
+	pos := v.currentPos()
+	v.Jumps = v.Jumps[:v.JumpIdx]
+	if n := len(v.Jumps); n > 0 && v.Jumps[n-1].Buffer == pos.Buffer && v.Jumps[n-1].Row == pos.Row {
+		v.Jumps[n-1] = pos // 同じ行の記録は最新の位置で置き換える
+	} else {
+		v.Jumps = append(v.Jumps, pos)
+	}
+	if len(v.Jumps) > JUMP_LIST_MAX {
+		v.Jumps = v.Jumps[len(v.Jumps)-JUMP_LIST_MAX:]
+	}
+	v.JumpIdx = len(v.Jumps)
+}
+
+// 閉じたバッファの位置をジャンプリストから削除
+func (v *View) forgetJumps(b *Buffer) {
+	jumps := v.Jumps[:0]
+	idx := v.JumpIdx
+	for i, pos := range v.Jumps {
+		if pos.Buffer == b {
+			if i < v.JumpIdx {
+				idx--
+			}
+			continue
+		}
+		jumps = append(jumps, pos)
+	}
+	v.Jumps = jumps
+	v.JumpIdx = idx
+}
+
+// ジャンプリストの前の位置に戻る (最新の位置から戻る場合は現在の位置を記録して進めるようにする)
+func (v *View) JumpBack() bool {
+	if len(v.Jumps) == 0 {
+		return false
+	}
+	if v.JumpIdx == len(v.Jumps) {
+		v.PushJump()
+		v.JumpIdx--
+	}
+	if v.JumpIdx == 0 {
+		return false
+	}
+	v.JumpIdx--
+	v.jumpTo(v.Jumps[v.JumpIdx])
+	return true
+}
+
+// ジャンプリストの次の位置に進む
+func (v *View) JumpForward() bool {
+	if v.JumpIdx >= len(v.Jumps)-1 {
+		return false
+	}
+	v.JumpIdx++
+	v.jumpTo(v.Jumps[v.JumpIdx])
+	return true
+}
+
+// 記録した位置へ移動 (別のタブの場合はタブを切り替える)
+func (v *View) jumpTo(pos JumpPos) {
+	if pos.Buffer != v.GetCurrentTab().Buffer {
+		v.AutosaveTabSwitch()
+		v.focusTab(pos.Buffer)
+	}
+	e := v.GetCurrentTab()
+	e.Cursor.Row = pos.Row
+	e.MoveTargetCol(pos.Col)
+	e.clampCursor()
+	v.ScrollToCursor()
+	v.Reflesh()
+}
+
+// 指定した行・列へ移動し、行を画面の中央に表示する (列が0の場合は行頭)
+func (v *View) GotoPos(row uint, col uint) {
+	e := v.GetCurrentTab()
+	e.MoveTargetRow(row)
+	if col == 0 {
+		col = 1
+	}
+	e.MoveTargetCol(col)
+	e.clampCursor()
+	v.CenterCursor()
+}
+
+// カーソル行を現在のペインの中央に表示
+func (v *View) CenterCursor() {
+	e := v.GetCurrentTab()
+	half := v.Focus.TextHeight() / 2
+	if e.Cursor.Row > half {
+		e.ScrollTargetRow(e.Cursor.Row - half)
+	} else {
+		e.ScrollHead()
+	}
+}
+
+// 移動先の入力 (行, 行:列, +N / -N, N%)
+func (v *View) promptGoto() {
+	e := v.GetCurrentTab()
+	label := fmt.Sprintf("Go to (1-%d, line:col, +N, -N, N%%): ", len(e.Lines))
+	v.OpenOverlay(NewPrompt(label, "", func(v *View, input string) uint8 {
+		if input == "" {
+			return 0
+		}
+		e := v.GetCurrentTab()
+		row, col, err := utils.ParseGoto(input, int(e.Cursor.Row), len(e.Lines))
+		if err != nil {
+			v.SetMessage("Error: %v", err)
+			return 0
+		}
+		v.PushJump()
+		v.GotoPos(uint(row), uint(col))
+		v.Reflesh()
+		return 0
+	}))
+}
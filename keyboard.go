@@ -1,186 +1,577 @@
-package main
-
-import (
-	"fmt"
-	"path/filepath"
-	"unicode/utf8"
-)
-
-const (
-	CTRL_A = iota + 1
-	CTRL_B
-	CTRL_C
-	CTRL_D
-	CTRL_E
-	CTRL_F
-	CTRL_G
-	CTRL_H
-	CTRL_I
-	CTRL_J
-	CTRL_K
-	CTRL_L
-	CTRL_M
-	CTRL_N
-	CTRL_O
-	CTRL_P
-	CTRL_Q
-	CTRL_R
-	CTRL_S
-	CTRL_T
-	CTRL_U
-	CTRL_V
-	CTRL_W
-	CTRL_X
-	CTRL_Y
-	CTRL_Z
-	ESC
-)
-
-const (
-	SPACE     = 32
-	BACKSPACE = 127
-	KEY_UP    = 10001
-	KEY_DOWN  = 10002
-	KEY_RIGHT = 10003
-	KEY_LEFT  = 10004
-)
-
-func parseKey(b []byte) (rune, int) {
-	if len(b) == 3 {
-		if b[0] == byte(27) && b[1] == '[' {
-			switch b[2] {
-			case 'A':
-				return KEY_UP, 3
-			case 'B':
-				return KEY_DOWN, 3
-			case 'C':
-				return KEY_RIGHT, 3
-			case 'D':
-				return KEY_LEFT, 3
-			default:
-				return -1, 0
-			}
-		}
-	}
-	return utf8.DecodeRune(b)
-}
-
-func (v *View) processInput(r rune) uint8 {
-	cTab := v.GetCurrentTab() // Current Tab
-	v.Term.DisableCursor()
-	defer v.Term.EnableCursor()
-	switch r {
-	case CTRL_A:
-	case CTRL_B:
-	case CTRL_C: // Copy
-	case CTRL_D:
-	case CTRL_E:
-	case CTRL_F:
-	case CTRL_G:
-	case CTRL_H:
-	case CTRL_I:
-	case CTRL_J:
-	case CTRL_K:
-	case CTRL_L:
-	case CTRL_M: // Enter
-		if !cTab.IsLastRow() {
-			v.ScrollDown()
-		}
-		cTab.InsertLine(uint(cTab.Cursor.Row))
-		tmp := cTab.Lines[cTab.Cursor.Row-1].GetFrom(int(cTab.Cursor.Col-1), cTab.Lines[cTab.Cursor.Row-1].Length())
-		cTab.Lines[cTab.Cursor.Row-1].EraseFrom(int(cTab.Cursor.Col-1), cTab.Lines[cTab.Cursor.Row-1].Length())
-		cTab.MoveNextRow()
-		cTab.MoveHeadCol()
-		cTab.Lines[cTab.Cursor.Row-1].AppendAll(tmp)
-		v.Reflesh()
-	case CTRL_N:
-	case CTRL_O: // Move Top
-		cTab.MoveHeadRow()
-		cTab.ScrollHead()
-		v.RefleshTextField()
-	case CTRL_P: // Move Bottom
-		cTab.MoveTailRow()
-		cTab.ScrollTail()
-		v.RefleshTextField()
-	case CTRL_Q:
-	case CTRL_R: // Prev Tab
-		v.PrevTab()
-		v.Reflesh()
-	case CTRL_S: // Save
-		_ = cTab.SaveNew(fmt.Sprintf("./bin/%s.bak", filepath.Base(cTab.FilePath)), cTab.NL)
-		cTab.IsSaved = true
-		v.UpdateTabBar()
-	case CTRL_T: // Next Tab
-		v.NextTab()
-		v.Reflesh()
-	case CTRL_U:
-	case CTRL_V: // Paste
-	case CTRL_W:
-	case CTRL_X: // Exit
-		return 1
-	case CTRL_Y: // Delete Tab
-		if !v.DeleteTab() {
-			return 1
-		}
-		v.Reflesh()
-	case CTRL_Z: // Comment Out
-	case ESC:
-		return 1
-	case SPACE: // Space
-		cTab.IsSaved = false
-		cTab.Lines[cTab.Cursor.Row-1].Insert(int(cTab.Cursor.Col-1), rune(' '))
-		cTab.MoveNextCol()
-		v.RefleshTargetRow(cTab.Cursor.Row)
-		v.RefleshCursor()
-		v.UpdateTabBar()
-	case BACKSPACE: // Backspace
-		if !cTab.IsFirstRow() {
-			v.ScrollUp()
-		}
-		if !cTab.Lines[cTab.Cursor.Row-1].IsEmpty() && cTab.Cursor.Col != 1 { // 行に何か入力されている場合
-			cTab.IsSaved = false
-			cTab.Lines[cTab.Cursor.Row-1].Erase(int(cTab.Cursor.Col-2))
-			cTab.MovePrevCol()
-			v.RefleshTargetRow(cTab.Cursor.Row)
-			v.RefleshCursor()
-			v.UpdateTabBar()
-		} else { // 行に何も入力されていない場合
-			tmp := cTab.Lines[cTab.Cursor.Row-1].GetAll()
-			cTab.DeleteLine(uint(cTab.Cursor.Row-1))
-			cTab.MovePrevRow()
-			cTab.MoveTailCol()
-			cTab.Lines[cTab.Cursor.Row-1].AppendAll(tmp)
-			v.Reflesh()
-		}
-	case KEY_UP: // Scroll Up
-		if !cTab.IsFirstRow() {
-			cTab.MovePrevRow()
-			v.ScrollUp()
-		}
-	case KEY_DOWN: // Scroll Down
-		if !cTab.IsLastRow() {
-			cTab.MoveNextRow()
-			v.ScrollDown()
-		}
-	case KEY_RIGHT:
-		if !cTab.IsLastCol() {
-			cTab.MoveNextCol()
-			v.RefleshCursor()
-			v.UpdateStatusBar()
-		}
-	case KEY_LEFT:
-		if !cTab.IsFirstCol() {
-			cTab.MovePrevCol()
-			v.RefleshCursor()
-			v.UpdateStatusBar()
-		}
-	default:
-		cTab.IsSaved = false
-		cTab.Lines[cTab.Cursor.Row-1].Insert(int(cTab.Cursor.Col-1), r)
-		cTab.MoveNextCol()
-		v.RefleshTargetRow(cTab.Cursor.Row)
-		v.RefleshCursor()
-		v.UpdateTabBar()
-	}
-	return 0
-}
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+const PASTE_TIMEOUT = 2 * time.Second // 貼り付けの終了の通知を待つ時間 (届かない場合はそこまでの入力で貼り付けを終える)
+
+const (
+	CTRL_A = iota + 1
+	CTRL_B
+	CTRL_C
+	CTRL_D
+	CTRL_E
+	CTRL_F
+	CTRL_G
+	CTRL_H
+	CTRL_I
+	CTRL_J
+	CTRL_K
+	CTRL_L
+	CTRL_M
+	CTRL_N
+	CTRL_O
+	CTRL_P
+	CTRL_Q
+	CTRL_R
+	CTRL_S
+	CTRL_T
+	CTRL_U
+	CTRL_V
+	CTRL_W
+	CTRL_X
+	CTRL_Y
+	CTRL_Z
+	ESC
+	CTRL_BACKSLASH
+	CTRL_BRACKET    // Ctrl+]
+	CTRL_CARET      // Ctrl+^
+	CTRL_UNDERSCORE // Ctrl+_, Ctrl+/
+)
+
+const (
+	CTRL_SPACE = 0 // Ctrl+Space, Ctrl+@
+	SPACE     = 32
+	BACKSPACE = 127
+	KEY_UP    = 10001
+	KEY_DOWN  = 10002
+	KEY_RIGHT = 10003
+	KEY_LEFT  = 10004
+	KEY_FOCUS_IN  = 10005 // 端末がフォーカスを得た (ESC[I)
+	KEY_FOCUS_OUT = 10006 // 端末がフォーカスを失った (ESC[O)
+	KEY_PAGE_UP   = 10007 // ESC[5~
+	KEY_PAGE_DOWN = 10008 // ESC[6~
+	KEY_HOME      = 10009 // ESC[H, ESC[1~
+	KEY_END       = 10010 // ESC[F, ESC[4~
+	KEY_DELETE    = 10011 // ESC[3~
+
+	// Bracketed Paste
+	KEY_PASTE_START = 10012 // ESC[200~
+	KEY_PASTE_END   = 10013 // ESC[201~
+
+	// 修飾キーとの同時押し (キーに加算する)
+	KEY_SHIFT = 0x100000
+	KEY_ALT   = 0x200000 // ESCに続く文字にも加算
+	KEY_CTRL  = 0x400000 // 特殊キーのみ (文字キーとの同時押しはCTRL_A等)
+)
+
+// CSIシーケンスの終端文字とキーの対応 (ESC[A, ESC[1;5Aなど)
+var csiLetterKeys = map[byte]rune{
+	'A': KEY_UP,
+	'B': KEY_DOWN,
+	'C': KEY_RIGHT,
+	'D': KEY_LEFT,
+	'H': KEY_HOME,
+	'F': KEY_END,
+	'Z': KEY_SHIFT | CTRL_I, // Shift+Tab (ESC[Z)
+}
+
+// ESC[n~ の番号とキーの対応 (ESC[5;5~など)
+var csiTildeKeys = map[int]rune{
+	1: KEY_HOME,
+	3: KEY_DELETE,
+	4: KEY_END,
+	5: KEY_PAGE_UP,
+	6: KEY_PAGE_DOWN,
+	7: KEY_HOME,
+	8: KEY_END,
+
+	200: KEY_PASTE_START,
+	201: KEY_PASTE_END,
+}
+
+func parseKey(b []byte) (rune, int) {
+	if len(b) >= 3 && b[0] == byte(27) && b[1] == '[' {
+		switch b[2] {
+		case 'A':
+			return KEY_UP, 3
+		case 'B':
+			return KEY_DOWN, 3
+		case 'C':
+			return KEY_RIGHT, 3
+		case 'D':
+			return KEY_LEFT, 3
+		case 'I':
+			return KEY_FOCUS_IN, 3
+		case 'O':
+			return KEY_FOCUS_OUT, 3
+		}
+		n := csiLength(b)
+		if n == 0 {
+			return -1, 0
+		}
+		return parseCSI(b[2 : n-1], b[n-1]), n
+	}
+	if len(b) >= 2 && b[0] == byte(27) && b[1] != '[' && b[1] >= SPACE { // Alt + 文字
+		r, n := utf8.DecodeRune(b[1:])
+		return KEY_ALT | r, n + 1
+	}
+	return utf8.DecodeRune(b)
+}
+
+// CSIシーケンスのキーの取得 (未対応のシーケンスは-1)
+// 2番目の引数は修飾キー (1 + Shift:1, Alt:2, Ctrl:4)
+func parseCSI(params []byte, final byte) rune {
+	args := strings.Split(string(params), ";")
+	nums := make([]int, len(args))
+	for i, a := range args {
+		if a == "" {
+			continue
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil {
+			return -1
+		}
+		nums[i] = n
+	}
+	var key rune
+	var ok bool
+	if final == '~' {
+		key, ok = csiTildeKeys[nums[0]]
+	} else {
+		key, ok = csiLetterKeys[final]
+	}
+	if !ok {
+		return -1
+	}
+	if len(nums) >= 2 && nums[1] > 1 {
+		mod := nums[1] - 1
+		if mod&1 != 0 {
+			key |= KEY_SHIFT
+		}
+		if mod&2 != 0 {
+			key |= KEY_ALT
+		}
+		if mod&4 != 0 {
+			key |= KEY_CTRL
+		}
+	}
+	return key
+}
+
+// CSIシーケンスの長さ (終端文字がない場合は0)
+func csiLength(b []byte) int {
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return i + 1
+		}
+		if b[i] < 0x20 || b[i] > 0x3f {
+			return 0
+		}
+	}
+	return 0
+}
+
+// 読み取った入力の末尾にある途中までのキー入力の長さ (続きを次に読み取った入力と合わせて解析する)
+// ESCとESC[のみの場合は単独のキー入力としても扱うため、読み取りがバッファを満たした (続きがある) 場合のみ途中とみなす
+func partialKeyLength(b []byte, full bool) int {
+	if i := bytes.LastIndexByte(b, 27); i >= 0 {
+		tail := b[i:]
+		switch {
+		case len(tail) == 1 || (len(tail) == 2 && tail[1] == '['):
+			if full {
+				return len(tail)
+			}
+			return 0
+		case tail[1] == '[': // 終端文字がまだ届いていないCSIシーケンス
+			if csiLength(tail) == 0 && bytes.IndexFunc(tail[2:], func(r rune) bool { return r < 0x20 || r > 0x3f }) < 0 {
+				return len(tail)
+			}
+		case !utf8.FullRune(tail[1:]): // Alt + 文字の途中
+			return len(tail)
+		}
+	}
+	for k := 1; k <= min(utf8.UTFMax-1, len(b)); k++ { // 複数バイトの文字の途中
+		if utf8.RuneStart(b[len(b)-k]) {
+			if !utf8.FullRune(b[len(b)-k:]) {
+				return k
+			}
+			break
+		}
+	}
+	return 0
+}
+
+// SGR形式のマウスイベント (ESC[<b;x;yM / ESC[<b;x;ym) の読み取り
+// マウスイベントでない場合は0を返す
+func parseMouse(b []byte) (MouseEvent, int) {
+	var m MouseEvent
+	if len(b) < 3 || b[0] != byte(27) || b[1] != '[' || b[2] != '<' {
+		return m, 0
+	}
+	n := csiLength(b)
+	if n == 0 {
+		return m, 0
+	}
+	var button, col, row int
+	if _, err := fmt.Sscanf(string(b[3:n-1]), "%d;%d;%d", &button, &col, &row); err != nil {
+		return m, 0
+	}
+	m.Button = button &^ 0x1c // 修飾キーのビットを除く
+	m.Mod = button & 0x1c
+	m.Col = uint(col)
+	m.Row = uint(row)
+	m.Press = b[n-1] == 'M'
+	return m, n
+}
+
+// キー名の取得 (コマンドパレットでの表示用)
+func keyName(r rune) string {
+	switch r {
+	case CTRL_I:
+		return "Tab"
+	case CTRL_M:
+		return "Enter"
+	case ESC:
+		return "Esc"
+	case SPACE:
+		return "Space"
+	case BACKSPACE:
+		return "Backspace"
+	case KEY_UP:
+		return "Up"
+	case KEY_DOWN:
+		return "Down"
+	case KEY_RIGHT:
+		return "Right"
+	case KEY_LEFT:
+		return "Left"
+	case KEY_PAGE_UP:
+		return "PageUp"
+	case KEY_PAGE_DOWN:
+		return "PageDown"
+	case KEY_HOME:
+		return "Home"
+	case KEY_END:
+		return "End"
+	case KEY_DELETE:
+		return "Delete"
+	case CTRL_BRACKET:
+		return "Ctrl+]"
+	case CTRL_SPACE:
+		return "Ctrl+Space"
+	case CTRL_UNDERSCORE:
+		return "Ctrl+/"
+	}
+	if r&KEY_CTRL != 0 {
+		return "Ctrl+" + keyName(r&^KEY_CTRL)
+	}
+	if r&KEY_ALT != 0 {
+		return "Alt+" + keyName(r&^KEY_ALT)
+	}
+	if r&KEY_SHIFT != 0 {
+		return "Shift+" + keyName(r&^KEY_SHIFT)
+	}
+	if r >= CTRL_A && r <= CTRL_Z {
+		return fmt.Sprintf("Ctrl+%c", 'A'+r-CTRL_A)
+	}
+	return string(r)
+}
+
+// テキストとして挿入可能な文字かどうかの判定
+func isInsertable(r rune) bool {
+	return r >= SPACE && r != BACKSPACE && r < KEY_UP
+}
+
+// デフォルトのキー割り当て (キー -> アクション名)
+func defaultKeymap() map[rune]string {
+	keymap := map[rune]string{
+		CTRL_B:    "Toggle Sidebar",
+		CTRL_C:    "Copy Region",
+		CTRL_D:    "Add Cursor at Next Match",
+		CTRL_E:    "Open File",
+		CTRL_G:    "Go to Line",
+		CTRL_I:    "Indent",
+		CTRL_K:    "Command Palette",
+		CTRL_L:    "Buffer List",
+		CTRL_M:    "Insert Newline",
+		CTRL_O:    "Move Top",
+		CTRL_P:    "Move Bottom",
+		CTRL_Q:    "Record Macro",
+		CTRL_R:    "Previous Tab",
+		CTRL_S:    "Save",
+		CTRL_T:    "Next Tab",
+		CTRL_V:    "Yank",
+		CTRL_W:    "Window Command",
+		CTRL_X:    "Exit",
+		CTRL_Y:    "Close Tab",
+		CTRL_Z:    "Toggle Comment",
+		ESC:       "Cancel",
+		BACKSPACE: "Delete Backward",
+		KEY_DELETE: "Delete Forward",
+		KEY_UP:    "Cursor Up",
+		KEY_DOWN:  "Cursor Down",
+		KEY_RIGHT: "Cursor Right",
+		KEY_LEFT:  "Cursor Left",
+		KEY_CTRL | KEY_PAGE_UP:               "Previous Tab",
+		KEY_CTRL | KEY_PAGE_DOWN:             "Next Tab",
+		KEY_CTRL | KEY_SHIFT | KEY_PAGE_UP:   "Move Tab Left",
+		KEY_CTRL | KEY_SHIFT | KEY_PAGE_DOWN: "Move Tab Right",
+		KEY_ALT | KEY_LEFT:                   "Jump Back",
+		KEY_ALT | KEY_RIGHT:                  "Jump Forward",
+		KEY_SHIFT | CTRL_I:                   "Outdent",
+		KEY_ALT | 'q':                        "Play Macro",
+		KEY_ALT | 'I':                        "Add Cursors to Lines",
+		KEY_ALT | '-':                        "Fold",
+		KEY_ALT | '=':                        "Unfold",
+		KEY_ALT | '_':                        "Fold All",
+		KEY_ALT | '+':                        "Unfold All",
+		KEY_CTRL | KEY_ALT | KEY_UP:          "Add Cursor Above",
+		KEY_CTRL | KEY_ALT | KEY_DOWN:        "Add Cursor Below",
+		KEY_SHIFT | KEY_UP:                   "Select Up",
+		KEY_SHIFT | KEY_DOWN:                 "Select Down",
+		KEY_SHIFT | KEY_LEFT:                 "Select Left",
+		KEY_SHIFT | KEY_RIGHT:                "Select Right",
+		KEY_SHIFT | KEY_ALT | KEY_UP:         "Block Select Up",
+		KEY_SHIFT | KEY_ALT | KEY_DOWN:       "Block Select Down",
+		KEY_SHIFT | KEY_ALT | KEY_LEFT:       "Block Select Left",
+		KEY_SHIFT | KEY_ALT | KEY_RIGHT:      "Block Select Right",
+		KEY_CTRL | KEY_LEFT:                  "Cursor Word Left",
+		KEY_CTRL | KEY_RIGHT:                 "Cursor Word Right",
+		KEY_CTRL | KEY_SHIFT | KEY_LEFT:      "Select Word Left",
+		KEY_CTRL | KEY_SHIFT | KEY_RIGHT:     "Select Word Right",
+		KEY_HOME:                             "Cursor Line Start",
+		KEY_END:                              "Cursor Line End",
+		KEY_SHIFT | KEY_HOME:                 "Select Line Start",
+		KEY_SHIFT | KEY_END:                  "Select Line End",
+		KEY_CTRL | KEY_UP:                    "Cursor Paragraph Up",
+		KEY_CTRL | KEY_DOWN:                  "Cursor Paragraph Down",
+		KEY_CTRL | KEY_SHIFT | KEY_UP:        "Select Paragraph Up",
+		KEY_CTRL | KEY_SHIFT | KEY_DOWN:      "Select Paragraph Down",
+		KEY_PAGE_UP:                          "Cursor Page Up",
+		KEY_PAGE_DOWN:                        "Cursor Page Down",
+		KEY_SHIFT | KEY_PAGE_UP:              "Select Page Up",
+		KEY_SHIFT | KEY_PAGE_DOWN:            "Select Page Down",
+		KEY_CTRL | KEY_HOME:                  "Move Top",
+		KEY_CTRL | KEY_END:                   "Move Bottom",
+		KEY_CTRL | KEY_SHIFT | KEY_HOME:      "Select to Top",
+		KEY_CTRL | KEY_SHIFT | KEY_END:       "Select to Bottom",
+		CTRL_BRACKET:                         "Cursor Matching Bracket",
+	}
+	for n := 1; n <= 9; n++ { // Alt+1 ~ 9
+		keymap[KEY_ALT|rune('0'+n)] = fmt.Sprintf("Go to Tab %d", n)
+	}
+	return keymap
+}
+
+// アクションに割り当てられているキー名の一覧
+func (v *View) KeyBindings(name string) []string {
+	keys := make([]rune, 0)
+	for k, n := range v.Keymap {
+		if n == name {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = keyName(k)
+	}
+	// ウィンドウ操作はCTRL_Wに続けて入力するキーも表示
+	if v.Keymap[CTRL_W] == "Window Command" {
+		wkeys := make([]rune, 0)
+		for k, n := range windowKeys {
+			if n == name {
+				wkeys = append(wkeys, k)
+			}
+		}
+		sort.Slice(wkeys, func(i, j int) bool { return wkeys[i] < wkeys[j] })
+		for _, k := range wkeys {
+			names = append(names, "Ctrl+W "+keyName(k))
+		}
+	}
+	return names
+}
+
+func (v *View) processInput(r rune) uint8 {
+	switch r { // フォーカスイベントはキー入力として扱わない
+	case KEY_FOCUS_OUT:
+		v.AutosaveFocusLost()
+		return 0
+	case KEY_FOCUS_IN:
+		return 0
+	}
+	if v.handlePaste(r) { // マクロの記録中は貼り付けも開始・終了の通知ごと記録する
+		v.recordKey(r, v.recording)
+		return 0
+	}
+	v.LastInput = time.Now()
+	v.Term.DisableCursor()
+	defer v.Term.EnableCursor()
+	if v.Message != "" { // 前回のメッセージを消去
+		v.Message = ""
+		defer func() {
+			if v.Overlay == nil {
+				v.UpdateStatusBar()
+			}
+		}()
+	}
+	recording := v.recording
+	exitCode := v.handleKey(r)
+	v.recordKey(r, recording)
+	if exitCode == 0 {
+		v.revealCursor()
+		v.UpdateBracketMatch()
+	}
+	return exitCode
+}
+
+// 貼り付けの開始・終了の通知と貼り付け中の入力の処理 (処理した場合はtrueを返す)
+// 貼り付けの終了までの入力はまとめてそのまま挿入する
+func (v *View) handlePaste(r rune) bool {
+	switch {
+	case r == KEY_PASTE_START:
+		v.pasted = make([]rune, 0)
+		v.pastedAt = time.Now()
+	case r == KEY_PASTE_END:
+		if v.pasted != nil {
+			text := v.pasted
+			v.pasted = nil
+			v.InsertPasted(text)
+			v.UpdateBracketMatch()
+		}
+	case v.pasted != nil:
+		v.pasted = append(v.pasted, r)
+		v.pastedAt = time.Now()
+	default:
+		return false
+	}
+	return true
+}
+
+// 貼り付けの終了の通知が一定時間届かない場合は、そこまでの入力を貼り付けとして挿入して貼り付けを終える
+// (分割して読み取った終了の通知を取りこぼした場合に貼り付け中のまま入力を受け付けなくなるのを防ぐ)
+func (v *View) ExpirePaste() {
+	if v.pasted == nil || time.Since(v.pastedAt) < PASTE_TIMEOUT {
+		return
+	}
+	v.handlePaste(KEY_PASTE_END)
+	v.recordKey(KEY_PASTE_END, v.recording)
+}
+
+// キー入力の処理 (オーバーレイ・ファイルツリー・入力レイヤー・キー割り当ての順に処理し、割り当てのない文字は入力する)
+func (v *View) handleKey(r rune) uint8 {
+	if v.handlePaste(r) { // 記録したマクロの再生中の貼り付け
+		return 0
+	}
+	v.keySeq++
+	if v.Overlay != nil { // オーバーレイ表示中はオーバーレイで入力を処理
+		return v.Overlay.HandleKey(v, r)
+	}
+	if v.Sidebar != nil && v.Sidebar.Focused { // ファイルツリーの操作
+		if handled, exitCode := v.Sidebar.HandleKey(v, r); handled {
+			return exitCode
+		}
+	}
+	if v.Layer != nil {
+		if handled, exitCode := v.Layer.HandleKey(v, r); handled {
+			return exitCode
+		}
+	}
+	if name, ok := v.Keymap[r]; ok {
+		return v.RunAction(name)
+	}
+	if !isInsertable(r) { // 割り当てのない制御文字・特殊キーは無視
+		return 0
+	}
+	v.InsertRune(r)
+	return 0
+}
+
+// 端末から貼り付けた文字列の挿入 (自動インデント・括弧の補完をせずにそのまま挿入する)
+// 改行はCR・CRLFもLFとして扱い、タブはファイルの読み込み時と同じく空白に変換する
+func (v *View) InsertPasted(keys []rune) {
+	text := make([]rune, 0, len(keys))
+	for i, r := range keys {
+		switch {
+		case r == CTRL_M:
+			text = append(text, '\n')
+		case r == CTRL_J:
+			if i == 0 || keys[i-1] != CTRL_M {
+				text = append(text, '\n')
+			}
+		case r == CTRL_I:
+			text = append(text, []rune(strings.Repeat(" ", int(v.GetCurrentTab().TabSize)))...)
+		case isInsertable(r):
+			text = append(text, r)
+		}
+	}
+	if v.Overlay != nil { // 入力欄には1行目のみ入力
+		for _, r := range text {
+			if r == '\n' || v.Overlay == nil { // 確認などのオーバーレイは入力で閉じる
+				break
+			}
+			v.Overlay.HandleKey(v, r)
+		}
+		return
+	}
+	if v.Sidebar != nil && v.Sidebar.Focused {
+		return
+	}
+	v.splitBlockSelection(true) // 矩形選択は各行に挿入する
+	if v.eachCaret(func() { v.InsertPasted(keys) }) {
+		return
+	}
+	cTab := v.GetCurrentTab()
+	cTab.SaveUndo()
+	cTab.DeleteSelection()
+	end := cTab.InsertText(*cTab.Cursor, text)
+	cTab.MoveTargetRow(end.Row)
+	cTab.MoveTargetCol(end.Col)
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	v.UpdateTabBar()
+}
+
+// カーソル位置への文字の入力 (選択範囲は入力した文字で置き換える)
+func (v *View) InsertRune(r rune) {
+	v.splitBlockSelection(true) // 矩形選択は各行に入力する
+	if v.eachCaret(func() { v.InsertRune(r) }) { // 複数カーソルの場合は全てのカーソルで入力
+		return
+	}
+	cTab := v.GetCurrentTab() // Current Tab
+	v.saveTypingUndo()
+	replaced := cTab.DeleteSelection() // 選択範囲は入力した文字で置き換える
+	if !replaced && cTab.overtypeClosing(r) { // 直後の同じ閉じ文字は上書きせずに進む
+		v.RefleshCursor()
+		v.UpdateStatusBar()
+		return
+	}
+	cTab.IsSaved = false
+	cTab.Lines[cTab.Cursor.Row-1].Insert(int(cTab.Cursor.Col-1), r)
+	cTab.changedFrom(cTab.Cursor.Row)
+	cTab.MoveNextCol()
+	if !replaced { // 開き括弧・引用符は閉じ文字を補完する
+		cTab.closePair(r)
+	}
+	if core.IsClosingBracket(r) { // 行頭の閉じ括弧は対応する開き括弧の行に揃える
+		cTab.dedentClosing()
+	}
+	if replaced {
+		v.ScrollToCursor()
+		v.RefleshTextField()
+	} else {
+		v.RefleshTargetRow(cTab.Cursor.Row)
+	}
+	v.UpdateTabBar()
+	v.UpdateStatusBar()
+}
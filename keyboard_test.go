@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_Keyboard_PartialKeyLength(t *testing.T) {
+	type args struct {
+		b    string
+		full bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want int
+	}{
+		{"Test #1", args{"abc", true}, 0},
+		{"Test #2", args{"abc\x1b[20", false}, 4},
+		{"Test #3", args{"abc\x1b[201", true}, 5},
+		{"Test #4", args{"abc\x1b[201~", true}, 0},
+		{"Test #5", args{"abc\x1b", false}, 0},
+		{"Test #6", args{"abc\x1b", true}, 1},
+		{"Test #7", args{"abc\x1b[", false}, 0},
+		{"Test #8", args{"abc\x1b[", true}, 2},
+		{"Test #9", args{"ab\xe3\x81", false}, 2},
+		{"Test #10", args{"ab\xe3\x81\x82", true}, 0},
+		{"Test #11", args{"\x1b[<0;12", false}, 7},
+		{"Test #12", args{"a\x1b\xe3", false}, 2},
+		{"Test #13", args{"\x1b[Ax\xf0\x9f", false}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partialKeyLength([]byte(tt.args.b), tt.args.full); got != tt.want {
+				t.Errorf("partialKeyLength() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode"
+)
+
+const (
+	MACRO_DEFAULT    = 'q'           // レジスタ名を指定しない場合のマクロのレジスタ
+	MACRO_MAX_DEPTH  = 100           // マクロの中から別のマクロを再生できる深さ
+	MACRO_UNTIL_FAIL = 10000         // 失敗するまで繰り返す場合の最大の回数
+	MACRO_FILE       = "macros.json" // マクロの保存先 (状態ディレクトリ内)
+)
+
+// マクロのレジスタ名として使用できる文字かどうか (英数字、大文字は小文字のレジスタに追記)
+func isMacroName(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// マクロの記録の開始 (大文字のレジスタ名の場合は既存のマクロに追記)
+func (v *View) StartRecording(name rune) {
+	if v.recording != 0 {
+		v.StopRecording()
+	}
+	v.recordKeys = nil
+	if unicode.IsUpper(name) {
+		name = unicode.ToLower(name)
+		v.recordKeys = append(v.recordKeys, v.Macros[name]...)
+	}
+	v.recording = name
+	v.recordEnd = len(v.recordKeys)
+	v.SetMessage("Recording @%c", name)
+	v.UpdateStatusBar()
+}
+
+// マクロの記録の終了 (記録を終了するキー列は含めない)
+func (v *View) StopRecording() {
+	if v.recording == 0 {
+		return
+	}
+	name := v.recording
+	if v.Macros == nil {
+		v.Macros = make(map[rune][]rune)
+	}
+	v.Macros[name] = append([]rune{}, v.recordKeys[:v.recordEnd]...)
+	v.recording, v.recordKeys, v.lastMacro = 0, nil, name
+	if err := v.SaveMacros(); err != nil {
+		v.SetMessage("Error: %v", err)
+	} else {
+		v.SetMessage("Recorded @%c (%d keys)", name, len(v.Macros[name]))
+	}
+	v.UpdateStatusBar()
+}
+
+// 処理したキー入力の記録 (wasは処理前に記録していたレジスタ名、記録を開始・終了したキー入力は含めない)
+func (v *View) recordKey(r rune, was rune) {
+	if v.recording == 0 || v.recording != was {
+		return
+	}
+	v.recordKeys = append(v.recordKeys, r)
+	if v.Layer == nil || !v.Layer.Pending() { // 入力途中のプレフィックスは記録の終了に使われた場合に取り除く
+		v.recordEnd = len(v.recordKeys)
+	}
+}
+
+// マクロの再生 (count回繰り返し、途中でコマンドが失敗した場合は中断する)
+// 再生全体を1つの取り消しの単位とし、画面は最後に1回だけ描画する
+func (v *View) PlayMacro(name rune, count int) uint8 {
+	if name == '@' { // 最後に記録・再生したマクロ
+		name = v.lastMacro
+		if name == 0 {
+			name = MACRO_DEFAULT
+		}
+	}
+	name = unicode.ToLower(name)
+	keys, ok := v.Macros[name]
+	if !ok {
+		v.SetMessage("Macro @%c is empty", name)
+		v.Fail()
+		return 0
+	}
+	if v.playing >= MACRO_MAX_DEPTH {
+		v.SetMessage("Error: macro @%c is nested too deeply", name)
+		v.Fail()
+		return 0
+	}
+	v.lastMacro = name
+	b := v.GetCurrentTab().Buffer
+	b.BeginUndoGroup()
+	defer b.EndUndoGroup()
+	if v.playing == 0 {
+		resume := v.suspendDrawing()
+		defer func() {
+			resume()
+			v.failed = false
+			v.Reflesh()
+		}()
+	}
+	v.playing++
+	defer func() { v.playing-- }()
+
+	for i := 0; i < max(count, 1); i++ {
+		for _, k := range keys {
+			if exitCode := v.handleKey(k); exitCode != 0 {
+				return exitCode
+			}
+			if v.failed {
+				return 0
+			}
+		}
+	}
+	return 0
+}
+
+// コマンドの失敗の通知 (再生中のマクロを中断する)
+func (v *View) Fail() {
+	if v.playing > 0 {
+		v.failed = true
+	}
+}
+
+// 描画の停止 (戻り値の関数で再開する、既に停止中の場合は何もしない)
+// 再開しても描画し直さないため、呼び出し側で最後に1回描画する
+func (v *View) suspendDrawing() func() {
+	if v.suspended {
+		return func() {}
+	}
+	v.suspended = true
+	return func() { v.suspended = false }
+}
+
+// マクロの保存先のパス
+func macroFilePath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, MACRO_FILE), nil
+}
+
+// 保存したマクロの読み込み (存在しない場合は何もしない)
+func (v *View) LoadMacros() error {
+	path, err := macroFilePath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	saved := make(map[string][]rune)
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	v.Macros = make(map[rune][]rune)
+	for name, keys := range saved {
+		if r := []rune(name); len(r) == 1 && isMacroName(r[0]) {
+			v.Macros[r[0]] = keys
+		}
+	}
+	return nil
+}
+
+// マクロの保存 (次回の起動時にも使用できるように状態ディレクトリに書き出す)
+func (v *View) SaveMacros() error {
+	path, err := macroFilePath()
+	if err != nil {
+		return err
+	}
+	saved := make(map[string][]rune, len(v.Macros))
+	for name, keys := range v.Macros {
+		saved[string(name)] = keys
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// レジスタ名と回数の入力の解析 (a・a10・10・a*、省略したレジスタ名は最後に使用したマクロ、*は失敗するまで)
+func parseMacroInput(input string) (name rune, count int, ok bool) {
+	name, count = '@', 1
+	if r := []rune(input); len(r) > 0 && !unicode.IsDigit(r[0]) && r[0] != '*' {
+		if !isMacroName(r[0]) && r[0] != '@' {
+			return 0, 0, false
+		}
+		name, input = r[0], string(r[1:])
+	}
+	if input == "*" {
+		count = MACRO_UNTIL_FAIL
+	} else if input != "" {
+		n, err := strconv.Atoi(input)
+		if err != nil || n < 1 {
+			return 0, 0, false
+		}
+		count = n
+	}
+	return name, count, true
+}
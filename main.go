@@ -1,44 +1,99 @@
-package main
-
-import (
-	"fmt"
-	"os"
-)
-
-func main() {
-	// 引数にファイルが指定されていない場合
-	if len(os.Args) == 1 {
-		fmt.Println("Error: ファイル名が指定されていません")
-		os.Exit(0)
-	}
-
-	view := NewView()
-	view.Term.EnableAlternativeScreenBuffer()
-	view.Term.EnableRawMode()
-	defer view.Term.DisableRawMode()
-	defer view.Term.DisableAlternativeScreenBuffer()
-	defer view.Term.EnableCursor()
-	defer view.Event.Close()
-
-	// 引数のパスをタブに追加
-	for i, path := range os.Args {
-		if i == 0 {
-			continue
-		}
-		pathInfo, _ := os.Stat(path)
-		if pathInfo.IsDir() == false {
-			view.AddTab(path)
-		} else {
-			// ディレクトリの場合の処理
-		}
-	}
-
-	// 全てのタブのファイルをロード
-	for _, tab := range view.Tabs {
-		tab.LoadFile()
-	}
-	
-	view.UpdateWinSize() // 画面サイズの取得
-	view.Reflesh()
-	view.MainLoop() //メインループ
-}
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+func main() {
+	view := NewView()
+	if err := view.Term.EnableRawMode(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: 端末をRawモードに設定できません: %v\n", err)
+		os.Exit(1)
+	}
+	view.Term.EnableAlternativeScreenBuffer()
+	view.Term.EnableFocusReporting() // フォーカス喪失時の自動保存用
+	view.Term.EnableMouseReporting() // タブバーとペインのクリック用
+	view.Term.EnableBracketedPaste() // 貼り付けた文字列を自動インデントせずに挿入する
+	defer view.Term.Restore()
+	defer view.Event.Close()
+	defer view.RemoveSwaps()
+	defer func() {
+		if view.FileWatcher != nil {
+			view.FileWatcher.Close()
+		}
+	}()
+	defer func() {
+		if view.Sidebar != nil {
+			view.Sidebar.Close()
+		}
+	}()
+	defer view.RecoverPanic() // パニック時は端末を復元してクラッシュレポートを出力
+
+	errs := make([]string, 0)
+	if config, err := LoadConfig(); err == nil {
+		view.Config = config
+	} else {
+		errs = append(errs, err.Error())
+	}
+	if err := view.LoadMacros(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	// 引数のパスをタブに追加
+	for i, path := range os.Args {
+		if i == 0 {
+			continue
+		}
+		pathInfo, err := os.Stat(path)
+		if view.FindBuffer(path) != nil { // 同じファイルは1つのバッファで開く
+			continue
+		}
+		if err != nil || pathInfo.IsDir() == false { // 存在しないパスは新規ファイルとして開く
+			view.AddTab(path)
+		} else if view.Sidebar == nil { // ディレクトリの場合はファイルツリーで表示
+			view.Sidebar = NewSidebar(path)
+		}
+	}
+
+	// 全てのタブのファイルをロード (読み込めないファイルのタブは閉じる)
+	tabs := make([]*Buffer, 0, len(view.Tabs))
+	for _, tab := range view.Tabs {
+		if err := tab.LoadFile(); err != nil {
+			errs = append(errs, err.Error())
+			view.Buffers.Remove(tab)
+			continue
+		}
+		tabs = append(tabs, tab)
+		view.watchFile(tab)
+	}
+	view.Tabs = tabs
+
+	// ファイルが指定されていない場合は無題のタブを表示 (保存時にパスを入力)
+	if len(view.Tabs) == 0 {
+		view.AddUntitledTab()
+	} else if view.Sidebar != nil {
+		view.Sidebar.Focused = false
+	}
+	if len(errs) > 0 {
+		view.Message = "Error: " + strings.Join(errs, "; ")
+	}
+
+	switch view.Config.Keymap { // キー操作の方式
+	case "vim": // Vim風のモード編集はノーマルモードから開始
+		view.Layer = NewVim()
+		view.Term.SetCursorShape(core.CURSOR_BLOCK)
+	case "emacs":
+		view.Layer = NewEmacs()
+	}
+
+	view.MoveTab(0)      // 最初のタブをペインに表示
+	view.UpdateWinSize() // 画面サイズの取得
+	view.Reflesh()
+	view.CheckSwaps(view.Tabs) // 前回の未保存の変更の復元・他のインスタンスで開いているファイルの警告
+	view.RecoverUntitled()     // 終了したインスタンスの無題のバッファの復元
+	view.MainLoop() //メインループ
+}
@@ -0,0 +1,165 @@
+package main
+
+import "github.com/broccolingual/Xanadu/core"
+
+// カーソル移動
+// 各移動について "Cursor <名前>" と選択範囲を広げる "Select <名前>" のアクションを作成する
+type Motion struct {
+	Name  string                   // 移動の名前
+	Plain bool                     // 選択しない移動のアクションも作成するかどうか (既存のアクションがある場合はfalse)
+	Move  func(v *View, e *Editor) // 移動処理
+}
+
+var motions = []Motion{
+	{"Up", false, func(v *View, e *Editor) { e.MovePrevRow() }},
+	{"Down", false, func(v *View, e *Editor) { e.MoveNextRow() }},
+	{"Left", false, func(v *View, e *Editor) { e.MovePrevCol() }},
+	{"Right", false, func(v *View, e *Editor) { e.MoveNextCol() }},
+	{"Word Left", true, func(v *View, e *Editor) { e.MoveWordLeft() }},
+	{"Word Right", true, func(v *View, e *Editor) { e.MoveWordRight() }},
+	{"Line Start", true, func(v *View, e *Editor) { e.MoveSmartHome() }},
+	{"Line End", true, func(v *View, e *Editor) { e.MoveTailCol() }},
+	{"Paragraph Up", true, func(v *View, e *Editor) { e.MoveParagraphUp() }},
+	{"Paragraph Down", true, func(v *View, e *Editor) { e.MoveParagraphDown() }},
+	{"Page Up", true, func(v *View, e *Editor) { e.MovePage(-int(v.Focus.TextHeight())) }},
+	{"Page Down", true, func(v *View, e *Editor) { e.MovePage(int(v.Focus.TextHeight())) }},
+	{"Matching Bracket", true, (*View).moveMatchingBracket},
+	{"to Top", false, func(v *View, e *Editor) { v.PushJump(); e.MoveHeadRow(); e.MoveHeadCol() }},
+	{"to Bottom", false, func(v *View, e *Editor) { v.PushJump(); e.MoveTailRow(); e.MoveTailCol() }},
+}
+
+// 行内の決まった位置への移動 (既にその位置にある場合も失敗としない)
+var lineMotions = map[string]bool{"Line Start": true, "Line End": true}
+
+// カーソル移動のアクションの一覧
+func motionActions() []*Action {
+	actions := make([]*Action, 0, len(motions)*2)
+	for _, m := range motions {
+		m := m
+		if m.Plain {
+			actions = append(actions, &Action{"Cursor " + m.Name, func(v *View) uint8 {
+				return v.runMotion(m, false)
+			}})
+		}
+		actions = append(actions, &Action{"Select " + m.Name, func(v *View) uint8 {
+			return v.runMotion(m, true)
+		}})
+	}
+	return actions
+}
+
+// カーソル移動の実行 (extendがtrueの場合は選択範囲を広げ、falseの場合は選択を解除する)
+func (v *View) runMotion(m Motion, extend bool) uint8 {
+	e := v.GetCurrentTab()
+	if extend {
+		e.StartSelection()
+	} else {
+		e.ClearSelection()
+	}
+	orig := *e.Cursor
+	m.Move(v, e)
+	e.clampCursor()
+	if e.Cursor.Row == orig.Row && e.Cursor.Col == orig.Col && !lineMotions[m.Name] { // 移動できなかった場合は再生中のマクロを中断
+		v.Fail()
+	}
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	return 0
+}
+
+// 現在の行の内容
+func (e *Editor) currentLine() []rune {
+	return e.Lines[e.Cursor.Row-1].GetAll()
+}
+
+// 前の単語の先頭へ移動 (行頭の場合は前の行末へ)
+func (e *Editor) MoveWordLeft() {
+	if e.IsFirstCol() {
+		if !e.IsFirstRow() {
+			e.MovePrevRow()
+			e.MoveTailCol()
+		}
+		return
+	}
+	e.MoveTargetCol(uint(core.PrevWordStart(e.currentLine(), int(e.Cursor.Col-1))) + 1)
+}
+
+// 次の単語の先頭へ移動 (行末の場合は次の行の最初の単語へ)
+func (e *Editor) MoveWordRight() {
+	if e.IsLastCol() {
+		if !e.IsLastRow() {
+			e.MoveNextRow()
+			e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+		}
+		return
+	}
+	e.MoveTargetCol(uint(core.NextWordStart(e.currentLine(), int(e.Cursor.Col-1))) + 1)
+}
+
+// 行頭の空白を除いた最初の文字へ移動 (既にその位置の場合は行頭へ)
+func (e *Editor) MoveSmartHome() {
+	first := uint(core.FirstNonBlank(e.currentLine())) + 1
+	if e.Cursor.Col == first {
+		e.MoveHeadCol()
+	} else {
+		e.MoveTargetCol(first)
+	}
+}
+
+// 空行かどうか (空白のみの行を含む)
+func (b *Buffer) isBlankLine(row uint) bool {
+	line := b.Lines[row-1].GetAll()
+	return core.FirstNonBlank(line) == len(line)
+}
+
+// 前の段落の区切り (空行) へ移動 (ない場合は先頭行)
+func (e *Editor) MoveParagraphUp() {
+	row := e.Cursor.Row - 1
+	for row >= 1 && e.isBlankLine(row) {
+		row--
+	}
+	for row >= 1 && !e.isBlankLine(row) {
+		row--
+	}
+	if row < 1 {
+		row = 1
+	}
+	e.MoveTargetRow(row)
+	e.MoveHeadCol()
+}
+
+// 次の段落の区切り (空行) へ移動 (ない場合は最終行の末尾)
+func (e *Editor) MoveParagraphDown() {
+	last := uint(len(e.Lines))
+	row := e.Cursor.Row + 1
+	for row <= last && e.isBlankLine(row) {
+		row++
+	}
+	for row <= last && !e.isBlankLine(row) {
+		row++
+	}
+	if row > last {
+		e.MoveTailRow()
+		e.MoveTailCol()
+		return
+	}
+	e.MoveTargetRow(row)
+	e.MoveHeadCol()
+}
+
+// 表示行数分の移動 (スクロール位置も同じ行数だけ動かす)
+func (e *Editor) MovePage(delta int) {
+	e.MoveTargetRow(e.stepVisible(e.Cursor.Row, delta))
+	e.ScrollTargetRow(e.stepVisible(e.ScrollRow, delta))
+}
+
+// 対応する括弧へ移動
+func (v *View) moveMatchingBracket(e *Editor) {
+	row, col, ok := e.matchBracket(int(e.Cursor.Row-1), int(e.Cursor.Col-1))
+	if !ok {
+		return
+	}
+	v.PushJump()
+	e.MoveTargetRow(uint(row + 1))
+	e.MoveTargetCol(uint(col + 1))
+}
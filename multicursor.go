@@ -0,0 +1,329 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+// 追加のカーソル (主カーソルはEditor.Cursor・Editor.Anchor)
+type Caret struct {
+	Cursor Cursor  // カーソル位置
+	Anchor *Cursor // 選択範囲の起点 (選択していない場合はnil)
+}
+
+// 全てのカーソルで実行するアクション ("Cursor <名前>"・"Select <名前>"の移動を含む)
+// trueのアクションは矩形選択を各行のカーソルに分割してから実行する
+var caretActions = map[string]bool{
+	"Insert Newline":  true,
+	"Delete Backward": true,
+	"Delete Forward":  true,
+	"Yank":            false,
+	"Indent":          false,
+	"Outdent":         false,
+}
+
+// 全てのカーソルで実行するアクションかどうか
+func isCaretAction(name string) bool {
+	if _, ok := caretActions[name]; ok {
+		return true
+	}
+	for _, m := range motions {
+		if name == "Cursor "+m.Name || name == "Select "+m.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// 主カーソルを含む全てのカーソル (文書内の順、primaryは主カーソルのインデックス)
+func (e *Editor) allCarets() (carets []Caret, primary int) {
+	carets = append([]Caret{{*e.Cursor, e.Anchor}}, e.Carets...)
+	order := make([]int, len(carets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return carets[order[i]].Cursor.Before(carets[order[j]].Cursor)
+	})
+	sorted := make([]Caret, len(carets))
+	for i, idx := range order {
+		sorted[i] = carets[idx]
+		if idx == 0 {
+			primary = i
+		}
+	}
+	return sorted, primary
+}
+
+// カーソルの追加 (追加前の主カーソルは追加のカーソルとし、追加した位置を主カーソルとする)
+func (e *Editor) AddCaret(pos Cursor, anchor *Cursor) {
+	e.Carets = append(e.Carets, Caret{*e.Cursor, e.Anchor})
+	*e.Cursor = pos
+	e.Anchor = anchor
+	e.mergeCarets()
+}
+
+// 追加のカーソルの解除 (解除した場合はtrueを返す)
+func (e *Editor) ClearCarets() bool {
+	had := len(e.Carets) > 0
+	e.Carets = nil
+	return had
+}
+
+// 同じ位置のカーソルをまとめ、範囲外のカーソルを収める
+func (e *Editor) mergeCarets() {
+	seen := map[[2]uint]bool{{e.Cursor.Row, e.Cursor.Col}: true}
+	carets := e.Carets[:0]
+	for _, c := range e.Carets {
+		c.Cursor = e.clampPos(c.Cursor)
+		if c.Anchor != nil {
+			anchor := e.clampPos(*c.Anchor)
+			c.Anchor = &anchor
+		}
+		key := [2]uint{c.Cursor.Row, c.Cursor.Col}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		carets = append(carets, c)
+	}
+	e.Carets = carets
+}
+
+// 位置をバッファの範囲内に収める
+func (b *Buffer) clampPos(pos Cursor) Cursor {
+	pos.Row = max(min(pos.Row, uint(len(b.Lines))), 1)
+	pos.Col = max(min(pos.Col, uint(b.Lines[pos.Row-1].Length())+1), 1)
+	return pos
+}
+
+// 末尾からの相対位置 (最終行からの行数・行末からの文字数、手前での編集では変わらない)
+func (b *Buffer) tailPos(pos Cursor) Cursor {
+	pos = b.clampPos(pos)
+	return Cursor{
+		Row:  uint(len(b.Lines)) - pos.Row,
+		Col:  uint(b.Lines[pos.Row-1].Length()) + 1 - pos.Col,
+		Want: pos.Want,
+	}
+}
+
+// 末尾からの相対位置を位置に戻す
+func (b *Buffer) fromTailPos(tail Cursor) Cursor {
+	row := max(int(len(b.Lines))-int(tail.Row), 1)
+	col := max(b.Lines[row-1].Length()+1-int(tail.Col), 1)
+	return Cursor{Row: uint(row), Col: uint(col), Want: tail.Want}
+}
+
+// 全てのカーソルでの実行 (追加のカーソルがない場合はfalse)
+// 後ろのカーソルから順に実行し、前のカーソルでの編集による位置のずれは末尾からの相対位置で調整する
+// 全体を1つの取り消しの単位とし、画面は最後に1回だけ描画する
+func (v *View) eachCaret(run func()) bool {
+	e := v.GetCurrentTab()
+	if len(e.Carets) == 0 {
+		return false
+	}
+	carets, primary := e.allCarets()
+	e.Carets = nil // 実行中の入れ子の呼び出しは主カーソルのみで行う
+	e.BeginUndoGroup()
+	resume := v.suspendDrawing()
+	tails := make([]Caret, len(carets))
+	for i := len(carets) - 1; i >= 0; i-- {
+		*e.Cursor = e.clampPos(carets[i].Cursor)
+		e.Anchor = carets[i].Anchor
+		run()
+		tails[i].Cursor = e.tailPos(*e.Cursor)
+		if e.Anchor != nil {
+			anchor := e.tailPos(*e.Anchor)
+			tails[i].Anchor = &anchor
+		}
+	}
+	resume()
+	e.EndUndoGroup()
+
+	for i, t := range tails {
+		c := Caret{Cursor: e.fromTailPos(t.Cursor)}
+		if t.Anchor != nil {
+			anchor := e.fromTailPos(*t.Anchor)
+			c.Anchor = &anchor
+		}
+		if i == primary {
+			*e.Cursor, e.Anchor = c.Cursor, c.Anchor
+		} else {
+			e.Carets = append(e.Carets, c)
+		}
+	}
+	e.mergeCarets()
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	v.UpdateTabBar()
+	return true
+}
+
+// 選択範囲の次の出現位置にカーソルを追加 (選択していない場合はカーソル位置の単語を選択)
+func (v *View) AddCaretAtNextMatch() {
+	e := v.GetCurrentTab()
+	start, end, ok := e.Selection()
+	if !ok || e.SelMode != SELECT_CHAR {
+		line := e.currentLine()
+		s, t := core.WordObject(line, int(e.Cursor.Col-1), core.ClassOf, false)
+		if s == t || core.ClassOf(line[s]) == core.CHAR_SPACE {
+			v.SetMessage("No word under cursor")
+			return
+		}
+		e.ClearSelection()
+		e.Anchor = &Cursor{Row: e.Cursor.Row, Col: uint(s) + 1}
+		e.MoveTargetCol(uint(t) + 1)
+		v.RefleshTextField()
+		return
+	}
+
+	needle := splitRunes(e.TextRange(start, end), '\n')
+	carets, _ := e.allCarets()
+	from := end // 最後のカーソルの選択範囲の後ろから検索
+	taken := make(map[Cursor]bool)
+	for _, c := range carets {
+		s, t := c.Cursor, c.Cursor
+		if c.Anchor != nil && c.Anchor.Before(s) {
+			s = Cursor{Row: c.Anchor.Row, Col: c.Anchor.Col}
+		} else if c.Anchor != nil {
+			t = Cursor{Row: c.Anchor.Row, Col: c.Anchor.Col}
+		}
+		s.Want, t.Want = 0, 0
+		taken[s] = true
+		if from.Before(t) {
+			from = t
+		}
+	}
+	lineAt := func(r int) []rune { return e.Lines[r].GetAll() }
+	row, col, found := core.FindNext(lineAt, len(e.Lines), needle, int(from.Row-1), int(from.Col-1))
+	match := Cursor{Row: uint(row) + 1, Col: uint(col) + 1}
+	if !found || taken[match] {
+		v.SetMessage("No more occurrences")
+		return
+	}
+	last := needle[len(needle)-1]
+	matchEnd := Cursor{Row: match.Row + uint(len(needle)) - 1, Col: uint(len(last)) + 1}
+	if len(needle) == 1 {
+		matchEnd.Col += match.Col - 1
+	}
+	e.AddCaret(matchEnd, &match)
+	v.ScrollToCursor()
+	v.RefleshTextField()
+}
+
+// 選択範囲の各行にカーソルを追加 (矩形選択の場合はカーソルの表示上の列、それ以外は行末)
+func (v *View) AddCaretsToLines() {
+	e := v.GetCurrentTab()
+	start, end, ok := e.Selection()
+	if !ok {
+		v.SetMessage("No selection")
+		return
+	}
+	last := end.Row
+	if e.SelMode != SELECT_BLOCK && end.Col == 1 && end.Row > start.Row { // 次の行の先頭までの選択は前の行まで
+		last--
+	}
+	cell := int(e.DisplayCol()) - 1
+	block := e.SelMode == SELECT_BLOCK
+	e.ClearSelection()
+	e.ClearCarets()
+	for row := start.Row; row <= last; row++ {
+		line := e.Lines[row-1].GetAll()
+		col := uint(len(line)) + 1
+		if block {
+			col = uint(utils.IndexAtCell(line, cell, int(e.TabSize))) + 1
+		}
+		if row == start.Row {
+			e.Cursor.Row, e.Cursor.Col, e.Cursor.Want = row, col, 0
+			continue
+		}
+		e.AddCaret(Cursor{Row: row, Col: col}, nil)
+	}
+	v.ScrollToCursor()
+	v.RefleshTextField()
+}
+
+// 上下の行にカーソルを追加 (一番上・下のカーソルから表示上の列を保って移動した位置)
+func (v *View) AddCaretVertical(down bool) {
+	e := v.GetCurrentTab()
+	carets, _ := e.allCarets()
+	edge := carets[0].Cursor
+	if down {
+		edge = carets[len(carets)-1].Cursor
+	}
+	tmp := *e
+	tmp.Cursor = &edge
+	if down && !tmp.IsLastRow() {
+		tmp.MoveNextRow()
+	} else if !down && !tmp.IsFirstRow() {
+		tmp.MovePrevRow()
+	} else {
+		return
+	}
+	e.ClearSelection()
+	e.AddCaret(edge, nil)
+	v.ScrollToCursor()
+	v.RefleshTextField()
+}
+
+// 指定位置のカーソルの追加・削除 (既にカーソルがある場合は削除)
+func (v *View) ToggleCaret(pos Cursor) {
+	e := v.GetCurrentTab()
+	for i, c := range e.Carets {
+		if c.Cursor.Row == pos.Row && c.Cursor.Col == pos.Col {
+			e.Carets = append(e.Carets[:i], e.Carets[i+1:]...)
+			return
+		}
+	}
+	if e.Cursor.Row == pos.Row && e.Cursor.Col == pos.Col { // 主カーソルは最後に追加したカーソルに移す
+		if n := len(e.Carets); n > 0 {
+			*e.Cursor, e.Anchor = e.Carets[n-1].Cursor, e.Carets[n-1].Anchor
+			e.Carets = e.Carets[:n-1]
+		}
+		return
+	}
+	e.AddCaret(pos, nil)
+}
+
+// 追加のカーソルの指定した行での位置 (1始まりの列)
+func (e *Editor) caretCols(row uint) []uint {
+	cols := make([]uint, 0)
+	for _, c := range e.Carets {
+		if c.Cursor.Row == row {
+			cols = append(cols, c.Cursor.Col)
+		}
+	}
+	return cols
+}
+
+// 追加のカーソルの選択範囲の指定した行での列の範囲 [from, to) の一覧
+func (e *Editor) caretSelectedCols(row uint) [][2]uint {
+	ranges := make([][2]uint, 0)
+	for _, c := range e.Carets {
+		if c.Anchor == nil {
+			continue
+		}
+		sub := *e
+		cursor := c.Cursor
+		sub.Cursor, sub.Anchor = &cursor, c.Anchor
+		if from, to, ok := sub.selectedCols(row); ok {
+			ranges = append(ranges, [2]uint{from, to})
+		}
+	}
+	return ranges
+}
+
+// 区切り文字での分割
+func splitRunes(s []rune, sep rune) [][]rune {
+	parts := make([][]rune, 0)
+	start := 0
+	for i, r := range s {
+		if r == sep {
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 一致箇所を強調して文字列を描画
+func drawMatched(v *View, text []rune, positions []int, bg uint8) {
+	matched := 0
+	for i, ch := range text {
+		if matched < len(positions) && positions[matched] == i {
+			v.Term.SetBold()
+			v.Term.SetColor(214)
+			fmt.Printf("%c", ch)
+			v.Term.ResetStyle()
+			v.Term.SetBGColor(bg)
+			matched++
+		} else {
+			fmt.Printf("%c", ch)
+		}
+	}
+}
+
+// 右側を空白で埋めて指定幅に揃える (超過分は切り詰め)
+func padRight(s string, width int) string {
+	rs := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(rs) >= width {
+		return string(rs[:width])
+	}
+	return s + strings.Repeat(" ", width-len(rs))
+}
+
+// 左側を空白で埋めて指定幅に揃える (超過分は切り詰め)
+func padLeft(s string, width int) string {
+	rs := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(rs) >= width {
+		return string(rs[len(rs)-width:])
+	}
+	return strings.Repeat(" ", width-len(rs)) + s
+}
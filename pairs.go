@@ -0,0 +1,186 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+// 自動で閉じる括弧の組 (開き -> 閉じ)
+var autoPairs = map[rune]rune{
+	'(': ')', '[': ']', '{': '}',
+}
+
+// 言語の文字列を囲む引用符かどうか (アポストロフィ等を補完しないよう、言語の記法にある引用符のみ)
+func (b *Buffer) isQuote(r rune) bool {
+	lang, _ := languageOf(b.FilePath)
+	return strings.ContainsRune(lang.Syntax.Quotes+lang.Syntax.RawQuotes, r)
+}
+
+// 入力時に補完する閉じ文字 (括弧と言語の引用符、補完しない文字の場合はfalse)
+func (b *Buffer) closingOf(r rune) (rune, bool) {
+	if closing, ok := autoPairs[r]; ok {
+		return closing, true
+	}
+	return r, b.isQuote(r)
+}
+
+// 字句解析した行の各文字の種類と行末の状態
+type lexedLine struct {
+	Kinds []core.TokenKind
+	State core.LexState
+}
+
+// 指定した行 (1始まり) 以降が変更された場合の解析結果の破棄 (前の行の状態を引き継ぐため後ろの行も解析し直す)
+// 折りたたみ範囲も次に参照した時点で求め直す
+func (b *Buffer) changedFrom(row uint) {
+	b.foldsStale = true
+	if row >= 1 && int(row) <= len(b.lexed) {
+		b.lexed = b.lexed[:row-1]
+	}
+}
+
+// 行の各文字の字句の種類を返す関数 (先頭の行から順に解析し、解析済みの行はバッファに保持して再利用する)
+func (b *Buffer) tokenKinds() func(row int) []core.TokenKind {
+	lang, _ := languageOf(b.FilePath)
+	return func(row int) []core.TokenKind {
+		for len(b.lexed) <= row && len(b.lexed) < len(b.Lines) {
+			var state core.LexState
+			if n := len(b.lexed); n > 0 {
+				state = b.lexed[n-1].State
+			}
+			kinds, state := core.Lex(b.Lines[len(b.lexed)].GetAll(), lang.Syntax, state)
+			b.lexed = append(b.lexed, lexedLine{kinds, state})
+		}
+		if row >= len(b.lexed) {
+			return nil
+		}
+		return b.lexed[row].Kinds
+	}
+}
+
+// 文字列・コメントを区別した対応する括弧の位置 (行・列は0始まり)
+func (b *Buffer) matchBracket(row int, col int) (int, int, bool) {
+	lineAt := func(r int) []rune { return b.Lines[r].GetAll() }
+	return core.MatchBracketIn(lineAt, b.tokenKinds(), len(b.Lines), row, col)
+}
+
+// カーソル位置 (括弧でない場合は直前) の括弧と対応する括弧の位置 (見つからない場合はnil)
+func (e *Editor) MatchingBrackets() []Cursor {
+	line := e.currentLine()
+	col := int(e.Cursor.Col - 1)
+	isBracket := func(c int) bool {
+		return c >= 0 && c < len(line) && (autoPairs[line[c]] != 0 || core.IsClosingBracket(line[c]))
+	}
+	if !isBracket(col) {
+		col--
+	}
+	if !isBracket(col) {
+		return nil
+	}
+	row, match, ok := e.matchBracket(int(e.Cursor.Row-1), col)
+	if !ok {
+		return nil
+	}
+	return []Cursor{{Row: e.Cursor.Row, Col: uint(col) + 1}, {Row: uint(row) + 1, Col: uint(match) + 1}}
+}
+
+// 入力した閉じ括弧・引用符がカーソル位置の文字と同じ場合は上書きせずに進む (進んだ場合はtrue)
+func (e *Editor) overtypeClosing(r rune) bool {
+	line := e.currentLine()
+	col := int(e.Cursor.Col - 1)
+	if col >= len(line) || line[col] != r || !(core.IsClosingBracket(r) || e.isQuote(r)) {
+		return false
+	}
+	e.MoveNextCol()
+	return true
+}
+
+// 入力した開き括弧・引用符に対応する閉じ文字をカーソルの後ろに挿入
+// 後ろが空白・行末・閉じ括弧の場合のみ補完し、引用符は前が単語の文字・引用符の場合は補完しない
+func (e *Editor) closePair(r rune) {
+	closing, ok := e.closingOf(r)
+	if !ok {
+		return
+	}
+	line := e.currentLine()
+	col := int(e.Cursor.Col - 1) // 入力した文字の直後
+	if col < len(line) && core.ClassOf(line[col]) != core.CHAR_SPACE && !core.IsClosingBracket(line[col]) {
+		return
+	}
+	if closing == r && col >= 2 && (core.ClassOf(line[col-2]) == core.CHAR_WORD || e.isQuote(line[col-2])) {
+		return
+	}
+	e.Lines[e.Cursor.Row-1].Insert(col, closing)
+	e.changedFrom(e.Cursor.Row)
+}
+
+// カーソルが空の括弧・引用符の組の間にあるかどうか
+func (e *Editor) inEmptyPair() bool {
+	line := e.currentLine()
+	col := int(e.Cursor.Col - 1)
+	if col < 1 || col >= len(line) {
+		return false
+	}
+	closing, ok := e.closingOf(line[col-1])
+	return ok && closing == line[col]
+}
+
+// 対応する括弧の強調表示の更新 (変わった括弧の行のみ再描画)
+func (v *View) UpdateBracketMatch() {
+	if v.Focus == nil || v.suspended {
+		return
+	}
+	e := v.Focus.Editor
+	var match []Cursor
+	if len(e.Lines) > 0 {
+		match = e.MatchingBrackets()
+	}
+	prev, prevEditor := v.brackets, v.bracketEditor
+	if prevEditor == e && equalCursors(prev, match) {
+		return
+	}
+	v.brackets, v.bracketEditor = match, e
+	if v.Overlay != nil {
+		return
+	}
+	rows := make(map[uint]bool)
+	if prevEditor == e {
+		for _, c := range prev {
+			rows[c.Row] = true
+		}
+	}
+	for _, c := range match {
+		rows[c.Row] = true
+	}
+	for row := range rows {
+		v.RefleshTargetRow(row)
+	}
+}
+
+// 位置の一覧が等しいかどうか
+func equalCursors(a []Cursor, b []Cursor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Row != b[i].Row || a[i].Col != b[i].Col {
+			return false
+		}
+	}
+	return true
+}
+
+// 指定した行の強調表示する括弧の列 (1始まり)
+func (v *View) bracketCols(e *Editor, row uint) []uint {
+	cols := make([]uint, 0)
+	if e != v.bracketEditor {
+		return cols
+	}
+	for _, c := range v.brackets {
+		if c.Row == row {
+			cols = append(cols, c.Col)
+		}
+	}
+	return cols
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+const (
+	PALETTE_WIDTH_MAX = 60 // コマンドパレットの最大幅
+	PALETTE_ITEM_MAX  = 10 // コマンドパレットの最大表示件数
+	PALETTE_RECENT    = 64 // 最近使用したアクションに加算するスコア
+)
+
+// コマンドパレット構造体
+type Palette struct {
+	Query    []rune    // 入力中の検索文字列
+	Items    []*Action // 絞り込み後の候補 (スコア順)
+	Selected int       // 選択中の候補のインデックス
+}
+
+// 新しいコマンドパレットの取得
+func NewPalette(v *View) (p *Palette) {
+	p = new(Palette)
+	p.Query = make([]rune, 0)
+	p.filter(v)
+	return
+}
+
+// 検索文字列で候補を絞り込み
+// 一致度が同じ場合は最近使用したものを優先し、次に短い名前を優先
+func (p *Palette) filter(v *View) {
+	type candidate struct {
+		action *Action
+		score  int
+	}
+	candidates := make([]candidate, 0, len(v.Actions))
+	for _, a := range v.Actions {
+		score, _, ok := core.FuzzyMatch(p.Query, []rune(a.Name))
+		if !ok {
+			continue
+		}
+		for i, name := range v.RecentActions {
+			if name == a.Name {
+				score += PALETTE_RECENT - i
+				break
+			}
+		}
+		candidates = append(candidates, candidate{a, score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if len(p.Query) == 0 {
+			return false
+		}
+		return len(candidates[i].action.Name) < len(candidates[j].action.Name)
+	})
+	p.Items = make([]*Action, len(candidates))
+	for i, c := range candidates {
+		p.Items[i] = c.action
+	}
+	p.Selected = 0
+}
+
+// パレットの表示領域 (左端の列・上端の行・幅)
+func (p *Palette) bounds(v *View) (left uint, top uint, width uint) {
+	w := min(int(v.WinCol)-4, PALETTE_WIDTH_MAX)
+	w = max(w, 1) // 狭い端末でも1文字分は確保
+	width = uint(w)
+	left = uint(max(int(v.WinCol)-w, 0)/2 + 1)
+	top = 2
+	return
+}
+
+// 表示可能な候補数
+func (p *Palette) visibleItems(v *View) int {
+	n := PALETTE_ITEM_MAX
+	if int(v.WinRow)-4 < n {
+		n = int(v.WinRow) - 4
+	}
+	if len(p.Items) < n {
+		n = len(p.Items)
+	}
+	return n
+}
+
+func (p *Palette) Draw(v *View) {
+	if v.suspended {
+		return
+	}
+	defer v.Term.ResetStyle()
+	left, top, width := p.bounds(v)
+
+	// 入力行
+	v.Term.MoveCursorPos(left, top)
+	v.Term.SetBGColor(237)
+	input := fmt.Sprintf(" > %s", string(p.Query))
+	fmt.Print(padRight(input, int(width)))
+
+	// 候補の一覧 (選択中の候補が見えるようにスクロール)
+	n := p.visibleItems(v)
+	offset := 0
+	if p.Selected >= n {
+		offset = p.Selected - n + 1
+	}
+	for i := 0; i < n; i++ {
+		a := p.Items[offset+i]
+		v.Term.MoveCursorPos(left, top+uint(i)+1)
+		v.Term.ResetStyle()
+		bg := uint8(235)
+		if offset+i == p.Selected {
+			bg = 25
+		}
+		v.Term.SetBGColor(bg)
+
+		keys := strings.Join(v.KeyBindings(a.Name), ", ")
+		_, positions, _ := core.FuzzyMatch(p.Query, []rune(a.Name))
+		fmt.Print(" ")
+		drawMatched(v, []rune(a.Name), positions, bg)
+		rest := int(width) - len([]rune(a.Name)) - 2
+		v.Term.SetColor(245)
+		fmt.Printf("%s ", padLeft(keys, rest))
+	}
+	if n == 0 {
+		v.Term.MoveCursorPos(left, top+1)
+		v.Term.SetBGColor(235)
+		v.Term.SetColor(245)
+		fmt.Print(padRight(" No matching actions", int(width)))
+	}
+}
+
+func (p *Palette) CursorPos(v *View) (col uint, row uint) {
+	left, top, _ := p.bounds(v)
+	return left + 3 + uint(len(p.Query)), top
+}
+
+func (p *Palette) HandleKey(v *View, r rune) uint8 {
+	switch r {
+	case ESC, CTRL_K: // Close
+		v.CloseOverlay()
+	case CTRL_M: // Run
+		if len(p.Items) == 0 {
+			return 0
+		}
+		name := p.Items[p.Selected].Name
+		v.TouchRecentAction(name)
+		v.CloseOverlay()
+		return v.RunAction(name) // 複数カーソル・矩形選択ではキー入力と同じく各カーソルで実行
+	case KEY_UP, CTRL_P:
+		if p.Selected > 0 {
+			p.Selected--
+		}
+		p.Draw(v)
+	case KEY_DOWN, CTRL_N:
+		if p.Selected < len(p.Items)-1 {
+			p.Selected++
+		}
+		p.Draw(v)
+	case BACKSPACE:
+		if len(p.Query) > 0 {
+			p.Query = p.Query[:len(p.Query)-1]
+			p.filter(v)
+			v.Reflesh()
+		}
+	default:
+		if !isInsertable(r) {
+			return 0
+		}
+		p.Query = append(p.Query, r)
+		p.filter(v)
+		v.Reflesh()
+	}
+	v.RefleshCursor()
+	return 0
+}
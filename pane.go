@@ -0,0 +1,404 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SplitDir int8 // 分割方向
+
+const (
+	SPLIT_NONE       SplitDir = iota // 分割なし (ペイン)
+	SPLIT_HORIZONTAL                 // 上下に分割
+	SPLIT_VERTICAL                   // 左右に分割
+)
+
+// 画面上の矩形領域 (Left, Topは1始まり)
+type Rect struct {
+	Left   uint
+	Top    uint
+	Width  uint
+	Height uint
+}
+
+// 指定した位置を含むかどうか
+func (r Rect) Contains(col uint, row uint) bool {
+	return col >= r.Left && col < r.Left+r.Width && row >= r.Top && row < r.Top+r.Height
+}
+
+// ウィンドウ分割の木のノード (分割しないノードはペインを持つ)
+type Layout struct {
+	Split  SplitDir
+	Ratio  float64 // 最初の子の大きさの割合 (区切り線を除く)
+	First  *Layout // 上または左
+	Second *Layout // 下または右
+	Parent *Layout
+	Pane   *Pane
+	Rect   Rect // 画面上の領域 (区切り線を含む)
+}
+
+// ペイン構造体 (バッファを表示する画面上の領域)
+type Pane struct {
+	Rect                        // 画面上の領域 (行番号を含む)
+	Editor  *Editor             // 表示中のエディタ
+	editors map[*Buffer]*Editor // 表示したことのあるバッファのエディタ (切り替えてもカーソル位置を保持)
+	node    *Layout
+}
+
+// 新しいペインの取得
+func newPane(b *Buffer) (p *Pane) {
+	p = new(Pane)
+	p.editors = make(map[*Buffer]*Editor)
+	p.node = &Layout{Pane: p}
+	p.Show(b)
+	return
+}
+
+// 表示するバッファの切り替え
+func (p *Pane) Show(b *Buffer) {
+	if p.Editor != nil {
+		p.editors[p.Editor.Buffer] = p.Editor
+	}
+	if e, ok := p.editors[b]; ok {
+		p.Editor = e
+	} else {
+		p.Editor = NewEditor(b)
+	}
+}
+
+// 閉じたバッファの破棄 (表示中の場合はreplacementに切り替える)
+func (p *Pane) Forget(b *Buffer, replacement *Buffer) {
+	delete(p.editors, b)
+	if p.Editor.Buffer == b {
+		p.Editor = nil
+		p.Show(replacement)
+	}
+}
+
+// テキストを表示できる文字数 (行番号を除く)
+func (p *Pane) TextWidth() int {
+	return int(p.Width) - GUTTER_WIDTH
+}
+
+// テキストを表示できる行数
+func (p *Pane) TextHeight() uint {
+	if p.Height < 1 {
+		return 1
+	}
+	return p.Height
+}
+
+// 全てのペイン (左上から順)
+func (v *View) Panes() []*Pane {
+	panes := make([]*Pane, 0)
+	var walk func(n *Layout)
+	walk = func(n *Layout) {
+		if n == nil {
+			return
+		}
+		if n.Split == SPLIT_NONE {
+			panes = append(panes, n.Pane)
+			return
+		}
+		walk(n.First)
+		walk(n.Second)
+	}
+	walk(v.Layout)
+	return panes
+}
+
+// 指定した位置にあるペイン (ない場合はnil)
+func (v *View) PaneAt(col uint, row uint) *Pane {
+	for _, p := range v.Panes() {
+		if p.Contains(col, row) {
+			return p
+		}
+	}
+	return nil
+}
+
+// 画面サイズに合わせて各ペインの領域を計算
+func (v *View) UpdateLayout() {
+	if v.Layout == nil {
+		return
+	}
+	width := int(v.WinCol) - int(v.TextLeft()) + 1
+	if width < 0 {
+		width = 0
+	}
+	layoutNode(v.Layout, Rect{v.TextLeft(), 2, uint(width), v.TextHeight()})
+}
+
+// ノードの領域を子に割り当て (区切り線に1文字分を使用)
+func layoutNode(n *Layout, r Rect) {
+	n.Rect = r
+	switch n.Split {
+	case SPLIT_NONE:
+		n.Pane.Rect = r
+	case SPLIT_HORIZONTAL:
+		first, second := splitSize(r.Height, n.Ratio)
+		layoutNode(n.First, Rect{r.Left, r.Top, r.Width, first})
+		layoutNode(n.Second, Rect{r.Left, r.Top + first + 1, r.Width, second})
+	case SPLIT_VERTICAL:
+		first, second := splitSize(r.Width, n.Ratio)
+		layoutNode(n.First, Rect{r.Left, r.Top, first, r.Height})
+		layoutNode(n.Second, Rect{r.Left + first + 1, r.Top, second, r.Height})
+	}
+}
+
+// 区切り線を除いた大きさを割合で分ける (可能な限りどちらも1以上にする)
+func splitSize(size uint, ratio float64) (first uint, second uint) {
+	if size < 1 {
+		return 0, 0
+	}
+	avail := size - 1
+	first = uint(float64(avail)*ratio + 0.5)
+	if first < 1 && avail >= 2 {
+		first = 1
+	}
+	if first > avail-1 && avail >= 2 {
+		first = avail - 1
+	}
+	if first > avail {
+		first = avail
+	}
+	return first, avail - first
+}
+
+// 現在のペインを分割して、同じバッファを表示する新しいペインにフォーカスを移す
+func (v *View) SplitPane(dir SplitDir) bool {
+	p := v.Focus
+	if (dir == SPLIT_HORIZONTAL && p.Height < 3) || (dir == SPLIT_VERTICAL && p.Width < 2*(GUTTER_WIDTH+1)+1) {
+		return false
+	}
+	np := newPane(p.Editor.Buffer)
+	*np.Editor.Cursor = *p.Editor.Cursor
+	np.Editor.ScrollRow = p.Editor.ScrollRow
+
+	n := p.node
+	first := &Layout{Pane: p, Parent: n}
+	second := np.node
+	second.Parent = n
+	p.node = first
+	n.Split = dir
+	n.Ratio = 0.5
+	n.First = first
+	n.Second = second
+	n.Pane = nil
+
+	v.Focus = np
+	v.UpdateLayout()
+	return true
+}
+
+// 現在のペインを閉じる (最後のペインは閉じない)
+func (v *View) ClosePane() bool {
+	n := v.Focus.node
+	parent := n.Parent
+	if parent == nil {
+		return false
+	}
+	sibling := parent.First
+	if sibling == n {
+		sibling = parent.Second
+	}
+	// 親ノードを残った子で置き換える
+	*parent = Layout{Split: sibling.Split, Ratio: sibling.Ratio, First: sibling.First, Second: sibling.Second, Parent: parent.Parent, Pane: sibling.Pane}
+	if parent.Split == SPLIT_NONE {
+		parent.Pane.node = parent
+	} else {
+		parent.First.Parent = parent
+		parent.Second.Parent = parent
+	}
+	v.Focus = v.firstPane(parent)
+	v.UpdateLayout()
+	return true
+}
+
+// ノード以下の最初のペイン
+func (v *View) firstPane(n *Layout) *Pane {
+	for n.Split != SPLIT_NONE {
+		n = n.First
+	}
+	return n.Pane
+}
+
+// 次のペインにフォーカスを移す
+func (v *View) FocusNextPane() {
+	panes := v.Panes()
+	for i, p := range panes {
+		if p == v.Focus {
+			v.Focus = panes[(i+1)%len(panes)]
+			return
+		}
+	}
+}
+
+// 隣のペインにフォーカスを移す (dx, dyは-1, 0, 1のいずれか)
+// 現在のペインの端からカーソルの位置の延長線上にあるペインを選ぶ
+func (v *View) FocusPaneDir(dx int, dy int) bool {
+	p := v.Focus
+	col, row := v.cursorScreenPos()
+	switch {
+	case dx < 0:
+		col = p.Left - 2
+	case dx > 0:
+		col = p.Left + p.Width + 1
+	case dy < 0:
+		row = p.Top - 2
+	case dy > 0:
+		row = p.Top + p.Height + 1
+	}
+	if next := v.PaneAt(col, row); next != nil {
+		v.Focus = next
+		return true
+	}
+	return false
+}
+
+// 現在のペインの大きさの変更 (deltaは変更する文字数)
+// 指定した方向に分割している最も近い親ノードの割合を変更する
+func (v *View) ResizePane(dir SplitDir, delta int) bool {
+	child := v.Focus.node
+	for n := child.Parent; n != nil; child, n = n, n.Parent {
+		if n.Split != dir {
+			continue
+		}
+		size := n.Rect.Width
+		first := n.First.Rect.Width
+		if dir == SPLIT_HORIZONTAL {
+			size = n.Rect.Height
+			first = n.First.Rect.Height
+		}
+		if size < 3 {
+			return false
+		}
+		if child == n.Second {
+			delta = -delta
+		}
+		target := int(first) + delta
+		if target < 1 {
+			target = 1
+		} else if target > int(size)-2 {
+			target = int(size) - 2
+		}
+		n.Ratio = float64(target) / float64(size-1)
+		v.UpdateLayout()
+		return true
+	}
+	return false
+}
+
+// 全てのペインを均等な大きさにする
+func (v *View) EqualizePanes() {
+	var walk func(n *Layout)
+	walk = func(n *Layout) {
+		if n == nil || n.Split == SPLIT_NONE {
+			return
+		}
+		n.Ratio = 0.5
+		walk(n.First)
+		walk(n.Second)
+	}
+	walk(v.Layout)
+	v.UpdateLayout()
+}
+
+// 現在のペインのカーソルの画面上の位置
+func (v *View) cursorScreenPos() (col uint, row uint) {
+	p := v.Focus
+	e := p.Editor
+	return p.Left + GUTTER_WIDTH + e.DisplayCol() - 1, p.Top + uint(e.screenOffset(e.closedFolds(), e.Cursor.Row))
+}
+
+// ペインの区切り線の描画
+func (v *View) drawSeparators(n *Layout) {
+	if n == nil || n.Split == SPLIT_NONE {
+		return
+	}
+	defer v.Term.ResetStyle()
+	v.Term.SetColor(240)
+	first := n.First.Rect
+	if n.Split == SPLIT_VERTICAL {
+		for row := first.Top; row < first.Top+first.Height; row++ {
+			v.Term.MoveCursorPos(first.Left+first.Width, row)
+			fmt.Print("│")
+		}
+	} else {
+		// 区切り線の上のペインのバッファ名を表示
+		above := n.First
+		for above.Split != SPLIT_NONE {
+			above = above.Second
+		}
+		line := []rune(fmt.Sprintf("─ %s ", above.Pane.Editor.Title()))
+		if len(line) > int(first.Width) {
+			line = line[:first.Width]
+		}
+		v.Term.MoveCursorPos(first.Left, first.Top+first.Height)
+		fmt.Print(string(line) + strings.Repeat("─", int(first.Width)-len(line)))
+	}
+	v.Term.ResetStyle()
+	v.drawSeparators(n.First)
+	v.drawSeparators(n.Second)
+}
+
+// CTRL_Wの後に続けて入力するキー (キー -> アクション名)
+var windowKeys = map[rune]string{
+	's':       "Split Down",
+	'v':       "Split Right",
+	'c':       "Close Pane",
+	'q':       "Close Pane",
+	'w':       "Focus Next Pane",
+	CTRL_W:    "Focus Next Pane",
+	'h':       "Focus Pane Left",
+	'j':       "Focus Pane Down",
+	'k':       "Focus Pane Up",
+	'l':       "Focus Pane Right",
+	KEY_LEFT:  "Focus Pane Left",
+	KEY_DOWN:  "Focus Pane Down",
+	KEY_UP:    "Focus Pane Up",
+	KEY_RIGHT: "Focus Pane Right",
+	'+':       "Increase Pane Height",
+	'-':       "Decrease Pane Height",
+	'>':       "Increase Pane Width",
+	'<':       "Decrease Pane Width",
+	'=':       "Equalize Panes",
+}
+
+// ウィンドウ操作のキー入力待ち (大きさの変更は続けて入力できる)
+type WindowMode struct{}
+
+const windowModeHint = " Window: [s]plit down / [v] split right / [c]lose / [w] next / hjkl focus / +-<> resize / [=] equalize"
+
+func (w *WindowMode) Draw(v *View) {
+	if v.suspended {
+		return
+	}
+	defer v.Term.ResetStyle()
+	v.Term.MoveCursorPos(1, uint(v.WinRow))
+	v.Term.ClearRow()
+	v.Term.SetBGColor(237)
+	fmt.Print(padRight(windowModeHint, int(v.WinCol)))
+}
+
+func (w *WindowMode) CursorPos(v *View) (col uint, row uint) {
+	col = uint(len([]rune(windowModeHint))) + 1
+	if col > uint(v.WinCol) {
+		col = uint(v.WinCol)
+	}
+	return col, uint(v.WinRow)
+}
+
+func (w *WindowMode) HandleKey(v *View, r rune) uint8 {
+	name, ok := windowKeys[r]
+	if !ok || r == ESC {
+		v.CloseOverlay()
+		return 0
+	}
+	switch r {
+	case '+', '-', '>', '<': // 大きさの変更は入力待ちを続ける
+		return v.RunAction(name)
+	}
+	v.CloseOverlay()
+	return v.RunAction(name)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ステータスバーに表示する1行入力
+type Prompt struct {
+	Label    string                            // 入力欄の前に表示する文字列
+	Input    []rune                            // 入力中の文字列
+	OnSubmit func(v *View, input string) uint8 // Enterで確定した時の処理
+	OnCancel func(v *View)                     // ESCで中断した時の処理 (nil可)
+}
+
+// 新しい入力欄の取得
+func NewPrompt(label string, initial string, onSubmit func(v *View, input string) uint8) (p *Prompt) {
+	p = new(Prompt)
+	p.Label = label
+	p.Input = []rune(initial)
+	p.OnSubmit = onSubmit
+	return
+}
+
+func (p *Prompt) Draw(v *View) {
+	if v.suspended {
+		return
+	}
+	defer v.Term.ResetStyle()
+	v.Term.MoveCursorPos(1, uint(v.WinRow))
+	v.Term.ClearRow()
+	v.Term.SetBGColor(237)
+	fmt.Print(padRight(fmt.Sprintf(" %s%s", p.Label, string(p.Input)), int(v.WinCol)))
+}
+
+func (p *Prompt) CursorPos(v *View) (col uint, row uint) {
+	return uint(len([]rune(p.Label))+len(p.Input)) + 2, uint(v.WinRow)
+}
+
+func (p *Prompt) HandleKey(v *View, r rune) uint8 {
+	switch r {
+	case ESC: // Cancel
+		v.CloseOverlay()
+		if p.OnCancel != nil {
+			p.OnCancel(v)
+		}
+		return 0
+	case CTRL_M: // Submit
+		v.CloseOverlay()
+		return p.OnSubmit(v, string(p.Input))
+	case BACKSPACE:
+		if len(p.Input) > 0 {
+			p.Input = p.Input[:len(p.Input)-1]
+		}
+	case CTRL_U: // Clear
+		p.Input = p.Input[:0]
+	default:
+		if !isInsertable(r) {
+			return 0
+		}
+		p.Input = append(p.Input, r)
+	}
+	p.Draw(v)
+	v.RefleshCursor()
+	return 0
+}
+
+// 選択肢
+type ChoiceOption struct {
+	Key   rune   // 選択に使うキー
+	Label string // 表示名
+}
+
+// ステータスバーに表示する選択肢付きの確認
+type Choice struct {
+	Message  string
+	Options  []ChoiceOption
+	OnSelect func(v *View, key rune) uint8 // 選択された時の処理 (ESCの場合はkey=ESC)
+}
+
+// 新しい選択肢付きの確認の取得
+func NewChoice(message string, options []ChoiceOption, onSelect func(v *View, key rune) uint8) (c *Choice) {
+	c = new(Choice)
+	c.Message = message
+	c.Options = options
+	c.OnSelect = onSelect
+	return
+}
+
+// はい/いいえの確認の取得 (はいが選択された場合のみonYesを実行)
+func NewConfirm(message string, onYes func(v *View) uint8) *Choice {
+	options := []ChoiceOption{{'y', "yes"}, {'n', "no"}}
+	return NewChoice(message, options, func(v *View, key rune) uint8 {
+		if key == 'y' {
+			return onYes(v)
+		}
+		return 0
+	})
+}
+
+// 選択肢の表示文字列 ("[y]es / [n]o" 形式)
+func (c *Choice) optionsText() string {
+	labels := make([]string, len(c.Options))
+	for i, o := range c.Options {
+		if strings.HasPrefix(o.Label, string(o.Key)) {
+			labels[i] = fmt.Sprintf("[%c]%s", o.Key, o.Label[len(string(o.Key)):])
+		} else {
+			labels[i] = fmt.Sprintf("[%c] %s", o.Key, o.Label)
+		}
+	}
+	return strings.Join(labels, " / ")
+}
+
+func (c *Choice) Draw(v *View) {
+	if v.suspended {
+		return
+	}
+	defer v.Term.ResetStyle()
+	v.Term.MoveCursorPos(1, uint(v.WinRow))
+	v.Term.ClearRow()
+	v.Term.SetBGColor(237)
+	fmt.Print(padRight(fmt.Sprintf(" %s %s ", c.Message, c.optionsText()), int(v.WinCol)))
+}
+
+func (c *Choice) CursorPos(v *View) (col uint, row uint) {
+	return uint(len([]rune(c.Message))+len([]rune(c.optionsText()))) + 3, uint(v.WinRow)
+}
+
+func (c *Choice) HandleKey(v *View, r rune) uint8 {
+	if r == ESC {
+		v.CloseOverlay()
+		return c.OnSelect(v, ESC)
+	}
+	for _, o := range c.Options {
+		if r == o.Key || (o.Key >= 'a' && o.Key <= 'z' && r == o.Key-'a'+'A') {
+			v.CloseOverlay()
+			return c.OnSelect(v, o.Key)
+		}
+	}
+	return 0
+}
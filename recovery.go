@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// 状態ファイル (クラッシュレポート・復元ファイル・スワップファイル) を保存するディレクトリ
+// $XDG_STATE_HOME/paprika (未設定の場合は ~/.local/state/paprika)
+func stateDir() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	dir := filepath.Join(base, "paprika")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// パニックからの復帰 (mainでdeferして使用)
+// 端末の状態を元に戻し、クラッシュレポートと未保存のバッファを書き出して終了する
+func (v *View) RecoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+	v.Term.Restore()
+
+	fmt.Fprintf(os.Stderr, "paprika: panic: %v\n", r)
+	if path, err := v.writeCrashReport(r, stack); err == nil {
+		fmt.Fprintf(os.Stderr, "crash report: %s\n", path)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s\n", stack)
+	}
+	for _, tab := range v.Buffers.All() {
+		if tab.IsSaved {
+			continue
+		}
+		if path, err := dumpRecovery(tab); err == nil {
+			fmt.Fprintf(os.Stderr, "unsaved changes of %s: %s\n", tab.Title(), path)
+		} else {
+			fmt.Fprintf(os.Stderr, "unsaved changes of %s are lost: %v\n", tab.Title(), err)
+		}
+	}
+	os.Exit(2)
+}
+
+// クラッシュレポートの書き出し
+func (v *View) writeCrashReport(r interface{}, stack []byte) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, fmt.Sprintf("crash-%s.log", now.Format("20060102-150405")))
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&report, "args: %q\n", os.Args)
+	fmt.Fprintf(&report, "panic: %v\n\n", r)
+	fmt.Fprintf(&report, "tabs:\n")
+	for i, tab := range v.Tabs {
+		fmt.Fprintf(&report, "  [%d] %s (saved: %v, lines: %d)\n", i, tab.Title(), tab.IsSaved, len(tab.Lines))
+	}
+	fmt.Fprintf(&report, "panes:\n")
+	for i, p := range v.Panes() {
+		fmt.Fprintf(&report, "  [%d] %s (cursor: %d:%d)\n", i, p.Editor.Title(), p.Editor.Cursor.Row, p.Editor.Cursor.Col)
+	}
+	fmt.Fprintf(&report, "\n%s", stack)
+	return path, os.WriteFile(path, []byte(report.String()), 0600)
+}
+
+// 未保存のバッファを復元ファイルとして書き出し
+// バッファが壊れている場合に備えて書き出し中のパニックはエラーとして返す
+func dumpRecovery(b *Buffer) (path string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "recovery")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := "untitled"
+	if b.FilePath != "" {
+		name = filepath.Base(b.FilePath)
+	}
+	fp, err := os.CreateTemp(dir, fmt.Sprintf("%s.%s.*", name, time.Now().Format("20060102-150405")))
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	_, err = fp.Write(b.Bytes(b.NL))
+	return fp.Name(), err
+}
@@ -0,0 +1,106 @@
+package main
+
+import "strings"
+
+// レジスタの内容の種類
+type RegisterKind int8
+
+const (
+	REG_CHARS RegisterKind = iota // 文字単位 (複数行の場合は途中の改行を含む)
+	REG_LINES                     // 行単位
+	REG_BLOCK                     // 矩形 (各行の同じ表示位置の範囲)
+)
+
+// コピー・削除した内容を保持するレジスタ
+type Register struct {
+	Kind  RegisterKind
+	Lines []string
+}
+
+// レジスタの内容を1つの文字列として取得 (行単位の場合は末尾に改行を含む)
+func (r Register) Text() string {
+	text := strings.Join(r.Lines, "\n")
+	if r.Kind == REG_LINES {
+		text += "\n"
+	}
+	return text
+}
+
+// レジスタへの書き込み
+// name: '"' (無名), 'a'~'z' (名前付き), 'A'~'Z' (名前付きへの追記), '_' (破棄)
+// 削除した内容は無名レジスタと'1'~'9' (1行未満の場合は'-')、コピーした内容は無名レジスタと'0'にも書き込む
+func (v *View) SetRegister(name rune, reg Register, deleted bool) {
+	if v.Registers == nil {
+		v.Registers = make(map[rune]Register)
+	}
+	switch {
+	case name == '_':
+		return
+	case name >= 'A' && name <= 'Z':
+		lower := name - 'A' + 'a'
+		if old, ok := v.Registers[lower]; ok {
+			reg = appendRegister(old, reg)
+		}
+		name = lower
+	}
+	v.Registers['"'] = reg
+	if name != '"' {
+		v.Registers[name] = reg
+		return
+	}
+	if !deleted {
+		v.Registers['0'] = reg
+		return
+	}
+	if reg.Kind == REG_CHARS && len(reg.Lines) == 1 {
+		v.Registers['-'] = reg
+		return
+	}
+	for n := '9'; n > '1'; n-- { // 古い削除の内容を1つずつずらす
+		if old, ok := v.Registers[n-1]; ok {
+			v.Registers[n] = old
+		}
+	}
+	v.Registers['1'] = reg
+}
+
+// レジスタの読み込み (名前付きの大文字は小文字と同じ)
+func (v *View) GetRegister(name rune) (Register, bool) {
+	if name >= 'A' && name <= 'Z' {
+		name = name - 'A' + 'a'
+	}
+	reg, ok := v.Registers[name]
+	return reg, ok
+}
+
+// レジスタの内容への追記 (行単位どうし以外は文字単位として連結する)
+func appendRegister(old Register, add Register) Register {
+	if old.Kind == REG_LINES || add.Kind == REG_LINES {
+		return Register{REG_LINES, append(append([]string{}, old.Lines...), add.Lines...)}
+	}
+	lines := append([]string{}, old.Lines...)
+	lines[len(lines)-1] += add.Lines[0]
+	return Register{REG_CHARS, append(lines, add.Lines[1:]...)}
+}
+
+// 選択範囲の内容をレジスタの形式で取得 (選択していない場合はfalse)
+func (e *Editor) SelectionRegister() (Register, bool) {
+	start, end, ok := e.Selection()
+	if !ok {
+		return Register{}, false
+	}
+	if e.SelMode == SELECT_BLOCK {
+		return Register{REG_BLOCK, e.BlockText()}, true
+	}
+	return Register{REG_CHARS, strings.Split(string(e.TextRange(start, end)), "\n")}, true
+}
+
+// 削除した内容の無名レジスタへの書き込み (直前のキー入力でも削除していた場合は追記する)
+func (v *View) kill(reg Register) {
+	if old, ok := v.GetRegister('"'); ok && v.killSeq != 0 && v.killSeq+1 == v.keySeq {
+		v.Registers['"'] = appendRegister(old, reg)
+	} else {
+		v.SetRegister('"', reg, true)
+	}
+	v.killSeq = v.keySeq
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// タブの内容をファイルに保存
+// 無題の場合は保存先のパスを入力し、新規ファイルの場合は作成の確認をしてから保存する
+// 保存に成功した場合はdoneを実行する (nil可)
+func (v *View) Save(b *Buffer, done func(v *View) uint8) uint8 {
+	if b.FilePath == "" {
+		v.OpenOverlay(NewPrompt("Save as: ", "", func(v *View, input string) uint8 {
+			if input == "" {
+				return 0
+			}
+			target := expandPath(input)
+			if other := v.FindBuffer(target); other != nil && other != b {
+				v.SetMessage("Error: %s is already open in another buffer", target)
+				return 0
+			}
+			if _, err := os.Stat(target); err == nil {
+				v.OpenOverlay(NewConfirm(fmt.Sprintf("%s already exists. Overwrite?", target), func(v *View) uint8 {
+					v.Buffers.SetFilePath(b, target)
+					return v.writeTab(b, done)
+				}))
+				return 0
+			}
+			v.Buffers.SetFilePath(b, target)
+			b.IsNew = true
+			if len(missingDirs(filepath.Dir(target))) == 0 { // 入力したパスに直接作成
+				return v.writeTab(b, done)
+			}
+			return v.Save(b, done)
+		}))
+		return 0
+	}
+
+	if _, err := os.Stat(b.FilePath); os.IsNotExist(err) {
+		message := fmt.Sprintf("Create %s?", b.FilePath)
+		if dirs := missingDirs(filepath.Dir(b.FilePath)); len(dirs) > 0 {
+			message = fmt.Sprintf("Create %s and directory %s?", b.FilePath, strings.Join(dirs, ", "))
+		}
+		v.OpenOverlay(NewConfirm(message, func(v *View) uint8 {
+			if err := os.MkdirAll(filepath.Dir(b.FilePath), 0755); err != nil {
+				v.SetMessage("Error: %v", err)
+				return 0
+			}
+			return v.writeTab(b, done)
+		}))
+		return 0
+	}
+	return v.writeTab(b, done)
+}
+
+// タブの内容を書き込み
+// 読み込んだ後にファイルが外部で変更されていた場合は先にマージ方法を選択する
+func (v *View) writeTab(b *Buffer, done func(v *View) uint8) uint8 {
+	if d, _ := b.changedOnDisk(); d != nil {
+		v.resolveDiskChange(b, d, done)
+		return 0
+	}
+	return v.storeTab(b, done)
+}
+
+// タブの内容を確認なしで書き込み
+func (v *View) storeTab(b *Buffer, done func(v *View) uint8) uint8 {
+	saveBytes, err := b.WriteFile()
+	if err != nil {
+		v.SetMessage("Error: %v", err)
+		return 0
+	}
+	v.UpdateTabBar()
+	v.watchFile(b)
+	v.SetMessage("Saved %s (%d bytes)", b.FilePath, saveBytes)
+	if done != nil {
+		return done(v)
+	}
+	return 0
+}
+
+// バッファの内容をファイルに上書きして保存済みにする
+func (b *Buffer) WriteFile() (saveBytes int, err error) {
+	saveBytes, err = b.SaveOverwrite(b.NL)
+	if err != nil {
+		return
+	}
+	b.IsSaved = true
+	b.IsNew = false
+	b.recordDisk()
+	if b.SwapPath != "" { // 保存した内容はスワップファイルから除く
+		b.syncSwap()
+	}
+	return
+}
+
+// 未保存のタブの一覧
+func (v *View) dirtyTabs() []*Buffer {
+	tabs := make([]*Buffer, 0)
+	for _, tab := range v.Buffers.All() {
+		if !tab.IsSaved {
+			tabs = append(tabs, tab)
+		}
+	}
+	return tabs
+}
+
+// 未保存のタブを順に保存・破棄・キャンセルから選択し、全て選択し終えたらdoneを実行する
+// キャンセル (またはESC) した場合と保存に失敗した場合はその時点で中止する
+func (v *View) guardUnsaved(tabs []*Buffer, done func(v *View) uint8) uint8 {
+	if len(tabs) == 0 {
+		return done(v)
+	}
+	b := tabs[0]
+	next := func(v *View) uint8 {
+		return v.guardUnsaved(tabs[1:], done)
+	}
+	message := fmt.Sprintf("Save changes to %s?", b.Title())
+	if len(tabs) > 1 {
+		titles := make([]string, len(tabs))
+		for i, tab := range tabs {
+			titles[i] = tab.Title()
+		}
+		message = fmt.Sprintf("Save changes to %s? (unsaved: %s)", b.Title(), strings.Join(titles, ", "))
+	}
+	v.focusTab(b)
+	v.Reflesh()
+	options := []ChoiceOption{{'s', "save"}, {'d', "discard"}, {'c', "cancel"}}
+	v.OpenOverlay(NewChoice(message, options, func(v *View, key rune) uint8 {
+		switch key {
+		case 's':
+			return v.Save(b, next)
+		case 'd':
+			return next(v)
+		}
+		return 0
+	}))
+	return 0
+}
+
+// タブを順に保存し、全て保存できたらdoneを実行する
+func (v *View) saveAll(tabs []*Buffer, done func(v *View) uint8) uint8 {
+	if len(tabs) == 0 {
+		return done(v)
+	}
+	v.focusTab(tabs[0])
+	v.Reflesh()
+	return v.Save(tabs[0], func(v *View) uint8 {
+		return v.saveAll(tabs[1:], done)
+	})
+}
+
+// 存在しない親ディレクトリの一覧 (浅い順)
+func missingDirs(dir string) []string {
+	dirs := make([]string, 0)
+	for dir != "." && dir != "/" {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		dirs = append([]string{dir}, dirs...)
+		dir = filepath.Dir(dir)
+	}
+	return dirs
+}
+
+// 入力されたパスの展開 ("~/"をホームディレクトリに置き換え)
+func expandPath(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
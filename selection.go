@@ -0,0 +1,370 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+// 選択範囲の種類
+type SelectionMode int8
+
+const (
+	SELECT_CHAR      SelectionMode = iota // 文字単位 (カーソル位置の文字は含まない)
+	SELECT_INCLUSIVE                      // 文字単位 (カーソル位置の文字を含む)
+	SELECT_LINE                           // 行単位
+	SELECT_BLOCK                          // 矩形 (起点とカーソルの表示上の列の間)
+)
+
+// カーソル位置の比較 (aがbより前の場合はtrue)
+func (a Cursor) Before(b Cursor) bool {
+	return a.Row < b.Row || (a.Row == b.Row && a.Col < b.Col)
+}
+
+// 選択の開始 (既に選択中の場合は起点を変えない)
+func (e *Editor) StartSelection() {
+	if e.Anchor == nil {
+		e.Anchor = &Cursor{Row: e.Cursor.Row, Col: e.Cursor.Col}
+	}
+}
+
+// 種類を指定した選択の開始 (選択中の場合は起点を変えずに種類のみ変更)
+func (e *Editor) StartSelectionMode(mode SelectionMode) {
+	e.StartSelection()
+	e.SelMode = mode
+}
+
+// 選択の解除 (選択していた場合はtrueを返す)
+func (e *Editor) ClearSelection() bool {
+	had := e.Anchor != nil
+	e.Anchor = nil
+	e.SelMode = SELECT_CHAR
+	return had
+}
+
+// 選択範囲の取得 (start < end の順、選択していないか空の場合はok=false)
+// 行単位の場合は最初の行の先頭から次の行の先頭まで (最終行の場合は行末まで)、矩形の場合は両端の位置
+func (e *Editor) Selection() (start Cursor, end Cursor, ok bool) {
+	if e.Anchor == nil {
+		return
+	}
+	start, end = Cursor{Row: e.Anchor.Row, Col: e.Anchor.Col}, Cursor{Row: e.Cursor.Row, Col: e.Cursor.Col}
+	if end.Before(start) {
+		start, end = end, start
+	}
+	switch e.SelMode {
+	case SELECT_CHAR:
+		if start == end {
+			return start, end, false
+		}
+	case SELECT_INCLUSIVE:
+		end = e.nextPos(end)
+	case SELECT_LINE:
+		start.Col = 1
+		end = e.nextPos(Cursor{Row: end.Row, Col: uint(e.Lines[end.Row-1].Length()) + 1})
+	case SELECT_BLOCK:
+		end.Col++
+	}
+	return start, end, true
+}
+
+// 1文字後の位置 (行末の場合は次の行の先頭、最終行の行末の場合はそのまま)
+func (b *Buffer) nextPos(pos Cursor) Cursor {
+	if pos.Col <= uint(b.Lines[pos.Row-1].Length()) {
+		return Cursor{Row: pos.Row, Col: pos.Col + 1}
+	}
+	if pos.Row < uint(len(b.Lines)) {
+		return Cursor{Row: pos.Row + 1, Col: 1}
+	}
+	return pos
+}
+
+// 矩形選択の表示上の列の範囲 [left, right) (0始まりのセル数)
+// カーソルが短い行にあり、上下移動で保っている列が行末より右にある場合はその列までとする
+func (e *Editor) BlockCells() (left int, right int) {
+	cell := func(c *Cursor) (int, int) {
+		line := e.Lines[c.Row-1].GetAll()
+		start := utils.CellsBefore(line, int(c.Col-1), int(e.TabSize))
+		width := 1
+		if int(c.Col) <= len(line) {
+			width = utils.CellsBefore(line, int(c.Col), int(e.TabSize)) - start
+		}
+		return start, start + width
+	}
+	al, ar := cell(e.Anchor)
+	cl, cr := cell(e.Cursor)
+	if want := int(e.Cursor.Want) - 1; want > cl {
+		cl, cr = want, want+1
+	}
+	return min(al, cl), max(ar, cr)
+}
+
+// 矩形選択の指定した行の列の範囲 [from, to) (1始まり、行が短い場合は空)
+func (e *Editor) blockCols(row uint) (from uint, to uint) {
+	left, right := e.BlockCells()
+	line := e.Lines[row-1].GetAll()
+	from = uint(utils.IndexAtCell(line, left, int(e.TabSize))) + 1
+	to = uint(utils.IndexAtCell(line, right-1, int(e.TabSize))) + 1
+	if to <= uint(len(line)) {
+		to++
+	}
+	return from, to
+}
+
+// 行内の選択されている列の範囲 [from, to) (1始まり、行末の改行が選択されている場合はtoが行の長さ+2)
+func (e *Editor) selectedCols(row uint) (from uint, to uint, ok bool) {
+	start, end, ok := e.Selection()
+	if !ok || row < start.Row || row > end.Row {
+		return 0, 0, false
+	}
+	if e.SelMode == SELECT_BLOCK {
+		from, to = e.blockCols(row)
+		return from, to, from < to
+	}
+	if e.SelMode == SELECT_LINE {
+		if row == end.Row && end.Col == 1 && row > start.Row {
+			return 0, 0, false
+		}
+		return 1, uint(e.Lines[row-1].Length()) + 2, true
+	}
+	from, to = 1, uint(e.Lines[row-1].Length())+2
+	if row == start.Row {
+		from = start.Col
+	}
+	if row == end.Row {
+		to = end.Col
+	}
+	return from, to, from < to
+}
+
+// 選択範囲の大きさ (行数・文字数、改行は1文字として数える)
+func (e *Editor) SelectionSize() (lines int, chars int) {
+	start, end, ok := e.Selection()
+	if !ok {
+		return 0, 0
+	}
+	if e.SelMode == SELECT_BLOCK {
+		for row := start.Row; row <= end.Row; row++ {
+			from, to := e.blockCols(row)
+			if from < to {
+				chars += int(to - from)
+			}
+		}
+		return int(end.Row-start.Row) + 1, chars
+	}
+	if start.Row == end.Row {
+		return 1, int(end.Col - start.Col)
+	}
+	chars = e.Lines[start.Row-1].Length() - int(start.Col) + 2
+	for row := start.Row + 1; row < end.Row; row++ {
+		chars += e.Lines[row-1].Length() + 1
+	}
+	chars += int(end.Col) - 1
+	return int(end.Row-start.Row) + 1, chars
+}
+
+// 範囲内の文字列の取得 (行の区切りは\n)
+func (b *Buffer) TextRange(start Cursor, end Cursor) []rune {
+	if start.Row == end.Row {
+		return b.Lines[start.Row-1].GetFrom(int(start.Col-1), int(end.Col-1))
+	}
+	first := b.Lines[start.Row-1]
+	text := append(first.GetFrom(int(start.Col-1), first.Length()), '\n')
+	for row := start.Row + 1; row < end.Row; row++ {
+		text = append(append(text, b.Lines[row-1].GetAll()...), '\n')
+	}
+	return append(text, b.Lines[end.Row-1].GetFrom(0, int(end.Col-1))...)
+}
+
+// 範囲内の文字列の削除 (endの位置の文字は残す)
+func (b *Buffer) DeleteRange(start Cursor, end Cursor) {
+	first := b.Lines[start.Row-1]
+	b.changedFrom(start.Row)
+	if start.Row == end.Row {
+		first.EraseFrom(int(start.Col-1), int(end.Col-1))
+		b.IsSaved = false
+		return
+	}
+	last := b.Lines[end.Row-1]
+	tail := last.GetFrom(int(end.Col-1), last.Length())
+	first.EraseFrom(int(start.Col-1), first.Length())
+	first.AppendAll(tail)
+	for row := end.Row; row > start.Row; row-- {
+		b.DeleteLine(uint(row - 1))
+	}
+	b.IsSaved = false
+}
+
+// 選択範囲の削除 (カーソルは範囲の先頭に移動し、選択していなかった場合はfalseを返す)
+func (e *Editor) DeleteSelection() bool {
+	start, end, ok := e.Selection()
+	block := e.SelMode == SELECT_BLOCK
+	if !ok {
+		e.ClearSelection()
+		return false
+	}
+	if block {
+		e.DeleteBlock()
+		return true
+	}
+	e.ClearSelection()
+	e.DeleteRange(start, end)
+	*e.Cursor = start
+	return true
+}
+
+// 矩形選択の各行の内容 (行が短い場合は空文字列)
+func (e *Editor) BlockText() []string {
+	start, end, _ := e.Selection()
+	lines := make([]string, 0, end.Row-start.Row+1)
+	for row := start.Row; row <= end.Row; row++ {
+		from, to := e.blockCols(row)
+		if from >= to {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, string(e.Lines[row-1].GetFrom(int(from-1), int(to-1))))
+	}
+	return lines
+}
+
+// 矩形選択の範囲の削除 (カーソルは左上に移動)
+func (e *Editor) DeleteBlock() {
+	start, end, _ := e.Selection()
+	top := start.Row
+	col := uint(0)
+	for row := start.Row; row <= end.Row; row++ {
+		from, to := e.blockCols(row)
+		if row == top {
+			col = from
+		}
+		if from < to {
+			e.Lines[row-1].EraseFrom(int(from-1), int(to-1))
+			e.changedFrom(row)
+			e.IsSaved = false
+		}
+	}
+	e.ClearSelection()
+	e.Cursor.Row = top
+	e.MoveTargetCol(col)
+	e.clampCursor()
+}
+
+// 行の範囲[top, bottom]の各行の表示上の列cellへのカーソルの配置 (主カーソルは先頭の行、cellが負の場合は行末)
+// padの場合は列に届かない短い行を空白で埋め、それ以外は短い行にはカーソルを置かない (配置しなかった場合はfalse)
+func (e *Editor) PlaceColumnCarets(top uint, bottom uint, cell int, pad bool) bool {
+	e.ClearSelection()
+	e.ClearCarets()
+	tabSize := int(e.TabSize)
+	placed := false
+	for row := top; row <= bottom; row++ {
+		line := e.LineRunes(row)
+		width := utils.CellsBefore(line, len(line), tabSize)
+		at := cell
+		if cell < 0 {
+			at = width
+		}
+		if width < at {
+			if !pad {
+				continue
+			}
+			e.Lines[row-1].AppendAll([]rune(strings.Repeat(" ", at-width)))
+			e.changedFrom(row)
+			e.IsSaved = false
+			line = e.LineRunes(row)
+		}
+		pos := Cursor{Row: row, Col: uint(utils.IndexAtCell(line, at, tabSize)) + 1}
+		if !placed {
+			*e.Cursor = pos
+			placed = true
+			continue
+		}
+		e.Carets = append(e.Carets, Caret{Cursor: pos})
+	}
+	return placed
+}
+
+// 矩形選択を各行の矩形内を選択したカーソルに分割 (padの場合は短い行を空白で埋め、それ以外は矩形内に文字がない行を除く)
+// 分割したカーソルがない場合はfalseを返す
+func (e *Editor) SplitBlock(pad bool) bool {
+	start, end, _ := e.Selection()
+	left, _ := e.BlockCells()
+	ranges := make(map[uint][2]uint)
+	for row := start.Row; row <= end.Row; row++ {
+		if from, to := e.blockCols(row); from < to {
+			ranges[row] = [2]uint{from, to}
+		}
+	}
+	if !e.PlaceColumnCarets(start.Row, end.Row, left, pad) {
+		e.MoveTargetRow(start.Row)
+		return false
+	}
+	selectRow := func(c *Cursor) *Cursor {
+		r, ok := ranges[c.Row]
+		if !ok {
+			return nil
+		}
+		c.Col = r[1]
+		return &Cursor{Row: c.Row, Col: r[0]}
+	}
+	carets := append([]Caret{{Cursor: *e.Cursor}}, e.Carets...)
+	e.Carets = nil
+	kept := 0
+	for _, c := range carets {
+		c.Anchor = selectRow(&c.Cursor)
+		if c.Anchor == nil && !pad { // 削除の場合は矩形内に文字がない行を除く
+			continue
+		}
+		if kept == 0 {
+			*e.Cursor, e.Anchor = c.Cursor, c.Anchor
+		} else {
+			e.Carets = append(e.Carets, c)
+		}
+		kept++
+	}
+	return kept > 0
+}
+
+// 矩形選択への入力・削除の前に各行のカーソルに分割 (入力の場合は短い行を空白で埋める)
+// 矩形内に編集する文字がない場合はfalseを返す
+func (v *View) splitBlockSelection(pad bool) bool {
+	e := v.GetCurrentTab()
+	if e.Anchor == nil || e.SelMode != SELECT_BLOCK || len(e.Carets) > 0 {
+		return true
+	}
+	v.saveTypingUndo()
+	return e.SplitBlock(pad)
+}
+
+// 矩形選択を広げる移動 (左右の移動は行末を越えて表示上の列で移動する)
+func (v *View) blockSelect(dRow int, dCell int) uint8 {
+	e := v.GetCurrentTab()
+	e.StartSelectionMode(SELECT_BLOCK)
+	switch {
+	case dRow < 0:
+		e.MovePrevRow()
+	case dRow > 0:
+		e.MoveNextRow()
+	}
+	if dCell != 0 {
+		cell := int(e.DisplayCol()) - 1
+		if want := int(e.Cursor.Want) - 1; want > cell {
+			cell = want
+		}
+		cell = max(cell+dCell, 0)
+		e.MoveTargetCol(uint(utils.IndexAtCell(e.currentLine(), cell, int(e.TabSize))) + 1)
+		if cell > int(e.DisplayCol())-1 { // 行末より右の列は上下移動と同様に保つ
+			e.Cursor.Want = uint(cell) + 1
+		}
+	}
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	return 0
+}
+
+// 選択の解除と再描画
+func (v *View) clearSelection() {
+	if v.GetCurrentTab().ClearSelection() {
+		v.RefleshTextField()
+	}
+}
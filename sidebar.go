@@ -0,0 +1,480 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+const SIDEBAR_WIDTH = 30 // ファイルツリーの幅 (境界線を含む)
+
+// ファイルツリーのノード
+type TreeNode struct {
+	Path     string      // ファイルのパス
+	Name     string      // 表示名
+	IsDir    bool        // ディレクトリかどうか
+	Expanded bool        // 展開中かどうか (ディレクトリのみ)
+	Depth    int         // ルートからの深さ
+	Parent   *TreeNode   // 親ノード (ルートはnil)
+	Children []*TreeNode // 子ノード (展開時に読み込み)
+}
+
+// 新しいノードの取得
+func NewTreeNode(path string, isDir bool, parent *TreeNode) (node *TreeNode) {
+	node = new(TreeNode)
+	node.Path = path
+	node.Name = filepath.Base(path)
+	node.IsDir = isDir
+	node.Parent = parent
+	if parent != nil {
+		node.Depth = parent.Depth + 1
+	}
+	return
+}
+
+// ディレクトリの内容を読み込み (ディレクトリ・名前順)
+// 読み込み済みの子ノードは展開状態を引き継ぐ
+func (node *TreeNode) Load() error {
+	entries, err := os.ReadDir(node.Path)
+	if err != nil {
+		return err
+	}
+	old := make(map[string]*TreeNode)
+	for _, child := range node.Children {
+		old[child.Name] = child
+	}
+	children := make([]*TreeNode, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Name() == ".git" {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			if info, err := os.Stat(filepath.Join(node.Path, entry.Name())); err == nil {
+				isDir = info.IsDir()
+			}
+		}
+		if child, ok := old[entry.Name()]; ok && child.IsDir == isDir {
+			children = append(children, child)
+			continue
+		}
+		children = append(children, NewTreeNode(filepath.Join(node.Path, entry.Name()), isDir, node))
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		if children[i].IsDir != children[j].IsDir {
+			return children[i].IsDir
+		}
+		return strings.ToLower(children[i].Name) < strings.ToLower(children[j].Name)
+	})
+	node.Children = children
+	return nil
+}
+
+// ファイルツリー構造体
+type Sidebar struct {
+	Root     *TreeNode
+	Rows     []*TreeNode // 表示中のノード (展開状態を反映した順)
+	Selected int         // 選択中の行
+	Scroll   int         // 表示中の最上行
+	Width    uint        // 表示幅 (境界線を含む)
+	Visible  bool        // 表示中かどうか
+	Focused  bool        // キー入力を受け付けているかどうか
+	watcher  *core.Watcher
+}
+
+// 新しいファイルツリーの取得
+func NewSidebar(root string) (s *Sidebar) {
+	s = new(Sidebar)
+	s.Root = NewTreeNode(filepath.Clean(root), true, nil)
+	s.Root.Name = s.Root.Path
+	s.Root.Expanded = true
+	s.Width = SIDEBAR_WIDTH
+	s.Visible = true
+	s.Focused = true
+	if w, err := core.NewWatcher(); err == nil {
+		s.watcher = w
+	}
+	s.expand(s.Root)
+	s.rebuild()
+	return
+}
+
+// ファイルツリーの終了 (変更監視の停止)
+func (s *Sidebar) Close() {
+	if s.watcher != nil {
+		s.watcher.Close()
+	}
+}
+
+// ディレクトリの展開 (内容を読み込んで変更監視を開始)
+func (s *Sidebar) expand(node *TreeNode) error {
+	if err := node.Load(); err != nil {
+		return err
+	}
+	node.Expanded = true
+	if s.watcher != nil {
+		_ = s.watcher.Add(node.Path)
+	}
+	return nil
+}
+
+// ディレクトリを折りたたむ
+func (s *Sidebar) collapse(node *TreeNode) {
+	node.Expanded = false
+	if s.watcher != nil && node != s.Root {
+		s.watcher.Remove(node.Path)
+	}
+}
+
+// 表示行の再構築
+// 選択中のノードが無くなった場合は同じ位置の行を選択
+func (s *Sidebar) rebuild() {
+	selectedPath := ""
+	if node := s.SelectedNode(); node != nil {
+		selectedPath = node.Path
+	}
+	s.Rows = make([]*TreeNode, 0)
+	var walk func(node *TreeNode)
+	walk = func(node *TreeNode) {
+		for _, child := range node.Children {
+			s.Rows = append(s.Rows, child)
+			if child.IsDir && child.Expanded {
+				walk(child)
+			}
+		}
+	}
+	walk(s.Root)
+	s.Select(selectedPath)
+}
+
+// パスを指定して行を選択
+func (s *Sidebar) Select(path string) {
+	for i, node := range s.Rows {
+		if node.Path == path {
+			s.Selected = i
+			return
+		}
+	}
+	if s.Selected >= len(s.Rows) {
+		s.Selected = len(s.Rows) - 1
+	}
+	if s.Selected < 0 {
+		s.Selected = 0
+	}
+}
+
+// パスに対応する展開中のノードの取得
+func (s *Sidebar) findDir(path string) *TreeNode {
+	if path == s.Root.Path {
+		return s.Root
+	}
+	for _, node := range s.Rows {
+		if node.Path == path && node.IsDir && node.Expanded {
+			return node
+		}
+	}
+	return nil
+}
+
+// 選択中のノード (空の場合はnil)
+func (s *Sidebar) SelectedNode() *TreeNode {
+	if s.Selected < len(s.Rows) {
+		return s.Rows[s.Selected]
+	}
+	return nil
+}
+
+// 選択中のノードを基準にした新規作成先のディレクトリ
+func (s *Sidebar) targetDir() *TreeNode {
+	node := s.SelectedNode()
+	if node == nil {
+		return s.Root
+	}
+	if node.IsDir && node.Expanded {
+		return node
+	}
+	if node.Parent != nil {
+		return node.Parent
+	}
+	return s.Root
+}
+
+// ディレクトリの再読み込み
+func (s *Sidebar) Reload(node *TreeNode) {
+	_ = node.Load()
+	for _, child := range node.Children {
+		if child.IsDir && child.Expanded {
+			s.Reload(child)
+		}
+	}
+}
+
+// ファイルの変更通知の反映
+func (s *Sidebar) Receive(v *View, ev core.WatchEvent, ok bool) {
+	if !ok {
+		s.watcher = nil
+		return
+	}
+	node := s.findDir(ev.Dir)
+	if node == nil {
+		return
+	}
+	_ = node.Load()
+	s.rebuild()
+	if s.Visible {
+		v.DrawSidebar()
+		v.RefleshCursor()
+	}
+}
+
+// 表示可能な行数
+func (s *Sidebar) height(v *View) int {
+	return int(v.WinRow) - 2
+}
+
+// 選択行が見えるようにスクロール
+func (s *Sidebar) adjustScroll(v *View) {
+	if s.Selected < s.Scroll {
+		s.Scroll = s.Selected
+	}
+	if h := s.height(v); s.Selected >= s.Scroll+h {
+		s.Scroll = s.Selected - h + 1
+	}
+}
+
+func (s *Sidebar) CursorPos(v *View) (col uint, row uint) {
+	return 1, uint(s.Selected-s.Scroll) + 2
+}
+
+// ファイルツリーの描画
+func (v *View) DrawSidebar() {
+	if v.suspended {
+		return
+	}
+	s := v.Sidebar
+	if s == nil || !s.Visible {
+		return
+	}
+	defer v.Term.ResetStyle()
+	s.adjustScroll(v)
+	width := int(s.Width) - 1
+	for i := 0; i < s.height(v); i++ {
+		v.Term.MoveCursorPos(1, uint(i)+2)
+		v.Term.ResetStyle()
+		idx := s.Scroll + i
+		if i == 0 && s.Scroll == 0 && len(s.Rows) == 0 {
+			v.Term.SetBGColor(234)
+			v.Term.SetColor(245)
+			fmt.Print(padRight(" (empty)", width))
+		} else if idx < len(s.Rows) {
+			node := s.Rows[idx]
+			bg := uint8(234)
+			if idx == s.Selected {
+				if s.Focused {
+					bg = 25
+				} else {
+					bg = 237
+				}
+			}
+			v.Term.SetBGColor(bg)
+			icon := "  "
+			if node.IsDir {
+				if node.Expanded {
+					icon = "▾ "
+				} else {
+					icon = "▸ "
+				}
+				v.Term.SetBold()
+			}
+			label := strings.Repeat("  ", node.Depth-1) + icon + node.Name
+			if node.IsDir {
+				label += "/"
+			}
+			fmt.Print(padRight(" "+label, width))
+		} else {
+			v.Term.SetBGColor(234)
+			fmt.Print(padRight("", width))
+		}
+		v.Term.ResetStyle()
+		v.Term.SetColor(240)
+		fmt.Print("│")
+	}
+}
+
+// ファイルツリーでのキー入力の処理
+// ファイルツリーで処理しないキーはhandled=falseを返す
+func (s *Sidebar) HandleKey(v *View, r rune) (handled bool, exitCode uint8) {
+	node := s.SelectedNode()
+	switch r {
+	case KEY_UP, 'k':
+		if s.Selected > 0 {
+			s.Selected--
+		}
+	case KEY_DOWN, 'j':
+		if s.Selected < len(s.Rows)-1 {
+			s.Selected++
+		}
+	case KEY_RIGHT, 'l': // Expand
+		if node != nil && node.IsDir && !node.Expanded {
+			if err := s.expand(node); err != nil {
+				v.SetMessage("Error: %v", err)
+			}
+			s.rebuild()
+		}
+	case KEY_LEFT, 'h': // Collapse / Parent
+		if node == nil {
+			break
+		}
+		if node.IsDir && node.Expanded {
+			s.collapse(node)
+		} else if node.Parent != nil && node.Parent != s.Root {
+			s.collapse(node.Parent)
+			s.rebuild()
+			s.Select(node.Parent.Path)
+		}
+		s.rebuild()
+	case CTRL_M: // Open / Toggle
+		if node == nil {
+			break
+		}
+		if node.IsDir {
+			if node.Expanded {
+				s.collapse(node)
+			} else if err := s.expand(node); err != nil {
+				v.SetMessage("Error: %v", err)
+			}
+			s.rebuild()
+			break
+		}
+		s.Focused = false
+		v.OpenFile(node.Path)
+		return true, 0
+	case 'a': // Create
+		s.promptCreate(v)
+		return true, 0
+	case 'r': // Rename
+		if node != nil {
+			s.promptRename(v, node)
+		}
+		return true, 0
+	case 'd': // Delete
+		if node != nil {
+			s.confirmDelete(v, node)
+		}
+		return true, 0
+	case 'R': // Reload
+		s.Reload(s.Root)
+		s.rebuild()
+	case ESC, CTRL_I: // Focus Editor
+		s.Focused = false
+	default:
+		return isInsertable(r) || r == BACKSPACE, 0
+	}
+	v.DrawSidebar()
+	v.RefleshCursor()
+	return true, 0
+}
+
+// ファイル・ディレクトリの新規作成 (名前が'/'で終わる場合はディレクトリ)
+func (s *Sidebar) promptCreate(v *View) {
+	dir := s.targetDir()
+	label := fmt.Sprintf("New file in %s/: ", dir.Path)
+	v.OpenOverlay(NewPrompt(label, "", func(v *View, input string) uint8 {
+		if input == "" {
+			return 0
+		}
+		target := filepath.Join(dir.Path, input)
+		isDir := strings.HasSuffix(input, "/")
+		v.OpenOverlay(NewConfirm(fmt.Sprintf("Create %s?", target), func(v *View) uint8 {
+			var err error
+			if isDir {
+				err = os.MkdirAll(target, 0755)
+			} else if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				var fp *os.File
+				if fp, err = os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); err == nil {
+					fp.Close()
+				}
+			}
+			if err != nil {
+				v.SetMessage("Error: %v", err)
+				return 0
+			}
+			s.Reload(dir)
+			s.rebuild()
+			s.Select(filepath.Clean(target))
+			if !isDir {
+				s.Focused = false
+				v.OpenFile(target)
+			} else {
+				v.Reflesh()
+			}
+			return 0
+		}))
+		return 0
+	}))
+}
+
+// ファイル・ディレクトリの名前の変更
+func (s *Sidebar) promptRename(v *View, node *TreeNode) {
+	v.OpenOverlay(NewPrompt("Rename to: ", node.Name, func(v *View, input string) uint8 {
+		if input == "" || input == node.Name {
+			return 0
+		}
+		target := filepath.Join(filepath.Dir(node.Path), input)
+		v.OpenOverlay(NewConfirm(fmt.Sprintf("Rename %s to %s?", node.Path, target), func(v *View) uint8 {
+			if _, err := os.Stat(target); err == nil {
+				v.SetMessage("Error: %s already exists", target)
+				return 0
+			}
+			if err := os.Rename(node.Path, target); err != nil {
+				v.SetMessage("Error: %v", err)
+				return 0
+			}
+			for _, tab := range v.Buffers.All() { // 開いているタブのパスも変更
+				if tab.FilePath == node.Path {
+					v.Buffers.SetFilePath(tab, target)
+				} else if rel, err := filepath.Rel(node.Path, tab.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+					v.Buffers.SetFilePath(tab, filepath.Join(target, rel))
+				}
+				v.watchFile(tab)
+			}
+			if node.Parent != nil {
+				s.Reload(node.Parent)
+			}
+			s.rebuild()
+			s.Select(target)
+			v.Reflesh()
+			return 0
+		}))
+		return 0
+	}))
+}
+
+// ファイル・ディレクトリの削除
+func (s *Sidebar) confirmDelete(v *View, node *TreeNode) {
+	message := fmt.Sprintf("Delete %s?", node.Path)
+	if node.IsDir {
+		message = fmt.Sprintf("Delete directory %s and all of its contents?", node.Path)
+	}
+	v.OpenOverlay(NewConfirm(message, func(v *View) uint8 {
+		if err := os.RemoveAll(node.Path); err != nil {
+			v.SetMessage("Error: %v", err)
+			return 0
+		}
+		for _, tab := range v.Buffers.All() { // 削除したファイルを開いているタブは未保存にする
+			if rel, err := filepath.Rel(node.Path, tab.FilePath); err == nil && !strings.HasPrefix(rel, "..") {
+				tab.IsSaved = false
+			}
+		}
+		if node.Parent != nil {
+			s.Reload(node.Parent)
+		}
+		s.rebuild()
+		v.Reflesh()
+		return 0
+	}))
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const (
+	STATUS_TICK      = time.Second     // 時計の表示の更新間隔
+	GIT_BRANCH_CACHE = 2 * time.Second // Gitのブランチ名を読み直す間隔
+	STATUS_NAME_MIN  = 8               // 切り詰めたファイル名の最小幅
+)
+
+// ステータスバーのセグメント
+type StatusSegment struct {
+	Priority int                  // 幅が足りない場合は小さいものから非表示にする
+	Shrink   bool                 // 非表示にする前に先頭を切り詰めるかどうか
+	Text     func(v *View) string // 表示する文字列 (空の場合は表示しない)
+}
+
+// 利用可能なセグメント (セグメント名 -> セグメント)
+var statusSegments = map[string]StatusSegment{
+	"mode":        {90, false, (*View).statusMode},
+	"filename":    {80, true, (*View).statusFilename},
+	"modified":    {85, false, (*View).statusModified},
+	"language":    {40, false, (*View).statusLanguage},
+	"encoding":    {20, false, (*View).statusEncoding},
+	"line_ending": {30, false, (*View).statusLineEnding},
+	"tab_size":    {25, false, (*View).statusTabSize},
+	"position":    {100, false, (*View).statusPosition},
+	"percent":     {50, false, (*View).statusPercent},
+	"selection":   {70, false, (*View).statusSelection},
+	"git_branch":  {35, false, (*View).statusGitBranch},
+	"diagnostics": {55, false, (*View).statusDiagnostics},
+	"conflicts":   {60, false, (*View).statusConflicts},
+	"clock":       {10, false, (*View).statusClock},
+}
+
+// デフォルトのステータスバーの表示項目
+var defaultStatusLine = StatusLineConfig{
+	Left:   []string{"mode", "filename", "modified"},
+	Center: []string{},
+	Right:  []string{"selection", "diagnostics", "conflicts", "git_branch", "language", "tab_size", "encoding", "line_ending", "position", "percent"},
+}
+
+// ステータスバーの表示項目 (設定ファイルで指定がない場合はデフォルト)
+func (c *Config) statusLine() StatusLineConfig {
+	if c.StatusLine == nil {
+		return defaultStatusLine
+	}
+	return *c.StatusLine
+}
+
+// 入力の状態の名前
+func (v *View) ModeName() string {
+	if _, ok := v.Overlay.(*WindowMode); ok {
+		return "WINDOW"
+	}
+	if v.Sidebar != nil && v.Sidebar.Focused {
+		return "TREE"
+	}
+	if v.Layer != nil {
+		if name := v.Layer.ModeName(); name != "" {
+			return name
+		}
+	}
+	return "EDIT"
+}
+
+// モード名 (マクロの記録中はレジスタ名を付ける)
+func (v *View) statusMode() string {
+	if v.recording != 0 {
+		return fmt.Sprintf("%s @%c", v.ModeName(), v.recording)
+	}
+	return v.ModeName()
+}
+
+func (v *View) statusFilename() string {
+	return tabLabels(v.Tabs)[v.TabIndex()]
+}
+
+func (v *View) statusModified() string {
+	if v.GetCurrentTab().IsSaved {
+		return ""
+	}
+	return "[+]"
+}
+
+func (v *View) statusLanguage() string {
+	return LanguageOf(v.GetCurrentTab().FilePath)
+}
+
+// ファイルは常にUTF-8として読み書きする
+func (v *View) statusEncoding() string {
+	return "UTF-8"
+}
+
+func (v *View) statusLineEnding() string {
+	switch v.GetCurrentTab().NL {
+	case utils.CRLF:
+		return "CRLF"
+	case utils.CR:
+		return "CR"
+	case utils.LF:
+		return "LF"
+	}
+	return "Unknown"
+}
+
+func (v *View) statusTabSize() string {
+	return fmt.Sprintf("Tab Size: %d", v.GetCurrentTab().TabSize)
+}
+
+func (v *View) statusPosition() string {
+	e := v.GetCurrentTab()
+	return fmt.Sprintf("Ln %d, Col %d", e.Cursor.Row, e.Cursor.Col)
+}
+
+// ファイル内のカーソル行の位置 (先頭はTop、末尾はBot)
+func (v *View) statusPercent() string {
+	e := v.GetCurrentTab()
+	switch {
+	case e.Cursor.Row <= 1:
+		return "Top"
+	case e.Cursor.Row >= uint(len(e.Lines)):
+		return "Bot"
+	}
+	return fmt.Sprintf("%d%%", (e.Cursor.Row-1)*100/uint(len(e.Lines)-1))
+}
+
+// 選択範囲の大きさ (選択していない場合は空、複数カーソルの場合はカーソルの数)
+func (v *View) statusSelection() string {
+	if n := len(v.GetCurrentTab().Carets); n > 0 {
+		return fmt.Sprintf("%d cursors", n+1)
+	}
+	lines, chars := v.GetCurrentTab().SelectionSize()
+	switch {
+	case chars == 0:
+		return ""
+	case lines > 1:
+		return fmt.Sprintf("%d lines, %d chars", lines, chars)
+	}
+	return fmt.Sprintf("%d chars", chars)
+}
+
+// 現在のファイル (無題の場合は作業ディレクトリ) のGitのブランチ名
+func (v *View) statusGitBranch() string {
+	dir := "."
+	if path := v.GetCurrentTab().FilePath; path != "" {
+		dir = filepath.Dir(path)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if c, ok := v.gitBranches[abs]; ok && time.Since(c.Time) < GIT_BRANCH_CACHE {
+		return c.Name
+	}
+	name := gitBranch(abs)
+	if v.gitBranches == nil {
+		v.gitBranches = make(map[string]gitBranchCache)
+	}
+	v.gitBranches[abs] = gitBranchCache{name, time.Now()}
+	return name
+}
+
+// 未解決のマージの競合の数
+func (v *View) statusConflicts() string {
+	n := v.GetCurrentTab().ConflictCount()
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d conflict(s)", n)
+}
+
+// 診断の数 (エラー・警告など)
+func (v *View) statusDiagnostics() string {
+	n := diagnosticCount(v.GetCurrentTab().Buffer)
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d diagnostic(s)", n)
+}
+
+// バッファの診断の数を求める関数 (診断の提供元を接続するまでは常に0)
+var diagnosticCount = func(b *Buffer) int {
+	return 0
+}
+
+func (v *View) statusClock() string {
+	return time.Now().Format("15:04")
+}
+
+// 読み込んだブランチ名
+type gitBranchCache struct {
+	Name string
+	Time time.Time
+}
+
+// ディレクトリを含むGitリポジトリの現在のブランチ名 (リポジトリでない場合は空)
+// ブランチでない場合はコミットハッシュの先頭7文字
+func gitBranch(dir string) string {
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil {
+			if !info.IsDir() { // ワークツリー (gitdir: <パス>)
+				data, err := os.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			ref := strings.TrimSpace(string(head))
+			if name, ok := strings.CutPrefix(ref, "ref: refs/heads/"); ok {
+				return name
+			}
+			if len(ref) > 7 {
+				return ref[:7]
+			}
+			return ref
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// 表示するセグメント
+type statusItem struct {
+	Name     string
+	Text     string
+	Priority int
+	Shrink   bool
+}
+
+// 表示するセグメントの一覧 (空のものと未知のセグメント名は除く)
+func (v *View) statusItems(names []string) []statusItem {
+	items := make([]statusItem, 0, len(names))
+	for _, name := range names {
+		seg, ok := statusSegments[name]
+		if !ok {
+			continue
+		}
+		if text := seg.Text(v); text != "" {
+			items = append(items, statusItem{name, text, seg.Priority, seg.Shrink})
+		}
+	}
+	return items
+}
+
+// セグメントを並べた幅 (各セグメントの前後の空白と区切り線を含む)
+func statusWidth(items []statusItem) int {
+	if len(items) == 0 {
+		return 0
+	}
+	w := len(items) - 1
+	for _, item := range items {
+		w += len([]rune(item.Text)) + 2
+	}
+	return w
+}
+
+// 幅に収まるようにセグメントを減らす
+// 優先度の低いものから非表示にし、切り詰め可能なものは最小幅まで先頭を切り詰めてから非表示にする
+func fitStatus(groups [][]statusItem, width int) {
+	for {
+		total := 0
+		used := 0
+		for _, g := range groups {
+			if w := statusWidth(g); w > 0 {
+				total += w
+				used++
+			}
+		}
+		if used > 1 {
+			total += used - 1 // グループ間の空白
+		}
+		if total <= width {
+			return
+		}
+		gi, ii := -1, -1
+		for i, g := range groups {
+			for j, item := range g {
+				if gi < 0 || item.Priority < groups[gi][ii].Priority {
+					gi, ii = i, j
+				}
+			}
+		}
+		if gi < 0 {
+			return
+		}
+		item := &groups[gi][ii]
+		rs := []rune(item.Text)
+		if over := total - width; item.Shrink && len(rs) > STATUS_NAME_MIN {
+			keep := len(rs) - over - 1
+			if keep < STATUS_NAME_MIN-1 {
+				keep = STATUS_NAME_MIN - 1
+			}
+			item.Text = "…" + string(rs[len(rs)-keep:])
+			item.Shrink = false
+			continue
+		}
+		groups[gi] = append(groups[gi][:ii], groups[gi][ii+1:]...)
+	}
+}
+
+// セグメントの描画
+func (v *View) drawStatusItems(items []statusItem) {
+	for i, item := range items {
+		if i > 0 {
+			v.Config.Style("status.separator").Apply(v.Term)
+			fmt.Print("|")
+		}
+		v.Config.Style("status." + item.Name).Apply(v.Term)
+		fmt.Printf(" %s ", item.Text)
+	}
+	v.Term.ResetStyle()
+}
+
+// ステータスバーの描画 (メッセージがある場合はメッセージを表示)
+func (v *View) UpdateStatusBar() {
+	if v.suspended {
+		return
+	}
+	defer v.RefleshCursor()
+	defer v.Term.ResetStyle()
+	width := int(v.WinCol)
+	v.Term.MoveCursorPos(1, uint(v.WinRow))
+	if v.Message != "" {
+		v.Config.Style("status.message").Apply(v.Term)
+		fmt.Printf(" %s", padRight(v.Message, width-1))
+		return
+	}
+	v.Config.Style("status").Apply(v.Term)
+	fmt.Print(strings.Repeat(" ", width))
+
+	conf := v.Config.statusLine()
+	groups := [][]statusItem{v.statusItems(conf.Left), v.statusItems(conf.Center), v.statusItems(conf.Right)}
+	fitStatus(groups, width)
+	left, center, right := groups[0], groups[1], groups[2]
+	leftWidth, centerWidth, rightWidth := statusWidth(left), statusWidth(center), statusWidth(right)
+
+	v.Term.MoveCursorPos(1, uint(v.WinRow))
+	v.drawStatusItems(left)
+	if centerWidth > 0 { // 中央に置けない場合は左右のセグメントの間に寄せる
+		col := (width - centerWidth) / 2
+		if col < leftWidth+1 {
+			col = leftWidth + 1
+		}
+		if col+centerWidth > width-rightWidth-1 && rightWidth > 0 {
+			col = width - rightWidth - 1 - centerWidth
+		}
+		v.Term.MoveCursorPos(uint(col+1), uint(v.WinRow))
+		v.drawStatusItems(center)
+	}
+	if rightWidth > 0 {
+		v.Term.MoveCursorPos(uint(width-rightWidth+1), uint(v.WinRow))
+		v.drawStatusItems(right)
+	}
+	v.lastClock = v.statusClock()
+}
+
+// 時計の表示の更新 (表示している場合のみ)
+func (v *View) TickStatusBar() {
+	if v.Overlay != nil || v.Message != "" || v.lastClock == v.statusClock() {
+		return
+	}
+	conf := v.Config.statusLine()
+	for _, names := range [][]string{conf.Left, conf.Center, conf.Right} {
+		for _, name := range names {
+			if name == "clock" {
+				v.UpdateStatusBar()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const SWAP_INTERVAL = 2 * time.Second // 未保存のバッファをスワップファイルに書き出す間隔
+
+// スワップファイルのヘッダ (1行目にJSONで記録し、未保存の内容がある場合は2行目以降に続ける)
+// ファイルを開いている間は未編集でもヘッダのみのスワップファイルを置き、ロックとして使用する
+type SwapHeader struct {
+	Path  string       `json:"path"`  // 編集中のファイルの正規化したパス (無題のバッファは空)
+	Pid   int          `json:"pid"`   // 編集中のプロセスID
+	Host  string       `json:"host"`  // 編集中のホスト名
+	Time  time.Time    `json:"time"`  // 書き出した時刻
+	Dirty bool         `json:"dirty"` // 未保存の内容を含むかどうか
+	NL    utils.NLCode `json:"nl"`    // 改行文字識別番号
+}
+
+var untitledSwapSeq int // このプロセスで作成した無題のバッファのスワップファイルの連番
+
+// スワップファイルを置くディレクトリ ($XDG_STATE_HOME/paprika/swap)
+func swapDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "swap")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// ファイルに対応するスワップファイルのパス
+// $XDG_STATE_HOME/paprika/swap/<ファイル名>.<正規化したパスのハッシュ>.swp
+// シンボリックリンク経由で開いた場合も同じスワップファイルを使用する
+func swapFilePath(filePath string) (string, error) {
+	dir, err := swapDir()
+	if err != nil {
+		return "", err
+	}
+	path := canonicalPath(filePath)
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(dir, fmt.Sprintf("%s.%x.swp", filepath.Base(path), sum[:6])), nil
+}
+
+// 無題のバッファのスワップファイルのパス
+// $XDG_STATE_HOME/paprika/swap/untitled.<プロセスID>.<連番>.swp
+func untitledSwapPath() (string, error) {
+	dir, err := swapDir()
+	if err != nil {
+		return "", err
+	}
+	untitledSwapSeq++
+	return filepath.Join(dir, fmt.Sprintf("untitled.%d.%d.swp", os.Getpid(), untitledSwapSeq)), nil
+}
+
+// スワップファイルの読み込み
+func readSwap(path string) (*SwapHeader, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	line, content, _ := bytes.Cut(data, []byte("\n"))
+	header := new(SwapHeader)
+	if err := json.Unmarshal(line, header); err != nil {
+		return nil, nil, fmt.Errorf("%s is broken: %v", path, err)
+	}
+	return header, content, nil
+}
+
+// ホスト名 (取得できない場合は空)
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+// プロセスが動作中かどうかの判定
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// スワップファイルの書き出し (contentがnilの場合はヘッダのみ)
+// 書き込み途中の状態を残さないように一時ファイルに書いてから置き換える
+func (b *Buffer) writeSwap(content []byte) error {
+	path := ""
+	if b.FilePath != "" {
+		path = canonicalPath(b.FilePath)
+	}
+	header, err := json.Marshal(SwapHeader{path, os.Getpid(), hostname(), time.Now(), content != nil, b.NL})
+	if err != nil {
+		return err
+	}
+	fp, err := os.CreateTemp(filepath.Dir(b.SwapPath), ".swp-*")
+	if err != nil {
+		return err
+	}
+	_, err = fp.Write(append(append(header, '\n'), content...))
+	if closeErr := fp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(fp.Name(), b.SwapPath)
+	}
+	if err != nil {
+		os.Remove(fp.Name())
+		return err
+	}
+	b.swapSum = sha256.Sum256(content)
+	return nil
+}
+
+// スワップファイルの使用開始 (既にスワップファイルがある場合は使用しない)
+// 無題のバッファは編集して未保存になってから作成する
+func (b *Buffer) claimSwap() {
+	var path string
+	var err error
+	if b.FilePath == "" {
+		if b.IsSaved {
+			return
+		}
+		path, err = untitledSwapPath()
+	} else {
+		path, err = swapFilePath(b.FilePath)
+	}
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	b.SwapPath = path
+	if err := b.syncSwap(); err != nil {
+		b.SwapPath = ""
+	}
+}
+
+// 現在の状態をスワップファイルに反映 (未保存の場合は内容も書き出す)
+func (b *Buffer) syncSwap() error {
+	if b.IsSaved {
+		return b.writeSwap(nil)
+	}
+	return b.writeSwap(b.Bytes(b.NL))
+}
+
+// スワップファイルの削除
+func (b *Buffer) removeSwap() {
+	if b.SwapPath == "" {
+		return
+	}
+	os.Remove(b.SwapPath)
+	b.SwapPath = ""
+}
+
+// 未保存のバッファをスワップファイルに書き出し (前回から変更のないものは除く)
+func (v *View) JournalSwaps() {
+	for _, b := range v.Buffers.All() {
+		if b.SwapPath == "" && b.FilePath == "" { // 無題のバッファは最初の書き出しでスワップファイルを作成
+			b.claimSwap()
+			continue
+		}
+		if b.SwapPath == "" || b.IsSaved {
+			continue
+		}
+		content := b.Bytes(b.NL)
+		if sha256.Sum256(content) == b.swapSum {
+			continue
+		}
+		if err := b.writeSwap(content); err != nil {
+			v.SetMessage("Error: cannot write swap file of %s: %v", b.Title(), err)
+			b.SwapPath = ""
+		}
+	}
+}
+
+// 全てのタブのスワップファイルを削除 (正常終了時)
+func (v *View) RemoveSwaps() {
+	for _, b := range v.Buffers.All() {
+		b.removeSwap()
+	}
+}
+
+// 読み込んだタブのスワップファイルを順に確認 (LoadFileの後に実行)
+// 選択が必要な場合は選択後に残りのタブの確認を続ける
+func (v *View) CheckSwaps(tabs []*Buffer) {
+	for i, b := range tabs {
+		rest := tabs[i+1:]
+		if v.checkSwap(b, func(v *View) { v.CheckSwaps(rest) }) {
+			return
+		}
+	}
+}
+
+// スワップファイルの確認
+// 他のインスタンスで開かれている場合は警告し、復元可能な変更がある場合は復元/差分表示/破棄を選択する
+// 選択を表示した場合はtrueを返し、選択後にnextを実行する
+func (v *View) checkSwap(b *Buffer, next func(v *View)) bool {
+	if b.FilePath == "" || b.SwapPath != "" {
+		return false
+	}
+	path, err := swapFilePath(b.FilePath)
+	if err != nil {
+		v.SetMessage("Error: cannot create swap file: %v", err)
+		return false
+	}
+	header, content, err := readSwap(path)
+	if err == nil {
+		if header.Pid == os.Getpid() { // このインスタンスの別のタブで開いている
+			return false
+		}
+		if header.Host != hostname() || processAlive(header.Pid) {
+			v.SetMessage("Warning: %s is already open in another Paprika (pid %d on %s)", b.FilePath, header.Pid, header.Host)
+			return false
+		}
+		if header.Dirty && swapIsNewer(header, b.FilePath) {
+			v.askRecover(b, path, header, content, nil, next)
+			return true
+		}
+	}
+	b.SwapPath = path // 古いスワップファイルは置き換える
+	if err := b.syncSwap(); err != nil {
+		v.SetMessage("Error: cannot write swap file of %s: %v", b.Title(), err)
+		b.SwapPath = ""
+	}
+	return false
+}
+
+// 終了したプロセスの無題のバッファのスワップファイルを新しいタブに復元 (起動時に実行)
+// 復元したタブはスワップファイルを引き継ぎ、閉じるまで未保存として扱う
+func (v *View) RecoverUntitled() {
+	dir, err := swapDir()
+	if err != nil {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "untitled.*.swp"))
+	count := 0
+	for _, path := range paths {
+		header, content, err := readSwap(path)
+		if err != nil || header.Path != "" || !header.Dirty || header.Host != hostname() || processAlive(header.Pid) {
+			continue
+		}
+		own, err := untitledSwapPath()
+		if err != nil || os.Rename(path, own) != nil {
+			continue
+		}
+		v.AddTab("")
+		b := v.Tabs[len(v.Tabs)-1]
+		b.readLines(bytes.NewReader(content))
+		b.NL = header.NL
+		b.Label = "untitled (recovered)"
+		b.IsSaved = false
+		b.SwapPath = own
+		b.syncSwap()
+		count++
+	}
+	if count > 0 {
+		if v.Overlay == nil { // 復元の選択中でなければ復元したタブを表示
+			v.MoveTab(len(v.Tabs) - 1)
+		}
+		v.Reflesh()
+		v.SetMessage("Recovered %d untitled buffer(s) from swap files", count)
+	}
+}
+
+// スワップファイルがファイルより新しいかどうか (ファイルが存在しない場合も含む)
+func swapIsNewer(header *SwapHeader, filePath string) bool {
+	info, err := os.Stat(filePath)
+	return err != nil || header.Time.After(info.ModTime())
+}
+
+// スワップファイルの変更の復元の選択
+// 差分の表示中はdiffTabにそのタブを渡す
+func (v *View) askRecover(b *Buffer, path string, header *SwapHeader, content []byte, diffTab *Buffer, next func(v *View)) {
+	v.focusTab(b)
+	options := []ChoiceOption{{'r', "recover"}, {'d', "diff"}, {'x', "discard"}}
+	if diffTab != nil {
+		options = []ChoiceOption{{'r', "recover"}, {'x', "discard"}}
+	}
+	message := fmt.Sprintf("Unsaved changes of %s from %s found.", b.Title(), header.Time.Format("2006-01-02 15:04:05"))
+	if diffTab != nil {
+		v.focusTab(diffTab)
+	}
+	v.Reflesh()
+	v.OpenOverlay(NewChoice(message, options, func(v *View, key rune) uint8 {
+		if key == 'd' {
+			v.askRecover(b, path, header, content, v.openSwapDiff(b, content), next)
+			return 0
+		}
+		if diffTab != nil {
+			v.focusTab(diffTab)
+			v.DeleteTab()
+		}
+		switch key {
+		case 'r': // スワップファイルの内容で置き換え
+			swap := NewBuffer(b.FilePath, b.TabSize)
+			swap.readLines(bytes.NewReader(content))
+			b.Lines = swap.Lines
+			b.changedFrom(1)
+			b.NL = header.NL
+			b.IsSaved = false
+			b.SwapPath = path
+			b.syncSwap()
+			v.Message = fmt.Sprintf("Recovered unsaved changes of %s", b.Title())
+		case 'x': // スワップファイルを破棄
+			b.SwapPath = path
+			b.syncSwap()
+		default: // 判断を保留してスワップファイルを残す (このタブでは使用しない)
+			v.Message = fmt.Sprintf("Swap file kept: %s", path)
+		}
+		v.focusTab(b)
+		v.Reflesh()
+		next(v)
+		return 0
+	}))
+}
+
+// ファイルとスワップファイルの差分を新しいタブで表示
+func (v *View) openSwapDiff(b *Buffer, content []byte) *Buffer {
+	swap := NewBuffer(b.FilePath, b.TabSize)
+	swap.readLines(bytes.NewReader(content))
+	diff := core.UnifiedDiff(b.LineStrings(), swap.LineStrings(), b.Title(), b.Title()+" (swap)", 3)
+	if len(diff) == 0 {
+		diff = []string{"No differences."}
+	}
+	d := NewBuffer("", b.TabSize)
+	d.Label = b.Title() + " (swap diff)"
+	d.SetLines(diff)
+	v.addBuffer(d)
+	return d
+}
+
+// 指定したバッファのタブに移動
+func (v *View) focusTab(b *Buffer) bool {
+	for i, tab := range v.Tabs {
+		if tab == b {
+			return v.MoveTab(i)
+		}
+	}
+	return false
+}
@@ -2,9 +2,80 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
 )
 
+// 言語の定義 (ファイル名または拡張子で判定)
+type Language struct {
+	Name       string
+	Extensions []string    // 拡張子 (ドットを含む)
+	FileNames  []string    // 拡張子以外で判定するファイル名
+	Openers    string      // 行末にある場合に次の行のインデントを深くする文字
+	Syntax     core.Syntax // 文字列・コメントの記法
+	Fold       FoldMethod  // 折りたたみ範囲の求め方
+}
+
+// 折りたたみ範囲の求め方
+type FoldMethod int8
+
+const (
+	FOLD_INDENT FoldMethod = iota // インデントの深さ
+	FOLD_SYNTAX                   // 括弧の対応 (文字列・コメント内の括弧を除く)
+)
+
+// 言語ごとの文字列・コメントの記法
+var (
+	cSyntax    = core.Syntax{LineComment: "//", BlockComment: [2]string{"/*", "*/"}, Quotes: "\"'"}
+	rawSyntax  = core.Syntax{LineComment: "//", BlockComment: [2]string{"/*", "*/"}, Quotes: "\"'", RawQuotes: "`"}
+	rustSyntax = core.Syntax{LineComment: "//", BlockComment: [2]string{"/*", "*/"}, Quotes: "\""}
+	hashSyntax = core.Syntax{LineComment: "#", Quotes: "\"'"}
+	cssSyntax  = core.Syntax{BlockComment: [2]string{"/*", "*/"}, Quotes: "\"'"}
+	htmlSyntax = core.Syntax{BlockComment: [2]string{"<!--", "-->"}}
+	jsonSyntax = core.Syntax{Quotes: "\""}
+)
+
+var languages = []Language{
+	{"Go", []string{".go"}, nil, "{([", rawSyntax, FOLD_SYNTAX},
+	{"Go Module", nil, []string{"go.mod", "go.sum"}, "(", rawSyntax, FOLD_SYNTAX},
+	{"C", []string{".c", ".h"}, nil, "{([", cSyntax, FOLD_SYNTAX},
+	{"C++", []string{".cc", ".cpp", ".cxx", ".hpp"}, nil, "{([", cSyntax, FOLD_SYNTAX},
+	{"Rust", []string{".rs"}, nil, "{([", rustSyntax, FOLD_SYNTAX},
+	{"Python", []string{".py"}, nil, ":{([", hashSyntax, FOLD_INDENT},
+	{"JavaScript", []string{".js", ".mjs"}, nil, "{([", rawSyntax, FOLD_SYNTAX},
+	{"TypeScript", []string{".ts", ".tsx"}, nil, "{([", rawSyntax, FOLD_SYNTAX},
+	{"Shell", []string{".sh", ".bash"}, []string{".bashrc", ".profile"}, "{(", hashSyntax, FOLD_INDENT},
+	{"Makefile", []string{".mk"}, []string{"Makefile", "makefile", "GNUmakefile"}, "", hashSyntax, FOLD_INDENT},
+	{"Markdown", []string{".md"}, nil, "", core.Syntax{}, FOLD_INDENT},
+	{"JSON", []string{".json"}, nil, "{[", jsonSyntax, FOLD_SYNTAX},
+	{"YAML", []string{".yml", ".yaml"}, nil, ":", hashSyntax, FOLD_INDENT},
+	{"TOML", []string{".toml"}, nil, "{[", hashSyntax, FOLD_INDENT},
+	{"HTML", []string{".html", ".htm"}, nil, "", htmlSyntax, FOLD_INDENT},
+	{"CSS", []string{".css"}, nil, "{(", cssSyntax, FOLD_SYNTAX},
+}
+
+// ファイルの言語の取得 (判定できない場合はfalse)
+func languageOf(path string) (Language, bool) {
+	base := filepath.Base(path)
+	ext := filepath.Ext(base)
+	for _, lang := range languages {
+		if containsToken(lang.FileNames, base) || containsToken(lang.Extensions, ext) {
+			return lang, true
+		}
+	}
+	return Language{}, false
+}
+
+// ファイルの言語名の取得 (判定できない場合はPlain Text)
+func LanguageOf(path string) string {
+	if lang, ok := languageOf(path); ok {
+		return lang.Name
+	}
+	return "Plain Text"
+}
+
 var goReserved = []string{
 	"break",
 	"case",
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const TAB_INDICATOR_WIDTH = 3 // タブバーの両端の隠れたタブの数の表示幅
+
+// タブバー上のタブの表示位置
+type tabSlot struct {
+	Idx   int    // タブのインデックス
+	Col   uint   // 左端の列
+	Width uint   // 表示幅 (区切り線を含む)
+	Label string // 表示するタブ名 (幅に合わせて切り詰めたもの)
+}
+
+// タブ名の一覧 (同じファイル名のタブはパスの末尾を区別できるところまで表示)
+func tabLabels(tabs []*Buffer) []string {
+	labels := make([]string, len(tabs))
+	paths := make([]string, 0, len(tabs))
+	idxs := make([]int, 0, len(tabs))
+	for i, b := range tabs {
+		labels[i] = b.Title()
+		if b.FilePath == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(b.FilePath); err == nil {
+			paths = append(paths, abs)
+			idxs = append(idxs, i)
+		}
+	}
+	for i, suffix := range utils.UniqueSuffixes(paths) {
+		labels[idxs[i]] = suffix
+	}
+	return labels
+}
+
+// タブの表示幅 (" タブ名 * |")
+func tabWidth(label string, b *Buffer) int {
+	w := len([]rune(label)) + 3
+	if !b.IsSaved {
+		w += 2
+	}
+	return w
+}
+
+// タブバーに表示するタブの位置の計算
+// 全てのタブが入り切らない場合は現在のタブが見えるようにスクロールし、両端に隠れたタブの数を表示する領域を空ける
+func (v *View) layoutTabBar() (slots []tabSlot, hiddenLeft int, hiddenRight int) {
+	labels := tabLabels(v.Tabs)
+	widths := make([]int, len(v.Tabs))
+	total := 0
+	for i, b := range v.Tabs {
+		widths[i] = tabWidth(labels[i], b)
+		total += widths[i]
+	}
+	slots = make([]tabSlot, 0, len(v.Tabs))
+	if total <= int(v.WinCol) {
+		v.TabScroll = 0
+		col := 1
+		for i := range v.Tabs {
+			slots = append(slots, tabSlot{i, uint(col), uint(widths[i]), labels[i]})
+			col += widths[i]
+		}
+		return slots, 0, 0
+	}
+
+	inner := int(v.WinCol) - 2*TAB_INDICATOR_WIDTH
+	current := v.TabIndex()
+	if v.TabScroll > current || v.TabScroll >= len(v.Tabs) {
+		v.TabScroll = current
+	}
+	sum := func(from int, to int) (w int) {
+		for i := from; i <= to; i++ {
+			w += widths[i]
+		}
+		return
+	}
+	for v.TabScroll < current && sum(v.TabScroll, current) > inner {
+		v.TabScroll++
+	}
+	for v.TabScroll > 0 && sum(v.TabScroll-1, len(v.Tabs)-1) <= inner { // 右側に空きがあれば左に戻す
+		v.TabScroll--
+	}
+
+	col := TAB_INDICATOR_WIDTH + 1
+	used := 0
+	for i := v.TabScroll; i < len(v.Tabs); i++ {
+		if used+widths[i] > inner {
+			if i == v.TabScroll { // 1つも入らない場合はタブ名を切り詰める
+				label := labels[i]
+				rs := []rune(label)
+				cut := widths[i] - inner + 1
+				if cut < len(rs) {
+					label = "…" + string(rs[cut:])
+				}
+				slots = append(slots, tabSlot{i, uint(col), uint(inner), label})
+				i++
+			}
+			hiddenRight = len(v.Tabs) - i
+			break
+		}
+		slots = append(slots, tabSlot{i, uint(col + used), uint(widths[i]), labels[i]})
+		used += widths[i]
+	}
+	return slots, v.TabScroll, hiddenRight
+}
+
+// タブバー上の指定した列にあるタブのインデックス
+// 左右の隠れたタブの数の表示の上の場合は、その側で最も近い隠れたタブを返す (ない場合は-1)
+func (v *View) tabAt(col uint) int {
+	slots, hiddenLeft, hiddenRight := v.layoutTabBar()
+	if hiddenLeft > 0 && col <= TAB_INDICATOR_WIDTH {
+		return hiddenLeft - 1
+	}
+	if hiddenRight > 0 && col > uint(v.WinCol)-TAB_INDICATOR_WIDTH {
+		return len(v.Tabs) - hiddenRight
+	}
+	for _, s := range slots {
+		if col >= s.Col && col < s.Col+s.Width {
+			return s.Idx
+		}
+	}
+	return -1
+}
+
+// タブの並び替え (fromのタブをtoの位置に移動)
+func (v *View) MoveTabTo(from int, to int) bool {
+	if from < 0 || from >= len(v.Tabs) || to < 0 || to >= len(v.Tabs) || from == to {
+		return false
+	}
+	b := v.Tabs[from]
+	v.Tabs = append(v.Tabs[:from], v.Tabs[from+1:]...)
+	v.Tabs = append(v.Tabs[:to], append([]*Buffer{b}, v.Tabs[to:]...)...)
+	return true
+}
+
+// マウスイベントの処理
+// タブバーのクリックでタブを切り替え、ドラッグで並び替える
+// テキストエリアのクリックでペインにフォーカスを移してカーソルを移動する
+func (v *View) processMouse(m MouseEvent) {
+	if v.Overlay != nil {
+		return
+	}
+	if !m.Press { // ドラッグしたタブを離した位置に移動
+		if v.dragTab != nil && m.Row == 1 && m.Button == MOUSE_LEFT {
+			if to := v.tabAt(m.Col); to >= 0 && v.MoveTabTo(v.TabIndex(), to) {
+				v.UpdateTabBar()
+			}
+		}
+		v.dragTab = nil
+		return
+	}
+	if m.Row == 1 {
+		switch m.Button {
+		case MOUSE_LEFT:
+			idx := v.tabAt(m.Col)
+			if idx < 0 {
+				return
+			}
+			if idx != v.TabIndex() {
+				v.AutosaveTabSwitch()
+				v.MoveTab(idx)
+				v.Reflesh()
+			}
+			v.dragTab = v.Tabs[idx]
+		case MOUSE_WHEEL_UP:
+			v.RunAction("Previous Tab")
+		case MOUSE_WHEEL_DOWN:
+			v.RunAction("Next Tab")
+		}
+		return
+	}
+
+	p := v.PaneAt(m.Col, m.Row)
+	if p == nil {
+		return
+	}
+	switch m.Button {
+	case MOUSE_LEFT:
+		if v.Sidebar != nil {
+			v.Sidebar.Focused = false
+		}
+		e := p.Editor
+		pos := e.clampPos(Cursor{Row: e.rowAtScreen(int(m.Row - p.Top)), Col: 1})
+		if m.Col >= p.Left+GUTTER_WIDTH { // クリックした表示上の列にある文字の位置
+			line := e.Lines[pos.Row-1].GetAll()
+			pos.Col = uint(utils.IndexAtCell(line, int(m.Col-p.Left-GUTTER_WIDTH), int(e.TabSize))) + 1
+		}
+		if p == v.Focus && m.Mod&(MOUSE_ALT|MOUSE_CTRL) != 0 { // Alt・Ctrl+クリックはカーソルの追加・削除
+			v.ToggleCaret(pos)
+			v.Reflesh()
+			return
+		}
+		v.Focus = p
+		e.ClearCarets()
+		e.Cursor.Row = pos.Row
+		e.clampCursor()
+		e.MoveHeadCol()
+		e.MoveTargetCol(pos.Col)
+		v.Reflesh()
+	case MOUSE_WHEEL_UP, MOUSE_WHEEL_DOWN: // ホイールはフォーカスのあるペインのカーソル移動として扱う
+		name := "Cursor Up"
+		if m.Button == MOUSE_WHEEL_DOWN {
+			name = "Cursor Down"
+		}
+		for i := 0; i < 3; i++ {
+			v.RunAction(name)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+// 表示スタイル (色は256色のパレット番号、省略した場合は端末のデフォルト)
+type Style struct {
+	FG   *uint8 `json:"fg,omitempty"`
+	BG   *uint8 `json:"bg,omitempty"`
+	Bold bool   `json:"bold,omitempty"`
+}
+
+// スタイル名 -> スタイル ("status.mode"のように.で区切り、ないものは親の名前のスタイルを使用)
+type Theme map[string]Style
+
+// 色の指定
+func color(c uint8) *uint8 {
+	return &c
+}
+
+// デフォルトのテーマ
+var defaultTheme = Theme{
+	"status":             {BG: color(25)},
+	"status.mode":        {FG: color(231), BG: color(31), Bold: true},
+	"status.modified":    {FG: color(214), BG: color(25), Bold: true},
+	"status.diagnostics": {FG: color(203), BG: color(25), Bold: true},
+	"status.conflicts":   {FG: color(203), BG: color(25), Bold: true},
+	"status.separator":   {FG: color(67), BG: color(25)},
+	"status.message":     {BG: color(25)},
+	"selection":          {BG: color(24)},
+	"cursor.secondary":   {FG: color(16), BG: color(250)},
+	"bracket.match":      {BG: color(240), Bold: true},
+	"fold":               {FG: color(244), BG: color(236)},
+}
+
+// スタイルの取得 (設定ファイルのテーマ・デフォルトのテーマの順に検索し、ない場合は親の名前で検索)
+func (c *Config) Style(name string) Style {
+	for {
+		if s, ok := c.Theme[name]; ok {
+			return s
+		}
+		if s, ok := defaultTheme[name]; ok {
+			return s
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return Style{}
+		}
+		name = name[:i]
+	}
+}
+
+// スタイルの適用 (直前のスタイルは解除する)
+func (s Style) Apply(term *core.UnixTerm) {
+	term.ResetStyle()
+	if s.FG != nil {
+		term.SetColor(*s.FG)
+	}
+	if s.BG != nil {
+		term.SetBGColor(*s.BG)
+	}
+	if s.Bold {
+		term.SetBold()
+	}
+}
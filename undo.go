@@ -0,0 +1,104 @@
+package main
+
+const UNDO_MAX = 200 // 元に戻せる変更の最大数
+
+// 変更前の内容とカーソル位置
+type undoState struct {
+	Lines []string
+	Row   uint
+	Col   uint
+}
+
+// 変更前の状態を記録 (まとめて記録中の場合は最初の1回のみ)
+func (e *Editor) SaveUndo() {
+	b := e.Buffer
+	if b.undoDepth > 0 {
+		if b.undoSaved {
+			return
+		}
+		b.undoSaved = true
+	}
+	b.undo = append(b.undo, undoState{b.LineStrings(), e.Cursor.Row, e.Cursor.Col})
+	if len(b.undo) > UNDO_MAX {
+		b.undo = b.undo[len(b.undo)-UNDO_MAX:]
+	}
+	b.redo = b.redo[:0]
+}
+
+// 文字の入力・削除の前に変更前の状態を記録 (連続したキー入力による入力・削除は1回の取り消しの単位にまとめる)
+// 複数カーソルでの同じキー入力による2回目以降の呼び出しでは記録しない
+func (v *View) saveTypingUndo() {
+	if v.typedSeq == v.keySeq {
+		return
+	}
+	if v.typedSeq == 0 || v.typedSeq+1 != v.keySeq {
+		v.GetCurrentTab().SaveUndo()
+	}
+	v.typedSeq = v.keySeq
+}
+
+// 複数の変更を1回の取り消しの単位にまとめる (EndUndoGroupと対で呼び出す、入れ子可)
+func (b *Buffer) BeginUndoGroup() {
+	if b.undoDepth == 0 {
+		b.undoSaved = false
+	}
+	b.undoDepth++
+}
+
+func (b *Buffer) EndUndoGroup() {
+	if b.undoDepth > 0 {
+		b.undoDepth--
+	}
+}
+
+// 直前の変更の取り消し (取り消す変更がない場合はfalse)
+func (e *Editor) Undo() bool {
+	b := e.Buffer
+	if len(b.undo) == 0 {
+		return false
+	}
+	state := b.undo[len(b.undo)-1]
+	b.undo = b.undo[:len(b.undo)-1]
+	b.redo = append(b.redo, undoState{b.LineStrings(), e.Cursor.Row, e.Cursor.Col})
+	e.restoreUndo(state)
+	return true
+}
+
+// 取り消した変更のやり直し (やり直す変更がない場合はfalse)
+func (e *Editor) Redo() bool {
+	b := e.Buffer
+	if len(b.redo) == 0 {
+		return false
+	}
+	state := b.redo[len(b.redo)-1]
+	b.redo = b.redo[:len(b.redo)-1]
+	b.undo = append(b.undo, undoState{b.LineStrings(), e.Cursor.Row, e.Cursor.Col})
+	e.restoreUndo(state)
+	return true
+}
+
+// 記録した状態の復元 (保存時と同じ内容に戻った場合は保存済みにする)
+func (e *Editor) restoreUndo(state undoState) {
+	nl := e.NL
+	e.SetLines(state.Lines)
+	e.NL = nl
+	e.IsSaved = equalStrings(state.Lines, e.Base)
+	e.ClearSelection()
+	e.ClearCarets()
+	e.MoveTargetRow(state.Row)
+	e.MoveTargetCol(state.Col)
+	e.clampCursor()
+}
+
+// 文字列のリストが等しいかどうか
+func equalStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
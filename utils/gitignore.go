@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"path"
+	"strings"
+)
+
+// .gitignoreの1行分のパターン
+type ignorePattern struct {
+	segments []string // '/'で分割したパターン
+	negate   bool     // '!'で始まる (除外の取り消し)
+	dirOnly  bool     // '/'で終わる (ディレクトリのみ対象)
+	anchored bool     // '/'を含む (.gitignoreの場所からの相対パスで一致)
+}
+
+// .gitignore構造体
+type GitIgnore struct {
+	Base     string // .gitignoreが置かれているディレクトリ (走査ルートからの相対パス)
+	patterns []ignorePattern
+}
+
+// .gitignoreの内容を解析
+func ParseGitIgnore(base string, data string) *GitIgnore {
+	g := new(GitIgnore)
+	g.Base = strings.Trim(base, "/")
+	if g.Base == "." {
+		g.Base = ""
+	}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimLeft(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.segments = strings.Split(line, "/")
+		g.patterns = append(g.patterns, p)
+	}
+	return g
+}
+
+// パスが一致するかの判定
+// relPathは走査ルートからの'/'区切りの相対パス
+// matched=falseの場合はこの.gitignoreでは判定できない
+func (g *GitIgnore) Match(relPath string, isDir bool) (ignored bool, matched bool) {
+	if g.Base != "" {
+		if !strings.HasPrefix(relPath, g.Base+"/") {
+			return false, false
+		}
+		relPath = relPath[len(g.Base)+1:]
+	}
+	segments := strings.Split(relPath, "/")
+	for _, p := range g.patterns { // 後に書かれたパターンを優先
+		if p.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if p.anchored {
+			ok = matchSegments(p.segments, segments)
+		} else {
+			ok = matchSegments(p.segments, segments[len(segments)-1:])
+		}
+		if ok {
+			ignored, matched = !p.negate, true
+		}
+	}
+	return
+}
+
+// '**'を含むパターンのセグメント単位での一致判定
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, err := path.Match(pattern[0], segments[0]); err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// 複数の.gitignoreをまとめて判定 (深い階層の.gitignoreを優先)
+type GitIgnoreList []*GitIgnore
+
+func (l GitIgnoreList) IsIgnored(relPath string, isDir bool) bool {
+	ignored := false
+	for _, g := range l {
+		if ok, matched := g.Match(relPath, isDir); matched {
+			ignored = ok
+		}
+	}
+	return ignored
+}
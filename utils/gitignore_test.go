@@ -0,0 +1,39 @@
+package utils
+
+import "testing"
+
+func Test_GitIgnore_IsIgnored(t *testing.T) {
+	type args struct {
+		relPath string
+		isDir   bool
+	}
+	rules := GitIgnoreList{
+		ParseGitIgnore("", "# comment\n*.log\nbin/\n/build\ndocs/**/*.tmp\n!keep.log\n"),
+		ParseGitIgnore("sub", "local.txt\n"),
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{"Test #1", args{"a.log", false}, true},
+		{"Test #2", args{"dir/b.log", false}, true},
+		{"Test #3", args{"keep.log", false}, false},
+		{"Test #4", args{"bin", true}, true},
+		{"Test #5", args{"bin", false}, false},
+		{"Test #6", args{"build", true}, true},
+		{"Test #7", args{"src/build", true}, false},
+		{"Test #8", args{"docs/a/b/c.tmp", false}, true},
+		{"Test #9", args{"docs/c.tmp", false}, true},
+		{"Test #10", args{"sub/local.txt", false}, true},
+		{"Test #11", args{"local.txt", false}, false},
+		{"Test #12", args{"main.go", false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.IsIgnored(tt.args.relPath, tt.args.isDir); got != tt.want {
+				t.Errorf("IsIgnored(%q) = %v, want %v", tt.args.relPath, got, tt.want)
+			}
+		})
+	}
+}
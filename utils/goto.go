@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 移動先の指定の解析
+// "行", "行:列", "+N" / "-N" (現在の行からの相対), "N%" (ファイル内の割合) を受け付ける
+// 行はファイルの範囲内に収め、列の指定がない場合は0を返す
+func ParseGoto(spec string, curRow int, lineCount int) (row int, col int, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return 0, 0, fmt.Errorf("empty position")
+	}
+	rowSpec, colSpec, hasCol := strings.Cut(spec, ":")
+	if hasCol {
+		if col, err = strconv.Atoi(colSpec); err != nil || col < 1 {
+			return 0, 0, fmt.Errorf("invalid column: %q", colSpec)
+		}
+	}
+	switch {
+	case strings.HasSuffix(rowSpec, "%"):
+		p, err := strconv.ParseFloat(strings.TrimSuffix(rowSpec, "%"), 64)
+		if err != nil || p < 0 {
+			return 0, 0, fmt.Errorf("invalid percentage: %q", rowSpec)
+		}
+		row = int(float64(lineCount)*p/100 + 0.5)
+	case strings.HasPrefix(rowSpec, "+") || strings.HasPrefix(rowSpec, "-"):
+		n, err := strconv.Atoi(rowSpec)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid offset: %q", rowSpec)
+		}
+		row = curRow + n
+	case rowSpec == "" && hasCol: // ":列" は現在の行
+		row = curRow
+	default:
+		if row, err = strconv.Atoi(rowSpec); err != nil {
+			return 0, 0, fmt.Errorf("invalid line: %q", rowSpec)
+		}
+	}
+	if row > lineCount {
+		row = lineCount
+	}
+	if row < 1 {
+		row = 1
+	}
+	return row, col, nil
+}
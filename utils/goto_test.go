@@ -0,0 +1,44 @@
+package utils
+
+import "testing"
+
+func Test_Goto_ParseGoto(t *testing.T) {
+	type args struct {
+		spec      string
+		curRow    int
+		lineCount int
+	}
+	type want struct {
+		row int
+		col int
+		err bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want want
+	}{
+		{"Test #1", args{"12", 1, 100}, want{12, 0, false}},
+		{"Test #2", args{"12:5", 1, 100}, want{12, 5, false}},
+		{"Test #3", args{"+10", 20, 100}, want{30, 0, false}},
+		{"Test #4", args{"-30", 20, 100}, want{1, 0, false}},
+		{"Test #5", args{"50%", 1, 100}, want{50, 0, false}},
+		{"Test #6", args{"100%", 1, 37}, want{37, 0, false}},
+		{"Test #7", args{"0%", 10, 37}, want{1, 0, false}},
+		{"Test #8", args{"500", 1, 100}, want{100, 0, false}},
+		{"Test #9", args{":7", 42, 100}, want{42, 7, false}},
+		{"Test #10", args{" 3:2 ", 1, 100}, want{3, 2, false}},
+		{"Test #11", args{"abc", 1, 100}, want{0, 0, true}},
+		{"Test #12", args{"3:0", 1, 100}, want{0, 0, true}},
+		{"Test #13", args{"", 1, 100}, want{0, 0, true}},
+		{"Test #14", args{"x%", 1, 100}, want{0, 0, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, col, err := ParseGoto(tt.args.spec, tt.args.curRow, tt.args.lineCount)
+			if row != tt.want.row || col != tt.want.col || (err != nil) != tt.want.err {
+				t.Errorf("ParseGoto(%q) = %d, %d, %v, want %d, %d, err=%v", tt.args.spec, row, col, err, tt.want.row, tt.want.col, tt.want.err)
+			}
+		})
+	}
+}
@@ -9,13 +9,31 @@ const (
 )
 
 func GetNLCode(runes []rune) NLCode {
+	if len(runes) == 0 {
+		return -1
+	}
 	if runes[len(runes)-1] == rune('\r') {
 		return CR
 	} else if runes[len(runes)-1] == rune('\n') {
-		if runes[len(runes)-2] == rune('\r') {
+		if len(runes) >= 2 && runes[len(runes)-2] == rune('\r') {
 			return CRLF
 		}
 		return LF
 	}
 	return -1
 }
+
+// 行末の改行文字の削除 (改行で終わっていない最終行はそのまま)
+func TrimNL(runes []rune, nl NLCode) []rune {
+	switch nl {
+	case CRLF:
+		if GetNLCode(runes) == CRLF {
+			return runes[:len(runes)-2]
+		}
+	case LF:
+		if GetNLCode(runes) == LF {
+			return runes[:len(runes)-1]
+		}
+	}
+	return runes
+}
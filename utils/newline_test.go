@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_NewLine_GetNLCode(t *testing.T) {
+	tests := []struct {
+		name  string
+		runes []rune
+		want  NLCode
+	}{
+		{"Test #1", []rune("abc\n"), LF},
+		{"Test #2", []rune("abc\r\n"), CRLF},
+		{"Test #3", []rune("abc\r"), CR},
+		{"Test #4", []rune("abc"), -1},
+		{"Test #5", []rune("\n"), LF},
+		{"Test #6", []rune(""), -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetNLCode(tt.runes); got != tt.want {
+				t.Errorf("GetNLCode(%q) = %v, want %v", string(tt.runes), got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_NewLine_TrimNL(t *testing.T) {
+	type args struct {
+		runes []rune
+		nl    NLCode
+	}
+	tests := []struct {
+		name string
+		args args
+		want []rune
+	}{
+		{"Test #1", args{[]rune("abc\n"), LF}, []rune("abc")},
+		{"Test #2", args{[]rune("abc\r\n"), CRLF}, []rune("abc")},
+		{"Test #3", args{[]rune("abc"), LF}, []rune("abc")},
+		{"Test #4", args{[]rune("abc"), CRLF}, []rune("abc")},
+		{"Test #5", args{[]rune("\n"), LF}, []rune("")},
+		{"Test #6", args{[]rune("abc\n"), CRLF}, []rune("abc\n")},
+		{"Test #7", args{[]rune(""), LF}, []rune("")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrimNL(tt.args.runes, tt.args.nl); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TrimNL(%q) = %q, want %q", string(tt.args.runes), string(got), string(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// 各パスを他のパスと区別できる最短の末尾部分に短縮する (区切り文字は/)
+// 同じパスが複数ある場合はそのまま全体を返す
+func UniqueSuffixes(paths []string) []string {
+	parts := make([][]string, len(paths))
+	for i, p := range paths {
+		parts[i] = strings.Split(filepath.ToSlash(filepath.Clean(p)), "/")
+	}
+	suffixes := make([]string, len(paths))
+	for i, elems := range parts {
+		n := 1
+		for ; n < len(elems); n++ {
+			if !sharesSuffix(parts, i, n) {
+				break
+			}
+		}
+		suffixes[i] = strings.Join(elems[len(elems)-n:], "/")
+	}
+	return suffixes
+}
+
+// 末尾のn要素が他のパスと一致するかどうか
+func sharesSuffix(parts [][]string, idx int, n int) bool {
+	suffix := strings.Join(parts[idx][len(parts[idx])-n:], "/")
+	for j, elems := range parts {
+		if j == idx || len(elems) < n {
+			continue
+		}
+		if strings.Join(elems[len(elems)-n:], "/") == suffix {
+			return true
+		}
+	}
+	return false
+}
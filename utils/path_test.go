@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_Path_UniqueSuffixes(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"Test #1", []string{"/src/a/main.go", "/src/b/util.go"}, []string{"main.go", "util.go"}},
+		{"Test #2", []string{"/src/a/main.go", "/src/b/main.go"}, []string{"a/main.go", "b/main.go"}},
+		{"Test #3", []string{"/x/a/main.go", "/y/a/main.go", "/y/b/main.go"}, []string{"x/a/main.go", "y/a/main.go", "b/main.go"}},
+		{"Test #4", []string{"main.go", "cmd/main.go"}, []string{"main.go", "cmd/main.go"}},
+		{"Test #5", []string{"/a/main.go", "/a/main.go"}, []string{"/a/main.go", "/a/main.go"}},
+		{"Test #6", []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UniqueSuffixes(tt.paths); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UniqueSuffixes(%q) = %q, want %q", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
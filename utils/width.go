@@ -0,0 +1,83 @@
+package utils
+
+// 全角で表示する文字の範囲
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // ハングル字母
+	{0x2E80, 0x303E},   // CJK部首・記号
+	{0x3041, 0x33FF},   // ひらがな・カタカナ・CJK互換
+	{0x3400, 0x4DBF},   // CJK統合漢字拡張A
+	{0x4E00, 0x9FFF},   // CJK統合漢字
+	{0xA000, 0xA4CF},   // イ文字
+	{0xAC00, 0xD7A3},   // ハングル音節
+	{0xF900, 0xFAFF},   // CJK互換漢字
+	{0xFE30, 0xFE4F},   // CJK互換形
+	{0xFF00, 0xFF60},   // 全角英数・記号
+	{0xFFE0, 0xFFE6},   // 全角記号
+	{0x1F300, 0x1F64F}, // 絵文字
+	{0x1F900, 0x1F9FF}, // 絵文字
+	{0x20000, 0x3FFFD}, // CJK統合漢字拡張B以降
+}
+
+// 幅を持たない文字の範囲 (結合文字など)
+var zeroRanges = [][2]rune{
+	{0x0300, 0x036F}, // 結合ダイアクリティカルマーク
+	{0x200B, 0x200F}, // ゼロ幅空白など
+	{0x3099, 0x309A}, // 結合用濁点・半濁点
+	{0xFE00, 0xFE0F}, // 異体字セレクタ
+}
+
+// 文字の表示幅 (セル数)
+func RuneWidth(r rune) int {
+	if inRanges(r, zeroRanges) {
+		return 0
+	}
+	if inRanges(r, wideRanges) {
+		return 2
+	}
+	return 1
+}
+
+func inRanges(r rune, ranges [][2]rune) bool {
+	for _, rg := range ranges {
+		if r >= rg[0] && r <= rg[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// 行の先頭からidx文字目の直前までの表示幅 (タブは次のタブ位置まで進める)
+func CellsBefore(line []rune, idx int, tabSize int) int {
+	if idx > len(line) {
+		idx = len(line)
+	}
+	cells := 0
+	for _, r := range line[:idx] {
+		cells += cellWidth(r, cells, tabSize)
+	}
+	return cells
+}
+
+// 表示位置cellsを超えない最も右の文字の位置 (行末を超える場合は行の長さ)
+func IndexAtCell(line []rune, cells int, tabSize int) int {
+	pos := 0
+	for i, r := range line {
+		w := cellWidth(r, pos, tabSize)
+		if pos+w > cells {
+			return i
+		}
+		pos += w
+	}
+	return len(line)
+}
+
+// 表示位置posにある文字の表示幅
+func cellWidth(r rune, pos int, tabSize int) int {
+	if r == '\t' {
+		if tabSize <= 0 {
+			return 1
+		}
+		return tabSize - pos%tabSize
+	}
+	return RuneWidth(r)
+}
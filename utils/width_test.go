@@ -0,0 +1,74 @@
+package utils
+
+import "testing"
+
+func Test_Width_RuneWidth(t *testing.T) {
+	tests := []struct {
+		name string
+		r    rune
+		want int
+	}{
+		{"Test #1", 'a', 1},
+		{"Test #2", 'あ', 2},
+		{"Test #3", '漢', 2},
+		{"Test #4", 'Ａ', 2},
+		{"Test #5", 'ｱ', 1},
+		{"Test #6", '́', 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RuneWidth(tt.r); got != tt.want {
+				t.Errorf("RuneWidth(%q) = %d, want %d", tt.r, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Width_CellsBefore(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		idx     int
+		tabSize int
+		want    int
+	}{
+		{"Test #1", "abc", 2, 4, 2},
+		{"Test #2", "日本語", 2, 4, 4},
+		{"Test #3", "a日b", 3, 4, 4},
+		{"Test #4", "\tx", 1, 4, 4},
+		{"Test #5", "ab\tx", 3, 4, 4},
+		{"Test #6", "abc", 10, 4, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CellsBefore([]rune(tt.line), tt.idx, tt.tabSize); got != tt.want {
+				t.Errorf("CellsBefore(%q, %d) = %d, want %d", tt.line, tt.idx, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_Width_IndexAtCell(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		cells   int
+		tabSize int
+		want    int
+	}{
+		{"Test #1", "abcdef", 3, 4, 3},
+		{"Test #2", "abc", 10, 4, 3},
+		{"Test #3", "日本語", 2, 4, 1},
+		{"Test #4", "日本語", 3, 4, 1},
+		{"Test #5", "\tx", 2, 4, 0},
+		{"Test #6", "\tx", 4, 4, 1},
+		{"Test #7", "", 5, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IndexAtCell([]rune(tt.line), tt.cells, tt.tabSize); got != tt.want {
+				t.Errorf("IndexAtCell(%q, %d) = %d, want %d", tt.line, tt.cells, got, tt.want)
+			}
+		})
+	}
+}
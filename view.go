@@ -1,284 +1,587 @@
-package main
-
-import (
-	"fmt"
-	"syscall"
-
-	"github.com/broccolingual/Xanadu/core"
-	"github.com/broccolingual/Xanadu/utils"
-)
-
-type View struct {
-	Term    *core.UnixTerm
-	Event	  *Event
-	Tabs    []*Editor
-	TabIdx  int
-	WinRow	uint16
-	WinCol	uint16
-}
-
-func NewView() *View {
-	v := new(View)
-	v.Term = core.NewUnixTerm()
-	v.Event = NewEvent()
-	v.Tabs = make([]*Editor, 0)
-	v.TabIdx = 0
-	v.WinCol = 0
-	v.WinRow = 0
-	return v
-}
-
-func (v *View) MainLoop() {
-	e := v.Event
-	e.NotifySignal() // シグナルの読み取り
-
-	exit := make(chan interface{})
-	defer close(exit)
-	go e.ScanInput(exit) // キー入力の読み取り用
-
-	Loop:
-		for {
-			select {
-			case r := <-e.Key: // キーイベント受け取り
-				exitCode := v.processInput(r)
-				if exitCode != 0 {
-					break Loop
-				}
-			case sig := <-e.Signal: // OSシグナルの受け取り
-				switch sig {
-					case syscall.SIGWINCH:
-						v.UpdateWinSize()
-						v.Reflesh()
-				}
-			default:
-			}
-		}
-}
-
-// 画面サイズの変更
-func (v *View) UpdateWinSize() {
-	row, col := v.Term.GetWinSize()
-	v.WinRow = row
-	v.WinCol = col
-}
-
-// タブの追加
-func (v *View) AddTab(filePath string) {
-	v.Tabs = append(v.Tabs, NewEditor(filePath, 4))
-}
-
-// タブの削除
-func (v *View) DeleteTab() bool {
-	v.Tabs = append(v.Tabs[:v.TabIdx], v.Tabs[v.TabIdx+1:]...)
-	if len(v.Tabs) == 0 {
-		return false
-	}
-	if !v.PrevTab() {
-		v.NextTab()
-	}
-	return true
-}
-
-// 指定したインデックスのタブに移動
-func (v *View) MoveTab(idx int) bool {
-	if idx >= 0 && idx < len(v.Tabs) {
-		v.TabIdx = idx
-		return true
-	}
-	return false
-}
-
-// 次のタブへ移動
-func (v *View) NextTab() bool {
-	return v.MoveTab(v.TabIdx + 1)
-}
-
-// 前のタブへ移動
-func (v *View) PrevTab() bool {
-	return v.MoveTab(v.TabIdx - 1)
-}
-
-// 現在のタブのオブジェクトの取得
-func (v *View) GetCurrentTab() *Editor {
-	return v.Tabs[v.TabIdx]
-}
-
-func (v *View) DrawRow(vPos uint, lineNum uint) {
-	cTab := v.GetCurrentTab()
-	defer v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, vPos)
-	v.Term.ClearRow()
-	v.Term.SetColor(240)
-	fmt.Printf("%4d  ", lineNum)
-	v.Term.ResetStyle()
-	fmt.Printf("%s", string(cTab.Lines[lineNum-1].GetAll()))
-}
-
-func (v *View) DrawFocusRow(vPos uint, lineNum uint) {
-	cTab := v.GetCurrentTab()
-	defer v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, vPos)
-	v.Term.ClearRow()
-	v.Term.SetBGColor(235)
-	for i := 0; i < int(v.WinCol); i++ {
-		fmt.Print(" ")
-	}
-	v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, vPos)
-	v.Term.SetBold()
-	fmt.Printf("%4d  ", lineNum)
-	v.Term.ResetStyle()
-	v.Term.SetBGColor(235)
-	fmt.Printf("%s", string(cTab.Lines[lineNum-1].GetAll()))
-}
-
-func (v *View) DrawAllRow() {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-	defer v.Term.ResetStyle()
-	v.Term.InitCursorPos()
-	for i := 1; i < int(v.WinRow - 1); i++ {
-		cLineNum := int(cTab.ScrollRow) + i - 1
-		if cLineNum >= len(cTab.Lines) {
-			break
-		}
-		if cTab.IsTargetRow(uint(cLineNum)) {
-			v.DrawFocusRow(uint(i+1), uint(cLineNum))
-		} else {
-			v.DrawRow(uint(i+1), uint(cLineNum))
-		}
-	}
-}
-
-func (v *View) UpdateTabBar() {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-	defer v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, 1)
-	v.Term.ClearRow()
-	v.Term.SetBGColor(235)
-	for i := 0; i < int(v.WinCol); i++ {
-		fmt.Print(" ")
-	}
-	v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, 1)
-	for i, tab := range v.Tabs {
-		if i == v.TabIdx {
-			v.Term.ResetStyle()
-			v.Term.SetBold()
-			v.Term.SetColor(25)
-			fmt.Printf(" %s ", tab.FilePath)
-			v.Term.ResetStyle()
-			if !tab.IsSaved {
-				fmt.Print("* ")
-			}
-			fmt.Print("|")
-		} else {
-			v.Term.ResetStyle()
-			v.Term.SetBGColor(235)
-			fmt.Printf(" %s ", tab.FilePath)
-			if !tab.IsSaved {
-				fmt.Print("* ")
-			}
-			fmt.Print("|")
-		}
-	}
-}
-
-func (v *View) UpdateStatusBar() {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-	defer v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, uint(v.WinRow))
-	v.Term.ClearRow()
-	v.Term.SetBGColor(25)
-	for i := 0; i < int(v.WinCol); i++ {
-		fmt.Print(" ")
-	}
-	var nl string
-	switch cTab.NL {
-	case utils.CRLF:
-		nl = "CRLF"
-	case utils.CR:
-		nl = "CR"
-	case utils.LF:
-		nl = "LF"
-	default:
-		nl = "Unknown"
-	}
-	v.Term.ResetStyle()
-	v.Term.MoveCursorPos(1, uint(v.WinRow))
-	v.Term.SetBGColor(25)
-	fmt.Printf(" Ln %d, Col %d | Tab Size: %d | %s", cTab.Cursor.Row, cTab.Cursor.Col, cTab.TabSize, nl)
-}
-
-func (v *View) Reflesh() {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-	v.Term.ClearAll()
-	v.UpdateTabBar()
-	v.DrawAllRow()
-	v.UpdateStatusBar()
-}
-
-func (v *View) RefleshTextField() {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-	v.Term.MoveCursorPos(1, 2)
-	v.Term.ClearAfterCursor()
-	v.DrawAllRow()
-	v.UpdateStatusBar()
-}
-
-func (v *View) RefleshTargetRow(rowNum uint) {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-	v.Term.MoveCursorPos(1, uint(rowNum-cTab.ScrollRow+2))
-	v.Term.ClearRow()
-	if cTab.IsTargetRow(rowNum) {
-		v.DrawFocusRow(uint(rowNum-cTab.ScrollRow+2), rowNum)
-	} else {
-		v.DrawRow(uint(rowNum-cTab.ScrollRow+2), rowNum)
-	}
-}
-
-func (v *View) RefleshCursor() {
-	cTab := v.GetCurrentTab()
-	defer v.Term.MoveCursorPos(cTab.Cursor.Col+6, cTab.Cursor.Row-cTab.ScrollRow+2)
-}
-
-func (v *View) ScrollUp() {
-	cTab := v.GetCurrentTab()
-	prevCol := cTab.Cursor.Col
-	if cTab.ScrollRow >= cTab.Cursor.Row {
-		cTab.ScrollUp()
-		v.Reflesh()
-	} else {
-		v.RefleshTargetRow(cTab.Cursor.Row + 1)
-		v.RefleshTargetRow(cTab.Cursor.Row)
-		v.RefleshCursor()
-	}
-	if prevCol > cTab.GetCurrentMaxCol() { // Cursor is on the last column
-		cTab.MoveTailCol()
-		v.RefleshCursor()
-	}
-	v.UpdateStatusBar()
-}
-
-func (v *View) ScrollDown() {
-	cTab := v.GetCurrentTab()
-	prevCol := cTab.Cursor.Col
-	if cTab.ScrollRow + uint(v.WinRow) - 3 <= cTab.Cursor.Row {
-		cTab.ScrollDown()
-		v.Reflesh()
-	} else {
-		v.RefleshTargetRow(cTab.Cursor.Row - 1)
-		v.RefleshTargetRow(cTab.Cursor.Row)
-		v.RefleshCursor()
-	}
-	if prevCol > cTab.GetCurrentMaxCol() { // Cursor is on the last column
-		cTab.MoveTailCol()
-		v.RefleshCursor()
-	}
-	v.UpdateStatusBar()
-}
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+const GUTTER_WIDTH = 6 // 行番号の表示幅
+
+type View struct {
+	Term    *core.UnixTerm
+	Event	  *Event
+	Tabs    []*Buffer
+	Buffers *BufferRegistry // 開いている全てのバッファ (Tabsの各タブはここに登録したバッファ)
+	WinRow	uint16
+	WinCol	uint16
+	Actions       []*Action       // 利用可能なアクション
+	Keymap        map[rune]string // キー割り当て (キー -> アクション名)
+	RecentActions []string        // 最近使用したアクション名 (先頭が最新)
+	Overlay       Overlay         // テキストエリアに重ねて表示中のUI
+	Files         *FileIndex      // 作業ディレクトリ内のファイル一覧 (初回のファイル検索時に走査)
+	Sidebar       *Sidebar        // ファイルツリー (ディレクトリが指定された場合のみ)
+	Message       string          // ステータスバーに表示するメッセージ (次のキー入力で消去)
+	Config        *Config         // 設定ファイルの内容
+	LastInput     time.Time       // 最後のキー入力の時刻 (自動保存の判定用)
+	FileWatcher   *core.Watcher   // 開いているファイルの外部での変更の監視
+	Layout        *Layout         // ウィンドウ分割の木 (最初のタブを表示するまではnil)
+	Focus         *Pane           // 入力を受け付けるペイン
+	TabScroll     int             // タブバーの先頭に表示するタブのインデックス
+	dragTab       *Buffer         // マウスでドラッグ中のタブ
+	Jumps         []JumpPos       // ジャンプリスト (古い順)
+	JumpIdx       int             // ジャンプリスト内の現在の位置 (最新の位置にいる場合はlen(Jumps))
+	lastClock     string          // ステータスバーに最後に表示した時刻
+	gitBranches   map[string]gitBranchCache // ディレクトリ -> Gitのブランチ名
+	Layer         KeyLayer        // キー割り当ての前に入力を処理するレイヤー (設定のkeymapで選択、デフォルトはnil)
+	Registers     map[rune]Register // コピー・削除した内容 (レジスタ名 -> 内容)
+	keySeq        uint64          // 受け付けたキー入力の数
+	typedSeq      uint64          // 最後に文字の入力・削除を行ったキー入力の番号 (取り消しの単位をまとめる)
+	killSeq       uint64          // 最後に削除した内容をレジスタに書き込んだキー入力の番号 (続けて削除した内容を追記する)
+	Macros        map[rune][]rune // キーボードマクロ (レジスタ名 -> キー入力)
+	recording     rune            // 記録中のマクロのレジスタ名 (記録していない場合は0)
+	recordKeys    []rune          // 記録中のキー入力
+	recordEnd     int             // recordKeys内の入力途中のキー列を含まない長さ
+	lastMacro     rune            // 最後に記録・再生したマクロのレジスタ名
+	playing       int             // 再生中のマクロの深さ (再生していない場合は0)
+	failed        bool            // 再生中にコマンドが失敗したかどうか (再生を中断する)
+	suspended     bool            // 描画を停止中かどうか (停止中は描画の関数が何もせず、再開後にまとめて描画する)
+	brackets      []Cursor        // 強調表示中のカーソル位置の括弧と対応する括弧の位置
+	bracketEditor *Editor         // 強調表示中の括弧のエディタ
+	pasted        []rune          // 貼り付け中の入力 (貼り付け中でない場合はnil)
+	pastedAt      time.Time       // 最後に貼り付け中の入力を受け取った時刻
+}
+
+// キー入力をエディタのアクションに変換する入力レイヤー (Vimのモード編集など)
+type KeyLayer interface {
+	HandleKey(v *View, r rune) (handled bool, exitCode uint8) // 処理しなかったキーはデフォルトのキー割り当てで処理する
+	ModeName() string                                         // ステータスバーに表示するモード名 (空の場合はデフォルト)
+	Pending() bool                                            // 入力途中のキー列があるかどうか
+}
+
+// テキストエリアに重ねて表示する入力UI
+type Overlay interface {
+	Draw(v *View)                      // オーバーレイの描画
+	CursorPos(v *View) (col, row uint) // 入力カーソルの位置
+	HandleKey(v *View, r rune) uint8   // キー入力の処理
+}
+
+func NewView() *View {
+	v := new(View)
+	v.Term = core.NewUnixTerm()
+	v.Event = NewEvent()
+	v.Tabs = make([]*Buffer, 0)
+	v.Buffers = NewBufferRegistry()
+	v.WinCol = 0
+	v.WinRow = 0
+	v.Actions = newActions()
+	v.Keymap = defaultKeymap()
+	v.RecentActions = make([]string, 0)
+	v.Config = new(Config)
+	v.LastInput = time.Now()
+	return v
+}
+
+func (v *View) MainLoop() {
+	e := v.Event
+	e.NotifySignal() // シグナルの読み取り
+
+	exit := make(chan interface{})
+	defer close(exit)
+	go e.ScanInput(exit) // キー入力の読み取り用
+
+	journal := time.NewTicker(SWAP_INTERVAL) // スワップファイルへの定期的な書き出し
+	defer journal.Stop()
+	autosave := time.NewTicker(AUTOSAVE_TICK) // 入力がない間の自動保存
+	defer autosave.Stop()
+	status := time.NewTicker(STATUS_TICK) // ステータスバーの時計の更新
+	defer status.Stop()
+	paste := time.NewTicker(PASTE_TIMEOUT) // 終了の通知が届かない貼り付けの確認
+	defer paste.Stop()
+
+	Loop:
+		for {
+			select {
+			case r := <-e.Key: // キーイベント受け取り
+				exitCode := v.processInput(r)
+				if exitCode != 0 {
+					break Loop
+				}
+			case m := <-e.Mouse: // マウスイベント受け取り
+				v.processMouse(m)
+				v.UpdateBracketMatch()
+			case paths, ok := <-v.fileUpdates(): // ファイル一覧の走査結果の受け取り
+				added, replaced := v.Files.Receive(paths, ok)
+				if f, isFinder := v.Overlay.(*Finder); isFinder {
+					f.Update(v, added, replaced)
+				}
+			case ev, ok := <-v.sidebarEvents(): // ファイルツリーの変更通知
+				v.Sidebar.Receive(v, ev, ok)
+			case ev, ok := <-v.fileEvents(): // 開いているファイルの変更通知
+				v.ReceiveFileEvent(ev, ok)
+			case <-journal.C:
+				v.JournalSwaps()
+			case <-autosave.C:
+				v.AutosaveIdle()
+			case <-status.C:
+				v.TickStatusBar()
+			case <-paste.C:
+				v.ExpirePaste()
+			case sig := <-e.Signal: // OSシグナルの受け取り
+				switch sig {
+					case syscall.SIGWINCH:
+						v.UpdateWinSize()
+						v.Reflesh()
+					case syscall.SIGHUP, syscall.SIGTERM: // 未保存の変更をスワップファイルに残して終了
+						v.JournalSwaps()
+						v.Term.Restore()
+						os.Exit(1)
+				}
+			}
+		}
+}
+
+// 画面サイズの変更
+func (v *View) UpdateWinSize() {
+	row, col := v.Term.GetWinSize()
+	v.WinRow = row
+	v.WinCol = col
+}
+
+// ファイル一覧の走査結果の通知チャネル (走査中でなければnil)
+func (v *View) fileUpdates() chan []string {
+	if v.Files == nil {
+		return nil
+	}
+	return v.Files.Updates
+}
+
+// ファイルツリーの変更通知チャネル (監視していなければnil)
+func (v *View) sidebarEvents() chan core.WatchEvent {
+	if v.Sidebar == nil || v.Sidebar.watcher == nil {
+		return nil
+	}
+	return v.Sidebar.watcher.Events
+}
+
+// 開いているファイルの変更通知チャネル (監視していなければnil)
+func (v *View) fileEvents() chan core.WatchEvent {
+	if v.FileWatcher == nil {
+		return nil
+	}
+	return v.FileWatcher.Events
+}
+
+// ステータスバーにメッセージを表示 (オーバーレイの表示中は閉じた後に表示)
+func (v *View) SetMessage(format string, a ...interface{}) {
+	v.Message = fmt.Sprintf(format, a...)
+	if v.Overlay == nil {
+		v.UpdateStatusBar()
+	}
+}
+
+// テキストエリアの左端の列 (ファイルツリーの表示中はその右側)
+func (v *View) TextLeft() uint {
+	if v.Sidebar != nil && v.Sidebar.Visible {
+		return v.Sidebar.Width + 1
+	}
+	return 1
+}
+
+// テキストエリアに表示できる行数 (全てのペインの合計)
+func (v *View) TextHeight() uint {
+	if v.WinRow < 3 {
+		return 1
+	}
+	return uint(v.WinRow) - 2
+}
+
+// カーソルが表示範囲に入るようにスクロール位置を調整
+func (v *View) ScrollToCursor() {
+	cTab := v.GetCurrentTab()
+	cTab.revealRow(cTab.Cursor.Row) // 閉じている範囲に隠れている位置への移動は範囲を開く
+	height := int(v.Focus.TextHeight())
+	if cTab.Cursor.Row < cTab.ScrollRow {
+		cTab.ScrollTargetRow(cTab.Cursor.Row)
+	} else if cTab.screenOffset(cTab.closedFolds(), cTab.Cursor.Row) >= height {
+		cTab.ScrollTargetRow(cTab.stepVisible(cTab.Cursor.Row, 1-height))
+	}
+}
+
+// ペインの幅に合わせて切り詰めた (または空白で埋めた) 行の文字列 (幅は表示上のセル数)
+func (p *Pane) clipRow(row []rune) string {
+	width := p.TextWidth()
+	if width < 0 {
+		width = 0
+	}
+	tabSize := int(p.Editor.TabSize)
+	row = row[:utils.IndexAtCell(row, width, tabSize)]
+	return string(row) + strings.Repeat(" ", width-utils.CellsBefore(row, len(row), tabSize))
+}
+
+// オーバーレイの表示
+func (v *View) OpenOverlay(o Overlay) {
+	v.Overlay = o
+	o.Draw(v)
+	v.RefleshCursor()
+}
+
+// オーバーレイを閉じて画面を再描画
+func (v *View) CloseOverlay() {
+	v.Overlay = nil
+	v.Reflesh()
+}
+
+// タブの追加
+func (v *View) AddTab(filePath string) {
+	v.addBuffer(NewBuffer(filePath, 4))
+}
+
+// 無題の空のタブの追加
+func (v *View) AddUntitledTab() {
+	v.AddTab("")
+	v.Tabs[len(v.Tabs)-1].InitEmpty()
+}
+
+// ファイルを新しいタブで開く
+// 既に開いている場合はそのバッファに移動し、未編集の無題のタブしかない場合はそのタブを置き換える
+func (v *View) OpenFile(filePath string) {
+	if b := v.FindBuffer(filePath); b != nil {
+		if b != v.GetCurrentTab().Buffer {
+			v.PushJump()
+		}
+		v.AutosaveTabSwitch()
+		v.focusTab(b)
+		v.Reflesh()
+		return
+	}
+	b := NewBuffer(filePath, 4)
+	if err := b.LoadFile(); err != nil {
+		v.SetMessage("Error: %v", err)
+		return
+	}
+	v.PushJump()
+	if len(v.Tabs) == 1 && v.Tabs[0].FilePath == "" && v.Tabs[0].Label == "" && v.Tabs[0].IsSaved {
+		old := v.Tabs[0]
+		v.Buffers.Remove(old)
+		v.Buffers.Add(b)
+		v.Tabs[0] = b
+		for _, p := range v.Panes() {
+			p.Forget(old, b)
+		}
+		v.forgetJumps(old)
+	} else {
+		v.addBuffer(b)
+	}
+	v.MoveTab(len(v.Tabs) - 1)
+	v.watchFile(b)
+	v.Reflesh()
+	v.CheckSwaps([]*Buffer{b})
+}
+
+// 現在のタブの削除 (最後のタブの場合はfalseを返す)
+func (v *View) DeleteTab() bool {
+	return v.DeleteBuffer(v.GetCurrentTab().Buffer)
+}
+
+// 指定したインデックスのタブを現在のペインに表示
+func (v *View) MoveTab(idx int) bool {
+	if idx < 0 || idx >= len(v.Tabs) {
+		return false
+	}
+	if v.Focus == nil { // 最初のタブの表示時にペインを作成
+		v.Focus = newPane(v.Tabs[idx])
+		v.Layout = v.Focus.node
+		v.UpdateLayout()
+		return true
+	}
+	v.Focus.Show(v.Tabs[idx])
+	return true
+}
+
+// 次のタブへ移動
+func (v *View) NextTab() bool {
+	return v.MoveTab(v.TabIndex() + 1)
+}
+
+// 前のタブへ移動
+func (v *View) PrevTab() bool {
+	return v.MoveTab(v.TabIndex() - 1)
+}
+
+// 現在のペインに表示しているタブのインデックス
+func (v *View) TabIndex() int {
+	for i, b := range v.Tabs {
+		if b == v.Focus.Editor.Buffer {
+			return i
+		}
+	}
+	return -1
+}
+
+// 現在のペインのエディタの取得
+func (v *View) GetCurrentTab() *Editor {
+	return v.Focus.Editor
+}
+
+// ペインの1行分の描画 (フォーカスのあるペインのカーソル行は強調表示)
+func (v *View) DrawRow(p *Pane, lineNum uint) {
+	if v.suspended {
+		return
+	}
+	e := p.Editor
+	folds := e.closedFolds()
+	if f, ok := foldAt(folds, lineNum); ok && f.Start != int(lineNum) { // 閉じている範囲に隠れている行
+		return
+	}
+	v.drawRowAt(p, lineNum, e.screenOffset(folds, lineNum), folds)
+}
+
+// 画面上の位置offset (スクロール位置からの表示上の行数) への行の描画
+// 閉じている折りたたみ範囲は最初の行に隠している行数を付けて1行で表示する
+func (v *View) drawRowAt(p *Pane, lineNum uint, offset int, folds []core.FoldRange) {
+	e := p.Editor
+	if lineNum < e.ScrollRow || offset < 0 || offset >= int(p.Height) || p.Width == 0 {
+		return
+	}
+	defer v.Term.ResetStyle()
+	v.Term.MoveCursorPos(p.Left, p.Top+uint(offset))
+	if lineNum > uint(len(e.Lines)) { // ファイルの末尾より後
+		fmt.Print(strings.Repeat(" ", int(p.Width)))
+		return
+	}
+	fold, folded := foldAt(folds, lineNum)
+	gutter := padLeft(fmt.Sprintf("%d  ", lineNum), GUTTER_WIDTH)
+	if folded {
+		gutter = padLeft(fmt.Sprintf("%d%c ", lineNum, FOLD_MARKER), GUTTER_WIDTH)
+	}
+	if int(p.Width) < GUTTER_WIDTH {
+		gutter = padLeft(gutter, int(p.Width))
+	}
+	if p == v.Focus && e.IsTargetRow(lineNum) {
+		v.Term.SetBGColor(235)
+		v.Term.SetBold()
+		fmt.Print(gutter)
+		v.Term.ResetStyle()
+		v.Term.SetBGColor(235)
+	} else {
+		v.Term.SetColor(240)
+		fmt.Print(gutter)
+		v.Term.ResetStyle()
+	}
+	line := e.Lines[lineNum-1].GetAll()
+	length := len(line)
+	if folded {
+		line = append(line, []rune(foldSummary(fold))...)
+	}
+	text := []rune(p.clipRow(line))
+	// 選択範囲 (行末の改行が選択されている場合は直後の1文字分)・対応する括弧・追加のカーソルの位置を強調表示
+	styles := make([]string, len(text))
+	marked := false
+	mark := func(from uint, to uint, style string) {
+		for i := int(from - 1); i < min(int(to-1), len(text)); i++ {
+			styles[i] = style
+			marked = true
+		}
+	}
+	if folded {
+		mark(uint(length)+1, uint(len(line))+1, "fold")
+	}
+	if from, to, ok := e.selectedCols(lineNum); ok {
+		mark(from, to, "selection")
+	}
+	for _, r := range e.caretSelectedCols(lineNum) {
+		mark(r[0], r[1], "selection")
+	}
+	for _, col := range v.bracketCols(e, lineNum) {
+		mark(col, col+1, "bracket.match")
+	}
+	for _, col := range e.caretCols(lineNum) {
+		mark(col, col+1, "cursor.secondary")
+	}
+	if !marked {
+		fmt.Print(string(text))
+		return
+	}
+	for start := 0; start < len(text); {
+		end := start + 1
+		for end < len(text) && styles[end] == styles[start] {
+			end++
+		}
+		if styles[start] != "" {
+			v.Config.Style(styles[start]).Apply(v.Term)
+		} else {
+			v.Term.ResetStyle()
+			if p == v.Focus && e.IsTargetRow(lineNum) {
+				v.Term.SetBGColor(235)
+			}
+		}
+		fmt.Print(string(text[start:end]))
+		start = end
+	}
+}
+
+// ペイン全体の描画
+func (v *View) DrawPane(p *Pane) {
+	e := p.Editor
+	e.clampCursor()
+	if v.suspended {
+		return
+	}
+	folds := e.closedFolds()
+	row := e.ScrollRow
+	for i := 0; i < int(p.Height); i++ {
+		v.drawRowAt(p, row, i, folds)
+		row = nextVisibleRow(folds, row)
+	}
+}
+
+// 全てのペインと区切り線の描画
+func (v *View) DrawAllRow() {
+	defer v.RefleshCursor()
+	for _, p := range v.Panes() {
+		v.DrawPane(p)
+	}
+	v.drawSeparators(v.Layout)
+}
+
+// タブバーの描画 (入り切らない場合は現在のタブが見えるようにスクロールし、両端に隠れたタブの数を表示)
+func (v *View) UpdateTabBar() {
+	if v.suspended {
+		return
+	}
+	defer v.RefleshCursor()
+	defer v.Term.ResetStyle()
+	v.Term.MoveCursorPos(1, 1)
+	v.Term.ClearRow()
+	v.Term.SetBGColor(235)
+	for i := 0; i < int(v.WinCol); i++ {
+		fmt.Print(" ")
+	}
+	slots, hiddenLeft, hiddenRight := v.layoutTabBar()
+	current := v.TabIndex()
+	for _, s := range slots {
+		tab := v.Tabs[s.Idx]
+		v.Term.ResetStyle()
+		v.Term.MoveCursorPos(s.Col, 1)
+		if s.Idx == current {
+			v.Term.SetBold()
+			v.Term.SetColor(25)
+			fmt.Printf(" %s ", s.Label)
+			v.Term.ResetStyle()
+		} else {
+			v.Term.SetBGColor(235)
+			fmt.Printf(" %s ", s.Label)
+		}
+		if !tab.IsSaved {
+			fmt.Print("* ")
+		}
+		fmt.Print("|")
+	}
+	v.Term.ResetStyle()
+	v.Term.SetBGColor(237)
+	if hiddenLeft > 0 {
+		v.Term.MoveCursorPos(1, 1)
+		fmt.Print(padRight(fmt.Sprintf("<%d", hiddenLeft), TAB_INDICATOR_WIDTH))
+	}
+	if hiddenRight > 0 {
+		v.Term.MoveCursorPos(uint(v.WinCol)-TAB_INDICATOR_WIDTH+1, 1)
+		fmt.Print(padLeft(fmt.Sprintf("%d>", hiddenRight), TAB_INDICATOR_WIDTH))
+	}
+}
+
+func (v *View) Reflesh() {
+	v.UpdateLayout()
+	if v.suspended { // 描画の停止中はペインの配置の計算のみ
+		return
+	}
+	defer v.RefleshCursor()
+	v.Term.ClearAll()
+	v.UpdateTabBar()
+	v.DrawAllRow()
+	v.DrawSidebar()
+	v.UpdateStatusBar()
+	if v.Overlay != nil {
+		v.Overlay.Draw(v)
+	}
+}
+
+func (v *View) RefleshTextField() {
+	v.UpdateLayout()
+	if v.suspended {
+		return
+	}
+	defer v.RefleshCursor()
+	v.Term.MoveCursorPos(1, 2)
+	v.Term.ClearAfterCursor()
+	v.DrawAllRow()
+	v.DrawSidebar()
+	v.UpdateStatusBar()
+	if v.Overlay != nil {
+		v.Overlay.Draw(v)
+	}
+}
+
+// 指定した行の再描画 (同じバッファを表示している全てのペイン)
+func (v *View) RefleshTargetRow(rowNum uint) {
+	if v.suspended {
+		return
+	}
+	defer v.RefleshCursor()
+	for _, p := range v.Panes() {
+		if p.Editor.Buffer == v.GetCurrentTab().Buffer {
+			if p != v.Focus {
+				p.Editor.clampCursor()
+			}
+			v.DrawRow(p, rowNum)
+		}
+	}
+}
+
+// 入力位置にカーソルを移動
+func (v *View) RefleshCursor() {
+	if v.suspended {
+		return
+	}
+	if v.Overlay != nil {
+		v.Term.MoveCursorPos(v.Overlay.CursorPos(v))
+		return
+	}
+	if v.Sidebar != nil && v.Sidebar.Focused {
+		v.Term.MoveCursorPos(v.Sidebar.CursorPos(v))
+		return
+	}
+	v.Term.MoveCursorPos(v.cursorScreenPos())
+}
+
+func (v *View) ScrollUp() {
+	cTab := v.GetCurrentTab()
+	if cTab.ScrollRow >= cTab.Cursor.Row {
+		cTab.ScrollUp()
+		v.Reflesh()
+	} else {
+		v.RefleshTargetRow(nextVisibleRow(cTab.closedFolds(), cTab.Cursor.Row))
+		v.RefleshTargetRow(cTab.Cursor.Row)
+		v.RefleshCursor()
+	}
+	v.UpdateStatusBar()
+}
+
+func (v *View) ScrollDown() {
+	cTab := v.GetCurrentTab()
+	folds := cTab.closedFolds()
+	if cTab.screenOffset(folds, cTab.Cursor.Row) >= int(v.Focus.TextHeight())-1 {
+		cTab.ScrollDown()
+		v.Reflesh()
+	} else {
+		v.RefleshTargetRow(prevVisibleRow(folds, cTab.Cursor.Row))
+		v.RefleshTargetRow(cTab.Cursor.Row)
+		v.RefleshCursor()
+	}
+	v.UpdateStatusBar()
+}
@@ -0,0 +1,561 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/broccolingual/Xanadu/core"
+)
+
+// Vimのモード
+type VimMode int8
+
+const (
+	VIM_NORMAL       VimMode = iota // ノーマルモード
+	VIM_INSERT                      // 挿入モード
+	VIM_VISUAL                      // ビジュアルモード (文字単位)
+	VIM_VISUAL_LINE                 // ビジュアルモード (行単位)
+	VIM_VISUAL_BLOCK                // ビジュアルモード (矩形)
+)
+
+// ステータスバーに表示するモード名
+var vimModeNames = map[VimMode]string{
+	VIM_NORMAL:       "NORMAL",
+	VIM_INSERT:       "INSERT",
+	VIM_VISUAL:       "VISUAL",
+	VIM_VISUAL_LINE:  "V-LINE",
+	VIM_VISUAL_BLOCK: "V-BLOCK",
+}
+
+// ビジュアルモードに対応する選択範囲の種類
+var vimSelectionModes = map[VimMode]SelectionMode{
+	VIM_VISUAL:       SELECT_INCLUSIVE,
+	VIM_VISUAL_LINE:  SELECT_LINE,
+	VIM_VISUAL_BLOCK: SELECT_BLOCK,
+}
+
+// コマンドの解析結果
+type vimParse int8
+
+const (
+	VIM_PENDING  vimParse = iota // 続きのキー入力待ち
+	VIM_INVALID                  // 該当するコマンドがない
+	VIM_COMPLETE                 // コマンドの入力完了
+)
+
+// Vim風のモード編集のレイヤー
+// キー入力をコマンド (レジスタ・回数・演算子・移動・テキストオブジェクト) として解析し、エディタの操作に変換する
+type Vim struct {
+	Mode        VimMode
+	keys        []rune  // 入力中のコマンド
+	change      []rune  // 記録中の変更コマンドのキー入力 (挿入モードでの入力を含む)
+	recording   bool    // 変更コマンドを記録中かどうか
+	lastChange  []rune  // .で繰り返す直前の変更コマンド
+	insertKeys  int     // change内の挿入モードでの入力の開始位置
+	insertCount int     // 挿入モードを抜けた時に入力を繰り返す回数 (3iなど)
+	repeating   bool    // 挿入した内容を繰り返し中かどうか
+	lastFind    [2]rune // 直前のf/F/t/Tのコマンドと文字 (;と,で繰り返す)
+	undoBuf     *Buffer // 挿入モードを抜けるまで取り消しの単位をまとめているバッファ
+	visualRows  [2]uint // 直前のビジュアルモードの選択範囲の行 ('<と'>)
+	editor      *Editor // 現在のモードを開始したエディタ (別のエディタに移った場合はノーマルモードに戻す)
+}
+
+// 解析したコマンド
+type vimCmd struct {
+	Reg     rune       // レジスタ名 ('"'は無名)
+	Count   int        // 回数 (省略した場合は0)
+	Arg     rune       // f/t/rなどに続けて入力した文字
+	Pending bool       // 演算子の範囲を求める移動かどうか
+	Start   Cursor     // 演算子の範囲の開始位置
+	End     Cursor     // 演算子の範囲の終了位置 (含まない)
+	Type    motionType // 演算子の範囲の種類 (行単位の場合はStart.Row~End.Rowの行)
+	Block   bool       // 矩形の範囲 (選択範囲を使用する)
+	Visual  bool       // ビジュアルモードの選択範囲に対する操作かどうか
+}
+
+// 回数 (省略した場合は1)
+func (c *vimCmd) count1() int {
+	if c.Count == 0 {
+		return 1
+	}
+	return c.Count
+}
+
+// 新しいVimのレイヤーの取得 (ノーマルモードから開始)
+func NewVim() *Vim {
+	return &Vim{Mode: VIM_NORMAL}
+}
+
+func (m *Vim) Pending() bool {
+	return len(m.keys) > 0
+}
+
+func (m *Vim) ModeName() string {
+	name := vimModeNames[m.Mode]
+	if len(m.keys) > 0 {
+		name += " " + keyString(m.keys)
+	}
+	return name
+}
+
+// キー列の表示文字列
+func keyString(keys []rune) string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		if isInsertable(k) {
+			names[i] = string(k)
+		} else {
+			names[i] = "<" + keyName(k) + ">"
+		}
+	}
+	return strings.Join(names, "")
+}
+
+func (m *Vim) HandleKey(v *View, r rune) (bool, uint8) {
+	m.followFocus(v)
+	if m.Mode == VIM_INSERT {
+		return m.handleInsert(v, r)
+	}
+	if r == 'q' && len(m.keys) == 0 && v.recording != 0 { // マクロの記録中のqは記録の終了
+		v.StopRecording()
+		return true, 0
+	}
+	m.keys = append(m.keys, r)
+	c, b, status := m.parse(v)
+	switch status {
+	case VIM_PENDING:
+		v.UpdateStatusBar()
+		return true, 0
+	case VIM_INVALID:
+		keys := m.keys
+		m.keys = nil
+		v.UpdateStatusBar()
+		if len(keys) == 1 && !isInsertable(r) { // 割り当てのない制御文字・特殊キーはデフォルトのキー割り当てで処理
+			return false, 0
+		}
+		v.Fail()
+		return true, 0
+	}
+	keys := m.keys
+	m.keys = nil
+	return true, m.execute(v, c, b, keys)
+}
+
+// 挿入モードでのキー入力 (ESC以外はデフォルトの入力として処理する)
+func (m *Vim) handleInsert(v *View, r rune) (bool, uint8) {
+	if m.recording && !m.repeating {
+		m.change = append(m.change, r)
+	}
+	if r != ESC {
+		return false, 0
+	}
+	if m.recording && !m.repeating { // 記録している変更の場合のみ (ビジュアルモードからの挿入は記録しない)
+		typed := m.change[m.insertKeys : len(m.change)-1]
+		m.repeating = true
+		for i := 1; i < m.insertCount; i++ {
+			for _, k := range typed {
+				v.handleKey(k)
+			}
+		}
+		m.repeating = false
+	}
+	if m.recording {
+		m.lastChange = m.change
+		m.recording = false
+	}
+	m.setMode(v, VIM_NORMAL)
+	e := v.GetCurrentTab()
+	e.ClearCarets() // 矩形への挿入の各行のカーソルは解除
+	e.MovePrevCol()
+	m.endUndo()
+	m.refresh(v)
+	return true, 0
+}
+
+// モードの切り替え (ビジュアルモードの場合は選択範囲の種類も切り替える)
+func (m *Vim) setMode(v *View, mode VimMode) {
+	e := v.GetCurrentTab()
+	if sel, ok := vimSelectionModes[mode]; ok {
+		e.StartSelectionMode(sel)
+	} else if e.Anchor != nil {
+		m.visualRows = [2]uint{min(e.Anchor.Row, e.Cursor.Row), max(e.Anchor.Row, e.Cursor.Row)}
+		e.ClearSelection()
+	}
+	m.Mode = mode
+	m.editor = e
+	if mode == VIM_INSERT {
+		v.Term.SetCursorShape(core.CURSOR_BAR)
+	} else {
+		v.Term.SetCursorShape(core.CURSOR_BLOCK)
+	}
+}
+
+// タブ・ペインの切り替えで入力先のエディタが変わった場合はノーマルモードに戻す
+// 元のエディタの選択範囲は解除し、挿入モードでの変更はそこまでで記録を終える
+func (m *Vim) followFocus(v *View) {
+	if m.Mode == VIM_NORMAL || m.editor == v.GetCurrentTab() {
+		return
+	}
+	if m.editor != nil {
+		m.editor.ClearCarets()
+		m.editor.ClearSelection()
+	}
+	if m.recording {
+		m.lastChange = m.change
+		m.recording = false
+	}
+	m.endUndo()
+	m.Mode = VIM_NORMAL
+	m.editor = v.GetCurrentTab()
+	v.Term.SetCursorShape(core.CURSOR_BLOCK)
+}
+
+// ビジュアルモードで選択範囲がない場合はノーマルモードに戻してtrueを返す
+func (m *Vim) lostSelection(v *View) bool {
+	if v.GetCurrentTab().Anchor != nil {
+		return false
+	}
+	m.setMode(v, VIM_NORMAL)
+	return true
+}
+
+// 挿入モードの開始 (countは抜けた時に入力を繰り返す回数)
+func (m *Vim) startInsert(v *View, count int) {
+	m.setMode(v, VIM_INSERT)
+	m.insertCount = count
+	m.insertKeys = len(m.change)
+}
+
+// 変更の前に取り消しの単位をまとめ始める
+func (m *Vim) beginUndo(e *Editor) {
+	if m.undoBuf != nil {
+		return
+	}
+	m.undoBuf = e.Buffer
+	e.BeginUndoGroup()
+}
+
+// 取り消しの単位をまとめるのを終了
+func (m *Vim) endUndo() {
+	if m.undoBuf != nil {
+		m.undoBuf.EndUndoGroup()
+		m.undoBuf = nil
+	}
+}
+
+// 入力中のキーの先頭の回数の読み取り (0から始まる場合は回数ではない)
+func readCount(keys []rune, i int) (int, int) {
+	count := 0
+	for i < len(keys) && keys[i] >= '0' && keys[i] <= '9' && !(count == 0 && keys[i] == '0') {
+		count = count*10 + int(keys[i]-'0')
+		i++
+	}
+	return count, i
+}
+
+// キー列に一致する割り当ての検索 (一致した割り当てと使用したキーの数)
+func lookupBinding(table map[string]*vimBinding, keys []rune) (*vimBinding, int, vimParse) {
+	for n := 1; n <= 2 && n <= len(keys); n++ {
+		b, ok := table[string(keys[:n])]
+		if !ok {
+			continue
+		}
+		if b.Arg {
+			if len(keys) == n {
+				return nil, 0, VIM_PENDING
+			}
+			return b, n + 1, VIM_COMPLETE
+		}
+		return b, n, VIM_COMPLETE
+	}
+	prefix := string(keys)
+	for name := range table {
+		if len(name) > len(prefix) && strings.HasPrefix(name, prefix) {
+			return nil, 0, VIM_PENDING
+		}
+	}
+	return nil, 0, VIM_INVALID
+}
+
+// 入力中のキーの解析
+// [回数][\"レジスタ][回数](移動 | コマンド | 演算子[回数](移動 | テキストオブジェクト | 同じ演算子))
+func (m *Vim) parse(v *View) (*vimCmd, *vimBinding, vimParse) {
+	keys := m.keys
+	count, i := readCount(keys, 0)
+	c := &vimCmd{Reg: '"', Count: count}
+	if i < len(keys) && keys[i] == '"' {
+		if i+1 >= len(keys) {
+			return nil, nil, VIM_PENDING
+		}
+		c.Reg = keys[i+1]
+		count, j := readCount(keys, i+2)
+		if count > 0 {
+			c.Count = c.count1() * count
+		}
+		i = j
+	}
+	if i >= len(keys) {
+		return nil, nil, VIM_PENDING
+	}
+	table := vimNormalKeys
+	if m.Mode != VIM_NORMAL {
+		table = vimVisualKeys
+	}
+	b, n, status := lookupBinding(table, keys[i:])
+	if status != VIM_COMPLETE {
+		if status == VIM_INVALID && m.Mode != VIM_NORMAL && (keys[i] == 'i' || keys[i] == 'a') {
+			return m.parseObject(v, c, nil, keys, i)
+		}
+		return nil, nil, status
+	}
+	if b.Arg {
+		c.Arg = keys[i+n-1]
+	}
+	if b.Kind != VIM_OPERATOR || m.Mode != VIM_NORMAL {
+		return c, b, VIM_COMPLETE
+	}
+
+	// 演算子に続く範囲
+	name := string(keys[i : i+n])
+	j := i + n
+	count2, j := readCount(keys, j)
+	if count2 > 0 {
+		c.Count = c.count1() * count2
+	}
+	if j >= len(keys) {
+		return nil, nil, VIM_PENDING
+	}
+	rest := string(keys[j:])
+	if rest == name || rest == name[len(name)-1:] { // dd・yy・>>・g~~など (行単位)
+		return m.lineRange(v, c, b)
+	}
+	if strings.HasPrefix(name, rest) || (len(name) == 2 && rest == name[:1]) {
+		return nil, nil, VIM_PENDING
+	}
+	if keys[j] == 'i' || keys[j] == 'a' {
+		return m.parseObject(v, c, b, keys, j)
+	}
+	motion, n2, status := lookupBinding(vimMotionKeys, keys[j:])
+	if status != VIM_COMPLETE {
+		return nil, nil, status
+	}
+	if motion.Arg {
+		c.Arg = keys[j+n2-1]
+	}
+	if name == "c" && (keys[j] == 'w' || keys[j] == 'W') { // cwはceと同じ (空白の上以外)
+		e := v.GetCurrentTab()
+		line := e.currentLine()
+		if int(e.Cursor.Col) <= len(line) && core.ClassOf(line[e.Cursor.Col-1]) != core.CHAR_SPACE {
+			if keys[j] == 'W' {
+				motion = vimMotionKeys["E"]
+			} else {
+				motion = vimMotionKeys["e"]
+			}
+		}
+	}
+	if !m.motionRange(v, c, motion) {
+		return nil, nil, VIM_INVALID
+	}
+	return c, b, VIM_COMPLETE
+}
+
+// 行単位の範囲 (現在の行から回数分の行)
+func (m *Vim) lineRange(v *View, c *vimCmd, op *vimBinding) (*vimCmd, *vimBinding, vimParse) {
+	e := v.GetCurrentTab()
+	last := e.Cursor.Row + uint(c.count1()) - 1
+	if last > uint(len(e.Lines)) {
+		last = uint(len(e.Lines))
+	}
+	c.Start = Cursor{Row: e.Cursor.Row, Col: 1}
+	c.End = Cursor{Row: last, Col: 1}
+	c.Type = MOTION_LINEWISE
+	return c, op, VIM_COMPLETE
+}
+
+// 移動による演算子の範囲 (移動できなかった場合はfalse)
+func (m *Vim) motionRange(v *View, c *vimCmd, motion *vimBinding) bool {
+	e := v.GetCurrentTab()
+	orig := *e.Cursor
+	c.Pending = true
+	c.Type = motion.Type // 移動によっては種類を変更する (;と,など)
+	ok := motion.Move(m, v, e, c)
+	c.Pending = false
+	start, end := Cursor{Row: orig.Row, Col: orig.Col}, Cursor{Row: e.Cursor.Row, Col: e.Cursor.Col}
+	*e.Cursor = orig
+	if !ok {
+		return false
+	}
+	if end.Before(start) {
+		start, end = end, start
+	}
+	switch c.Type {
+	case MOTION_INCLUSIVE:
+		if int(end.Col) <= e.Lines[end.Row-1].Length() {
+			end.Col++
+		}
+	case MOTION_EXCLUSIVE:
+		// 次の行の先頭で終わる場合は前の行の行末までとする
+		if end.Col == 1 && end.Row > start.Row {
+			end = Cursor{Row: end.Row - 1, Col: uint(e.Lines[end.Row-2].Length()) + 1}
+		}
+	}
+	c.Start, c.End = start, end
+	return true
+}
+
+// テキストオブジェクト (opがnilの場合はビジュアルモードの選択範囲を広げる)
+func (m *Vim) parseObject(v *View, c *vimCmd, op *vimBinding, keys []rune, j int) (*vimCmd, *vimBinding, vimParse) {
+	if j+1 >= len(keys) {
+		return nil, nil, VIM_PENDING
+	}
+	e := v.GetCurrentTab()
+	start, end, typ, ok := textObject(e, keys[j+1], keys[j] == 'a')
+	if !ok {
+		return nil, nil, VIM_INVALID
+	}
+	c.Start, c.End, c.Type = start, end, typ
+	if op == nil {
+		return c, vimSelectObject, VIM_COMPLETE
+	}
+	return c, op, VIM_COMPLETE
+}
+
+// テキストオブジェクトの範囲 (w W " ' ` ( ) b [ ] { } B)
+func textObject(e *Editor, obj rune, around bool) (start Cursor, end Cursor, typ motionType, ok bool) {
+	row := e.Cursor.Row
+	line := e.currentLine()
+	col := int(e.Cursor.Col - 1)
+	typ = MOTION_EXCLUSIVE
+	switch obj {
+	case 'w', 'W':
+		classOf := core.ClassOf
+		if obj == 'W' {
+			classOf = core.BigClassOf
+		}
+		if len(line) == 0 {
+			return
+		}
+		s, t := core.WordObject(line, col, classOf, around)
+		return Cursor{Row: row, Col: uint(s) + 1}, Cursor{Row: row, Col: uint(t) + 1}, typ, true
+	case '"', '\'', '`':
+		s, t, found := core.QuoteObject(line, col, obj, around)
+		if !found {
+			return
+		}
+		return Cursor{Row: row, Col: uint(s) + 1}, Cursor{Row: row, Col: uint(t) + 1}, typ, true
+	}
+	pairs := map[rune][2]rune{
+		'(': {'(', ')'}, ')': {'(', ')'}, 'b': {'(', ')'},
+		'[': {'[', ']'}, ']': {'[', ']'},
+		'{': {'{', '}'}, '}': {'{', '}'}, 'B': {'{', '}'},
+	}
+	pair, found := pairs[obj]
+	if !found {
+		return
+	}
+	lineAt := func(r int) []rune { return e.Lines[r].GetAll() }
+	sr, sc, er, ec, found := core.PairObject(lineAt, len(e.Lines), int(row-1), col, pair[0], pair[1], around)
+	if !found {
+		return
+	}
+	start = Cursor{Row: uint(sr) + 1, Col: uint(sc) + 1}
+	end = Cursor{Row: uint(er) + 1, Col: uint(ec) + 1}
+	// 開き括弧が行末、閉じ括弧が行頭 (インデントのみの後) にある場合は間の行を行単位で選択
+	if !around && sr < er && sc >= len(lineAt(sr)) && core.FirstNonBlank(lineAt(er)) >= ec {
+		if er-sr < 2 {
+			return start, Cursor{Row: end.Row, Col: 1}, typ, true
+		}
+		return Cursor{Row: start.Row + 1, Col: 1}, Cursor{Row: end.Row - 1, Col: 1}, MOTION_LINEWISE, true
+	}
+	return start, end, typ, true
+}
+
+// コマンドの実行
+func (m *Vim) execute(v *View, c *vimCmd, b *vimBinding, keys []rune) uint8 {
+	e := v.GetCurrentTab()
+	if b.Alias != "" { // 別のコマンドとして解析し直す (xはdlなど)
+		prefix := keys[:len(keys)-len([]rune(b.Name))]
+		m.keys = append(append([]rune{}, prefix...), []rune(b.Alias)...)
+		c, alias, status := m.parse(v)
+		m.keys = nil
+		if status != VIM_COMPLETE {
+			return 0
+		}
+		return m.execute(v, c, alias, keys)
+	}
+	normal := m.Mode == VIM_NORMAL
+	if !normal && b.Kind == VIM_OPERATOR { // ビジュアルモードでは選択範囲に演算子を適用
+		if !m.visualRange(v, c) {
+			v.Fail()
+			m.refresh(v)
+			return 0
+		}
+	}
+	if b.Kind == VIM_MOTION {
+		if b.Jump {
+			v.PushJump()
+		}
+		if !b.Move(m, v, e, c) {
+			v.Fail()
+		}
+		m.refresh(v)
+		return 0
+	}
+	change := b.Change || (b.Kind == VIM_OPERATOR && b.Name != "y")
+	if change {
+		m.beginUndo(e)
+		if normal && !m.recording { // ノーマルモードでの変更は.で繰り返せるように記録
+			m.change = append([]rune{}, keys...)
+			m.recording = true
+		}
+	}
+	code := b.Run(m, v, c)
+	if m.Mode != VIM_INSERT {
+		m.endUndo()
+		if m.recording {
+			m.lastChange = m.change
+		}
+		m.recording = false
+	}
+	m.refresh(v)
+	return code
+}
+
+// ビジュアルモードの選択範囲を演算子の範囲とする (矩形の場合は選択を残す)
+// 選択範囲がない場合はノーマルモードに戻してfalseを返す
+func (m *Vim) visualRange(v *View, c *vimCmd) bool {
+	if m.lostSelection(v) {
+		return false
+	}
+	e := v.GetCurrentTab()
+	start, end, _ := e.Selection()
+	c.Start, c.End = start, end
+	c.Visual = true
+	m.visualRows = [2]uint{min(e.Anchor.Row, e.Cursor.Row), max(e.Anchor.Row, e.Cursor.Row)}
+	switch m.Mode {
+	case VIM_VISUAL:
+		c.Type = MOTION_EXCLUSIVE
+	case VIM_VISUAL_LINE:
+		c.Type = MOTION_LINEWISE
+		c.End = Cursor{Row: max(e.Anchor.Row, e.Cursor.Row), Col: 1}
+	case VIM_VISUAL_BLOCK:
+		c.Block = true
+		m.Mode = VIM_NORMAL
+		return true
+	}
+	m.setMode(v, VIM_NORMAL)
+	return true
+}
+
+// コマンドの実行後の再描画 (ノーマルモードではカーソルを行末の文字の上に収める)
+func (m *Vim) refresh(v *View) {
+	e := v.GetCurrentTab()
+	e.clampCursor()
+	if m.Mode == VIM_NORMAL {
+		if n := uint(e.Lines[e.Cursor.Row-1].Length()); e.Cursor.Col > n && n > 0 {
+			e.Cursor.Col = n
+		}
+	}
+	v.ScrollToCursor()
+	v.RefleshTextField()
+	v.UpdateTabBar()
+}
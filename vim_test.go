@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+// 端末に描画しないビューの作成 (各テキストを内容とするタブを開き、最初のタブを表示する)
+func newTestView(texts ...string) *View {
+	v := NewView()
+	v.suspended = true
+	v.WinCol, v.WinRow = 80, 24
+	for _, text := range texts {
+		v.AddUntitledTab()
+		v.Tabs[len(v.Tabs)-1].SetLines([]string{text})
+	}
+	v.MoveTab(0)
+	return v
+}
+
+func Test_Vim_FocusChange(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want []string // キー入力後の各タブの内容
+		mode VimMode
+	}{
+		{"Test #1", "v\x14d", []string{"abc", "xyz"}, VIM_NORMAL},
+		{"Test #2", "vl\x14x", []string{"abc", "yz"}, VIM_NORMAL},
+		{"Test #3", "V\x14J", []string{"abc", "xyz"}, VIM_NORMAL},
+		{"Test #4", "ia\x14b", []string{"aabc", "xyz"}, VIM_NORMAL},
+		{"Test #5", "v\x14v", []string{"abc", "xyz"}, VIM_VISUAL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestView("abc", "xyz")
+			m := NewVim()
+			v.Layer = m
+			for _, r := range tt.keys {
+				v.handleKey(r)
+			}
+			for i, b := range v.Tabs {
+				if got := b.LineStrings()[0]; got != tt.want[i] {
+					t.Errorf("tab %d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+			if m.Mode != tt.mode {
+				t.Errorf("Mode = %v, want %v", m.Mode, tt.mode)
+			}
+			if e := v.Focus.editors[v.Tabs[0]]; e != nil && e.Anchor != nil {
+				t.Errorf("selection of the previous tab is not cleared")
+			}
+		})
+	}
+}
@@ -0,0 +1,552 @@
+package main
+
+import (
+	"math"
+	"unicode"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+// Vimのキー割り当ての種類
+type vimKind int8
+
+const (
+	VIM_COMMAND  vimKind = iota // コマンド (単独で実行する)
+	VIM_MOTION                  // 移動 (単独で実行するか演算子の範囲とする)
+	VIM_OPERATOR                // 演算子 (続く移動・テキストオブジェクトの範囲に適用する)
+)
+
+// 移動による演算子の範囲の種類
+type motionType int8
+
+const (
+	MOTION_EXCLUSIVE motionType = iota // 移動先の文字を含まない
+	MOTION_INCLUSIVE                   // 移動先の文字を含む
+	MOTION_LINEWISE                    // 行単位
+)
+
+// Vimのキー割り当て
+type vimBinding struct {
+	Name   string     // キー列
+	Kind   vimKind    // 種類
+	Arg    bool       // 続けて1文字入力するかどうか (f・t・rなど)
+	Type   motionType // 移動の範囲の種類
+	Jump   bool       // 移動前の位置をジャンプリストに追加するかどうか
+	Change bool       // 内容を変更するコマンドかどうか (.で繰り返す)
+	Alias  string     // 別のキー列のコマンドとして実行する (xはdlなど)
+	Move   func(m *Vim, v *View, e *Editor, c *vimCmd) bool
+	Run    func(m *Vim, v *View, c *vimCmd) uint8
+}
+
+// キー割り当ての表の作成 (キー列を名前、kindを種類として設定する)
+func vimTable(kind vimKind, table map[string]*vimBinding) map[string]*vimBinding {
+	for keys, b := range table {
+		b.Name = keys
+		b.Kind = kind
+	}
+	return table
+}
+
+// キー割り当ての表の結合
+func vimMerge(tables ...map[string]*vimBinding) map[string]*vimBinding {
+	merged := make(map[string]*vimBinding)
+	for _, table := range tables {
+		for keys, b := range table {
+			merged[keys] = b
+		}
+	}
+	return merged
+}
+
+// 特殊キー・制御文字のキー列
+func vimKey(r rune) string {
+	return string(r)
+}
+
+// 移動
+var vimMotionKeys = vimTable(VIM_MOTION, map[string]*vimBinding{
+	"h":                   {Move: (*Vim).moveLeft},
+	vimKey(KEY_LEFT):      {Move: (*Vim).moveLeft},
+	vimKey(BACKSPACE):     {Move: (*Vim).moveLeft},
+	vimKey(CTRL_H):        {Move: (*Vim).moveLeft},
+	"l":                   {Move: (*Vim).moveRight},
+	vimKey(KEY_RIGHT):     {Move: (*Vim).moveRight},
+	" ":                   {Move: (*Vim).moveRight},
+	"j":                   {Type: MOTION_LINEWISE, Move: (*Vim).moveDown},
+	vimKey(KEY_DOWN):      {Type: MOTION_LINEWISE, Move: (*Vim).moveDown},
+	vimKey(CTRL_N):        {Type: MOTION_LINEWISE, Move: (*Vim).moveDown},
+	"k":                   {Type: MOTION_LINEWISE, Move: (*Vim).moveUp},
+	vimKey(KEY_UP):        {Type: MOTION_LINEWISE, Move: (*Vim).moveUp},
+	vimKey(CTRL_P):        {Type: MOTION_LINEWISE, Move: (*Vim).moveUp},
+	"+":                   {Type: MOTION_LINEWISE, Move: (*Vim).moveDownFirst},
+	vimKey(CTRL_M):        {Type: MOTION_LINEWISE, Move: (*Vim).moveDownFirst},
+	"-":                   {Type: MOTION_LINEWISE, Move: (*Vim).moveUpFirst},
+	"_":                   {Type: MOTION_LINEWISE, Move: (*Vim).moveLineFirst},
+	"w":                   {Move: vimWordForward(core.ClassOf)},
+	"W":                   {Move: vimWordForward(core.BigClassOf)},
+	"b":                   {Move: vimWordBackward(core.ClassOf)},
+	"B":                   {Move: vimWordBackward(core.BigClassOf)},
+	"e":                   {Type: MOTION_INCLUSIVE, Move: vimWordEnd(core.ClassOf)},
+	"E":                   {Type: MOTION_INCLUSIVE, Move: vimWordEnd(core.BigClassOf)},
+	"0":                   {Move: (*Vim).moveLineStart},
+	vimKey(KEY_HOME):      {Move: (*Vim).moveLineStart},
+	"^":                   {Move: (*Vim).moveFirstNonBlank},
+	"$":                   {Type: MOTION_INCLUSIVE, Move: (*Vim).moveLineEnd},
+	vimKey(KEY_END):       {Type: MOTION_INCLUSIVE, Move: (*Vim).moveLineEnd},
+	"|":                   {Move: (*Vim).moveColumn},
+	"gg":                  {Type: MOTION_LINEWISE, Jump: true, Move: vimGotoLine(false)},
+	"G":                   {Type: MOTION_LINEWISE, Jump: true, Move: vimGotoLine(true)},
+	"{":                   {Jump: true, Move: (*Vim).moveParagraphUp},
+	"}":                   {Jump: true, Move: (*Vim).moveParagraphDown},
+	"%":                   {Type: MOTION_INCLUSIVE, Jump: true, Move: (*Vim).moveMatch},
+	"f":                   {Arg: true, Type: MOTION_INCLUSIVE, Move: vimFind('f')},
+	"F":                   {Arg: true, Move: vimFind('F')},
+	"t":                   {Arg: true, Type: MOTION_INCLUSIVE, Move: vimFind('t')},
+	"T":                   {Arg: true, Move: vimFind('T')},
+	";":                   {Move: vimRepeatFind(false)},
+	",":                   {Move: vimRepeatFind(true)},
+	"H":                   {Type: MOTION_LINEWISE, Jump: true, Move: vimScreenRow(0)},
+	"M":                   {Type: MOTION_LINEWISE, Jump: true, Move: vimScreenRow(1)},
+	"L":                   {Type: MOTION_LINEWISE, Jump: true, Move: vimScreenRow(2)},
+	vimKey(CTRL_F):        {Type: MOTION_LINEWISE, Move: vimPage(2)},
+	vimKey(KEY_PAGE_DOWN): {Type: MOTION_LINEWISE, Move: vimPage(2)},
+	vimKey(CTRL_B):        {Type: MOTION_LINEWISE, Move: vimPage(-2)},
+	vimKey(KEY_PAGE_UP):   {Type: MOTION_LINEWISE, Move: vimPage(-2)},
+	vimKey(CTRL_D):        {Type: MOTION_LINEWISE, Move: vimPage(1)},
+	vimKey(CTRL_U):        {Type: MOTION_LINEWISE, Move: vimPage(-1)},
+})
+
+// 演算子
+var vimOperatorKeys = vimTable(VIM_OPERATOR, map[string]*vimBinding{
+	"d":  {Run: (*Vim).opDelete},
+	"c":  {Run: (*Vim).opChange},
+	"y":  {Run: (*Vim).opYank},
+	">":  {Run: vimShift(1)},
+	"<":  {Run: vimShift(-1)},
+	"g~": {Run: vimCase(toggleCase)},
+	"gu": {Run: vimCase(unicode.ToLower)},
+	"gU": {Run: vimCase(unicode.ToUpper)},
+})
+
+// ノーマルモードのコマンド
+var vimCommandKeys = vimTable(VIM_COMMAND, map[string]*vimBinding{
+	"x":                {Alias: "dl"},
+	vimKey(KEY_DELETE): {Alias: "dl"},
+	"X":                {Alias: "dh"},
+	"D":                {Alias: "d$"},
+	"C":                {Alias: "c$"},
+	"s":                {Alias: "cl"},
+	"S":                {Alias: "cc"},
+	"Y":                {Alias: "yy"},
+	"p":                {Change: true, Run: vimPaste(true)},
+	"P":                {Change: true, Run: vimPaste(false)},
+	"i":                {Change: true, Run: (*Vim).cmdInsert},
+	"a":                {Change: true, Run: (*Vim).cmdAppend},
+	"I":                {Change: true, Run: (*Vim).cmdInsertLineStart},
+	"A":                {Change: true, Run: (*Vim).cmdAppendLineEnd},
+	"o":                {Change: true, Run: vimOpenLine(true)},
+	"O":                {Change: true, Run: vimOpenLine(false)},
+	"~":                {Change: true, Run: (*Vim).cmdToggleCase},
+	"r":                {Arg: true, Change: true, Run: (*Vim).cmdReplace},
+	"J":                {Change: true, Run: (*Vim).cmdJoin},
+	"v":                {Run: vimVisual(VIM_VISUAL)},
+	"V":                {Run: vimVisual(VIM_VISUAL_LINE)},
+	vimKey(CTRL_V):     {Run: vimVisual(VIM_VISUAL_BLOCK)},
+	"u":                {Run: (*Vim).cmdUndo},
+	vimKey(CTRL_R):     {Run: (*Vim).cmdRedo},
+	".":                {Run: (*Vim).cmdRepeat},
+	":":                {Run: (*Vim).cmdEx},
+	"q":                {Arg: true, Run: (*Vim).cmdRecord},
+	"@":                {Arg: true, Run: (*Vim).cmdPlay},
+	"ZZ":               {Run: vimEx("x")},
+	"ZQ":               {Run: vimEx("q!")},
+	"zc":               {Run: vimAction("Fold")},
+	"zo":               {Run: vimAction("Unfold")},
+	"za":               {Run: vimAction("Toggle Fold")},
+	"zM":               {Run: vimAction("Fold All")},
+	"zR":               {Run: vimAction("Unfold All")},
+	vimKey(CTRL_O):     {Run: vimAction("Jump Back")},
+	vimKey(CTRL_I):     {Run: vimAction("Jump Forward")},
+	vimKey(ESC):        {Run: vimAction("Cancel")},
+})
+
+// ビジュアルモードの演算子 (選択範囲に適用する)
+var vimVisualOperatorKeys = vimTable(VIM_OPERATOR, map[string]*vimBinding{
+	"d": {Run: (*Vim).opDelete},
+	"c": {Run: (*Vim).opChange},
+	"y": {Run: (*Vim).opYank},
+	">": {Run: vimShift(1)},
+	"<": {Run: vimShift(-1)},
+	"~": {Run: vimCase(toggleCase)},
+	"u": {Run: vimCase(unicode.ToLower)},
+	"U": {Run: vimCase(unicode.ToUpper)},
+})
+
+// ビジュアルモードのコマンド
+var vimVisualCommandKeys = vimTable(VIM_COMMAND, map[string]*vimBinding{
+	"x":                {Alias: "d"},
+	vimKey(KEY_DELETE): {Alias: "d"},
+	"s":                {Alias: "c"},
+	"X":                {Change: true, Run: vimVisualLines((*Vim).opDelete)},
+	"D":                {Change: true, Run: vimVisualLines((*Vim).opDelete)},
+	"C":                {Change: true, Run: vimVisualLines((*Vim).opChange)},
+	"S":                {Change: true, Run: vimVisualLines((*Vim).opChange)},
+	"R":                {Change: true, Run: vimVisualLines((*Vim).opChange)},
+	"Y":                {Run: vimVisualLines((*Vim).opYank)},
+	"p":                {Change: true, Run: (*Vim).cmdVisualPaste},
+	"P":                {Change: true, Run: (*Vim).cmdVisualPaste},
+	"J":                {Change: true, Run: (*Vim).cmdVisualJoin},
+	"I":                {Change: true, Run: vimVisualInsert(false)},
+	"A":                {Change: true, Run: vimVisualInsert(true)},
+	"o":                {Run: (*Vim).cmdSwapEnds},
+	"O":                {Run: (*Vim).cmdSwapEnds},
+	"v":                {Run: vimVisual(VIM_VISUAL)},
+	"V":                {Run: vimVisual(VIM_VISUAL_LINE)},
+	vimKey(CTRL_V):     {Run: vimVisual(VIM_VISUAL_BLOCK)},
+	":":                {Run: (*Vim).cmdEx},
+	vimKey(ESC):        {Run: vimVisual(VIM_NORMAL)},
+	vimKey(CTRL_C):     {Run: vimVisual(VIM_NORMAL)},
+})
+
+var (
+	vimNormalKeys = vimMerge(vimMotionKeys, vimOperatorKeys, vimCommandKeys)
+	vimVisualKeys = vimMerge(vimMotionKeys, vimVisualOperatorKeys, vimVisualCommandKeys)
+)
+
+// ビジュアルモードでテキストオブジェクトを選択するコマンド (viwなど)
+var vimSelectObject = &vimBinding{Kind: VIM_COMMAND, Run: (*Vim).cmdSelectObject}
+
+// 左へ移動 (h)
+func (m *Vim) moveLeft(v *View, e *Editor, c *vimCmd) bool {
+	if e.Cursor.Col <= 1 {
+		return false
+	}
+	e.MoveTargetCol(uint(max(1, int(e.Cursor.Col)-c.count1())))
+	return true
+}
+
+// 右へ移動 (l、演算子の範囲では行末の後ろまで)
+func (m *Vim) moveRight(v *View, e *Editor, c *vimCmd) bool {
+	limit := uint(len(e.currentLine()))
+	if c.Pending || limit == 0 {
+		limit++
+	}
+	if e.Cursor.Col >= limit {
+		return false
+	}
+	e.MoveTargetCol(min(e.Cursor.Col+uint(c.count1()), limit))
+	return true
+}
+
+// 下の行へ移動 (j)
+func (m *Vim) moveDown(v *View, e *Editor, c *vimCmd) bool {
+	if e.IsLastRow() {
+		return false
+	}
+	for i := 0; i < c.count1() && !e.IsLastRow(); i++ {
+		e.MoveNextRow()
+	}
+	return true
+}
+
+// 上の行へ移動 (k)
+func (m *Vim) moveUp(v *View, e *Editor, c *vimCmd) bool {
+	if e.IsFirstRow() {
+		return false
+	}
+	for i := 0; i < c.count1() && !e.IsFirstRow(); i++ {
+		e.MovePrevRow()
+	}
+	return true
+}
+
+// 下の行の最初の文字へ移動 (+・Enter)
+func (m *Vim) moveDownFirst(v *View, e *Editor, c *vimCmd) bool {
+	if !m.moveDown(v, e, c) {
+		return false
+	}
+	return m.moveFirstNonBlank(v, e, c)
+}
+
+// 上の行の最初の文字へ移動 (-)
+func (m *Vim) moveUpFirst(v *View, e *Editor, c *vimCmd) bool {
+	if !m.moveUp(v, e, c) {
+		return false
+	}
+	return m.moveFirstNonBlank(v, e, c)
+}
+
+// 回数-1行下の最初の文字へ移動 (_)
+func (m *Vim) moveLineFirst(v *View, e *Editor, c *vimCmd) bool {
+	for i := 1; i < c.count1() && !e.IsLastRow(); i++ {
+		e.MoveNextRow()
+	}
+	return m.moveFirstNonBlank(v, e, c)
+}
+
+// 行頭へ移動 (0)
+func (m *Vim) moveLineStart(v *View, e *Editor, c *vimCmd) bool {
+	e.MoveHeadCol()
+	return true
+}
+
+// 行頭の空白を除いた最初の文字へ移動 (^)
+func (m *Vim) moveFirstNonBlank(v *View, e *Editor, c *vimCmd) bool {
+	e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+	return true
+}
+
+// 行末の文字へ移動 ($、回数-1行下の行末、上下に移動しても行末を保つ)
+func (m *Vim) moveLineEnd(v *View, e *Editor, c *vimCmd) bool {
+	for i := 1; i < c.count1() && !e.IsLastRow(); i++ {
+		e.MoveNextRow()
+	}
+	e.MoveTargetCol(max(uint(len(e.currentLine())), 1))
+	e.Cursor.Want = math.MaxInt32
+	return true
+}
+
+// 表示上の列へ移動 (|)
+func (m *Vim) moveColumn(v *View, e *Editor, c *vimCmd) bool {
+	line := e.currentLine()
+	idx := utils.IndexAtCell(line, c.count1()-1, int(e.TabSize))
+	e.MoveTargetCol(uint(min(idx, max(len(line)-1, 0))) + 1)
+	return true
+}
+
+// 前の段落へ移動 ({)
+func (m *Vim) moveParagraphUp(v *View, e *Editor, c *vimCmd) bool {
+	for i := 0; i < c.count1(); i++ {
+		e.MoveParagraphUp()
+	}
+	return true
+}
+
+// 次の段落へ移動 (})
+func (m *Vim) moveParagraphDown(v *View, e *Editor, c *vimCmd) bool {
+	for i := 0; i < c.count1(); i++ {
+		e.MoveParagraphDown()
+	}
+	return true
+}
+
+// 対応する括弧へ移動 (%、回数を指定した場合はファイルのN%の行へ移動)
+func (m *Vim) moveMatch(v *View, e *Editor, c *vimCmd) bool {
+	if c.Count > 0 {
+		if c.Count > 100 {
+			return false
+		}
+		e.MoveTargetRow(uint((c.Count*len(e.Lines) + 99) / 100))
+		c.Type = MOTION_LINEWISE
+		return m.moveFirstNonBlank(v, e, c)
+	}
+	lineAt := func(row int) []rune { return e.Lines[row].GetAll() }
+	row, col, ok := core.MatchBracket(lineAt, len(e.Lines), int(e.Cursor.Row-1), int(e.Cursor.Col-1))
+	if !ok {
+		return false
+	}
+	e.MoveTargetRow(uint(row + 1))
+	e.MoveTargetCol(uint(col + 1))
+	return true
+}
+
+// 行番号への移動 (gg・G、回数を省略した場合は先頭・末尾の行)
+func vimGotoLine(last bool) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		row := uint(c.Count)
+		if row == 0 {
+			row = 1
+			if last {
+				row = uint(len(e.Lines))
+			}
+		}
+		e.MoveTargetRow(min(row, uint(len(e.Lines))))
+		return m.moveFirstNonBlank(v, e, c)
+	}
+}
+
+// 次の単語の先頭へ移動 (w・W)
+// 演算子の範囲では最後に移動する単語が行末にある場合はその行末までとする
+func vimWordForward(classOf core.ClassFunc) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		moved := false
+		for i := 0; i < c.count1(); i++ {
+			line := e.currentLine()
+			if idx := core.NextWordStartBy(line, int(e.Cursor.Col-1), classOf); idx < len(line) {
+				e.MoveTargetCol(uint(idx) + 1)
+				moved = true
+				continue
+			}
+			if e.IsLastRow() || (c.Pending && i == c.count1()-1) {
+				if end := uint(len(line)) + 1; e.Cursor.Col < end {
+					e.MoveTargetCol(end)
+					moved = true
+				}
+				break
+			}
+			for !e.IsLastRow() { // 空白のみの行は読み飛ばす
+				e.MoveNextRow()
+				line = e.currentLine()
+				idx := core.FirstNonBlank(line)
+				e.MoveTargetCol(uint(idx) + 1)
+				if len(line) == 0 || idx < len(line) {
+					break
+				}
+			}
+			moved = true
+		}
+		return moved
+	}
+}
+
+// 前の単語の先頭へ移動 (b・B)
+func vimWordBackward(classOf core.ClassFunc) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		moved := false
+		for i := 0; i < c.count1(); i++ {
+			line := e.currentLine()
+			col := int(e.Cursor.Col - 1)
+			if idx := core.PrevWordStartBy(line, col, classOf); idx < col && classOf(line[idx]) != core.CHAR_SPACE {
+				e.MoveTargetCol(uint(idx) + 1)
+				moved = true
+				continue
+			}
+			found := false
+			for !found && !e.IsFirstRow() { // 空白のみの行は読み飛ばす
+				e.MovePrevRow()
+				line = e.currentLine()
+				idx := core.PrevWordStartBy(line, len(line), classOf)
+				if len(line) == 0 || classOf(line[idx]) != core.CHAR_SPACE {
+					e.MoveTargetCol(uint(idx) + 1)
+					found = true
+				}
+			}
+			if !found {
+				if e.Cursor.Row == 1 && e.Cursor.Col > 1 {
+					e.MoveHeadCol()
+					moved = true
+				}
+				break
+			}
+			moved = true
+		}
+		return moved
+	}
+}
+
+// 単語の末尾へ移動 (e・E)
+func vimWordEnd(classOf core.ClassFunc) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		moved := false
+		for i := 0; i < c.count1(); i++ {
+			line := e.currentLine()
+			if idx := core.WordEnd(line, int(e.Cursor.Col-1), classOf); idx < len(line) {
+				e.MoveTargetCol(uint(idx) + 1)
+				moved = true
+				continue
+			}
+			row := e.Cursor.Row
+			found := false
+			for !found && e.hasRow(row+1) {
+				row++
+				line = e.LineRunes(row)
+				if idx := core.WordEnd(line, -1, classOf); idx < len(line) {
+					e.MoveTargetRow(row)
+					e.MoveTargetCol(uint(idx) + 1)
+					found = true
+				}
+			}
+			if !found {
+				break
+			}
+			moved = true
+		}
+		return moved
+	}
+}
+
+// 行内の文字への移動 (f・F・t・T)
+func vimFind(cmd rune) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		m.lastFind = [2]rune{cmd, c.Arg}
+		return findChar(e, c, cmd, c.Arg, false)
+	}
+}
+
+// 直前の行内の文字への移動の繰り返し (;・reverseの場合は逆方向の,)
+func vimRepeatFind(reverse bool) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	opposite := map[rune]rune{'f': 'F', 'F': 'f', 't': 'T', 'T': 't'}
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		cmd := m.lastFind[0]
+		if cmd == 0 {
+			return false
+		}
+		if reverse {
+			cmd = opposite[cmd]
+		}
+		return findChar(e, c, cmd, m.lastFind[1], true)
+	}
+}
+
+// 行内で回数分目の文字chの位置へ移動 (tとTは手前の位置)
+// 繰り返しの場合は既に手前にいる文字を読み飛ばす
+func findChar(e *Editor, c *vimCmd, cmd rune, ch rune, repeat bool) bool {
+	line := e.currentLine()
+	forward := cmd == 'f' || cmd == 't'
+	till := cmd == 't' || cmd == 'T'
+	step := 1
+	c.Type = MOTION_INCLUSIVE
+	if !forward {
+		step = -1
+		c.Type = MOTION_EXCLUSIVE
+	}
+	idx := int(e.Cursor.Col - 1)
+	if till && repeat {
+		idx += step
+	}
+	for n := c.count1(); n > 0; {
+		idx += step
+		if idx < 0 || idx >= len(line) {
+			return false
+		}
+		if line[idx] == ch {
+			n--
+		}
+	}
+	if till {
+		idx -= step
+	}
+	e.MoveTargetCol(uint(idx) + 1)
+	return true
+}
+
+// 画面内の行への移動 (H・M・L、posは0:上端 1:中央 2:下端)
+func vimScreenRow(pos int) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		top := e.ScrollRow
+		bottom := e.stepVisible(top, int(v.Focus.TextHeight())-1)
+		row := e.stepVisible(top, (visibleCount(e.closedFolds(), top, bottom+1)-1)/2)
+		switch pos {
+		case 0:
+			row = min(e.stepVisible(top, c.count1()-1), bottom)
+		case 2:
+			row = max(e.stepVisible(bottom, 1-c.count1()), top)
+		}
+		e.MoveTargetRow(row)
+		return m.moveFirstNonBlank(v, e, c)
+	}
+}
+
+// 画面単位のスクロール (halvesは半画面単位の量と方向)
+func vimPage(halves int) func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+	return func(m *Vim, v *View, e *Editor, c *vimCmd) bool {
+		delta := int(v.Focus.TextHeight()) * halves / 2
+		if halves == 2 || halves == -2 {
+			delta *= c.count1()
+		}
+		row := e.Cursor.Row
+		e.MovePage(delta)
+		return e.Cursor.Row != row
+	}
+}
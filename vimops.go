@@ -0,0 +1,623 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/broccolingual/Xanadu/core"
+	"github.com/broccolingual/Xanadu/utils"
+)
+
+// 演算子の範囲の各行の列の範囲[from, to)に対する処理
+func eachRangeRow(e *Editor, c *vimCmd, f func(row uint, from uint, to uint)) {
+	for row := c.Start.Row; row <= c.End.Row; row++ {
+		from, to := uint(1), uint(e.Lines[row-1].Length())+1
+		switch {
+		case c.Block:
+			from, to = e.blockCols(row)
+		case c.Type == MOTION_LINEWISE:
+		default:
+			if row == c.Start.Row {
+				from = c.Start.Col
+			}
+			if row == c.End.Row {
+				to = c.End.Col
+			}
+		}
+		if from < to {
+			f(row, from, to)
+		}
+	}
+}
+
+// 演算子の範囲の内容をレジスタの形式で取得
+func rangeRegister(e *Editor, c *vimCmd) Register {
+	switch {
+	case c.Block:
+		return Register{REG_BLOCK, e.BlockText()}
+	case c.Type == MOTION_LINEWISE:
+		return Register{REG_LINES, e.LinesText(c.Start.Row, c.End.Row)}
+	}
+	return Register{REG_CHARS, strings.Split(string(e.TextRange(c.Start, c.End)), "\n")}
+}
+
+// 演算子の範囲の削除 (カーソルは範囲の先頭に移動)
+func deleteRange(e *Editor, c *vimCmd) {
+	switch {
+	case c.Block:
+		e.DeleteBlock()
+	case c.Type == MOTION_LINEWISE:
+		e.DeleteLines(c.Start.Row, c.End.Row)
+		e.MoveTargetRow(min(c.Start.Row, uint(len(e.Lines))))
+		e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+	default:
+		e.DeleteRange(c.Start, c.End)
+		e.MoveTargetRow(c.Start.Row)
+		e.MoveTargetCol(c.Start.Col)
+	}
+}
+
+// 演算子の範囲の先頭 (矩形の場合は左上) へカーソルを移動
+func moveRangeStart(e *Editor, c *vimCmd) {
+	if c.Block {
+		col, _ := e.blockCols(c.Start.Row)
+		e.ClearSelection()
+		e.MoveTargetRow(c.Start.Row)
+		e.MoveTargetCol(col)
+		return
+	}
+	if c.Type == MOTION_LINEWISE {
+		if e.Cursor.Row != c.Start.Row {
+			e.MoveTargetRow(c.Start.Row)
+		}
+		return
+	}
+	e.MoveTargetRow(c.Start.Row)
+	e.MoveTargetCol(c.Start.Col)
+}
+
+// 削除 (d)
+func (m *Vim) opDelete(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	e.SaveUndo()
+	v.SetRegister(c.Reg, rangeRegister(e, c), true)
+	deleteRange(e, c)
+	return 0
+}
+
+// コピー (y)
+func (m *Vim) opYank(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	reg := rangeRegister(e, c)
+	v.SetRegister(c.Reg, reg, false)
+	if reg.Kind == REG_LINES && len(reg.Lines) > 2 {
+		v.SetMessage("%d lines yanked", len(reg.Lines))
+	}
+	moveRangeStart(e, c)
+	return 0
+}
+
+// 変更 (c、行単位の場合は最初の行のインデントを残す)
+func (m *Vim) opChange(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	e.SaveUndo()
+	v.SetRegister(c.Reg, rangeRegister(e, c), true)
+	if c.Type == MOTION_LINEWISE && !c.Block {
+		line := e.LineRunes(c.Start.Row)
+		indent := line[:core.FirstNonBlank(line)]
+		e.ReplaceLine(c.Start.Row, indent)
+		if c.End.Row > c.Start.Row {
+			e.DeleteLines(c.Start.Row+1, c.End.Row)
+		}
+		e.MoveTargetRow(c.Start.Row)
+		e.MoveTargetCol(uint(len(indent)) + 1)
+	} else if c.Block { // 矩形は各行の左端に挿入
+		left, _ := e.BlockCells()
+		deleteRange(e, c)
+		e.PlaceColumnCarets(c.Start.Row, c.End.Row, left, false)
+	} else {
+		deleteRange(e, c)
+	}
+	m.startInsert(v, 1)
+	return 0
+}
+
+// インデントの変更 (>・<、dirは増減の方向で空の行は変更しない)
+// ビジュアルモードでは回数をインデントの段数とする
+func vimShift(dir int) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		e := v.GetCurrentTab()
+		levels := 1
+		if c.Visual {
+			levels = c.count1()
+		}
+		width := max(int(e.TabSize), 1) * levels * dir
+		e.SaveUndo()
+		for row := c.Start.Row; row <= c.End.Row; row++ {
+			line := e.LineRunes(row)
+			if len(line) == 0 {
+				continue
+			}
+			indent := core.FirstNonBlank(line)
+			spaces := []rune(strings.Repeat(" ", max(indent+width, 0)))
+			e.ReplaceLine(row, append(spaces, line[indent:]...))
+		}
+		if c.Block {
+			e.ClearSelection()
+		}
+		e.MoveTargetRow(c.Start.Row)
+		e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+		return 0
+	}
+}
+
+// 大文字・小文字の変換 (g~・gu・gU)
+func vimCase(convert func(r rune) rune) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		e := v.GetCurrentTab()
+		e.SaveUndo()
+		eachRangeRow(e, c, func(row uint, from uint, to uint) {
+			line := e.LineRunes(row)
+			for i := from - 1; i < to-1; i++ {
+				line[i] = convert(line[i])
+			}
+			e.ReplaceLine(row, line)
+		})
+		moveRangeStart(e, c)
+		return 0
+	}
+}
+
+// 大文字と小文字の入れ替え
+func toggleCase(r rune) rune {
+	if unicode.IsUpper(r) {
+		return unicode.ToLower(r)
+	}
+	return unicode.ToUpper(r)
+}
+
+// レジスタの内容の貼り付け (p・P)
+func vimPaste(after bool) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		reg, ok := v.GetRegister(c.Reg)
+		if !ok {
+			v.SetMessage("E353: Nothing in register %c", c.Reg)
+			return 0
+		}
+		e := v.GetCurrentTab()
+		e.SaveUndo()
+		pasteRegister(e, reg, c.count1(), after)
+		return 0
+	}
+}
+
+// レジスタの内容をcount回貼り付け (afterの場合はカーソルの後ろ・下の行に貼り付ける)
+func pasteRegister(e *Editor, reg Register, count int, after bool) {
+	switch reg.Kind {
+	case REG_LINES:
+		lines := make([]string, 0, len(reg.Lines)*count)
+		for i := 0; i < count; i++ {
+			lines = append(lines, reg.Lines...)
+		}
+		row := e.Cursor.Row
+		if after {
+			row++
+		}
+		e.InsertLines(row, lines)
+		e.MoveTargetRow(row)
+		e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+	case REG_BLOCK:
+		line := e.currentLine()
+		cell := int(e.DisplayCol()) - 1
+		if after && len(line) > 0 {
+			cell = utils.CellsBefore(line, int(e.Cursor.Col), int(e.TabSize))
+		}
+		lines := make([]string, len(reg.Lines))
+		for i, text := range reg.Lines {
+			lines[i] = strings.Repeat(text, count)
+		}
+		e.InsertBlock(e.Cursor.Row, cell, lines)
+		e.MoveTargetCol(uint(utils.IndexAtCell(e.currentLine(), cell, int(e.TabSize))) + 1)
+	default:
+		at := Cursor{Row: e.Cursor.Row, Col: e.Cursor.Col}
+		if after && len(e.currentLine()) > 0 {
+			at.Col++
+		}
+		end := e.InsertText(at, []rune(strings.Repeat(reg.Text(), count)))
+		if len(reg.Lines) > 1 { // 複数行の場合は貼り付けた先頭の位置
+			e.MoveTargetRow(at.Row)
+			e.MoveTargetCol(at.Col)
+			return
+		}
+		e.MoveTargetCol(max(end.Col-1, 1))
+	}
+}
+
+// ビジュアルモードでの挿入 (I・A、矩形の場合は各行の左端・右端、それ以外は選択範囲の先頭・末尾)
+// 矩形の右端への挿入では短い行を空白で埋め、$で行末まで選択した場合は各行の行末に挿入する
+func vimVisualInsert(after bool) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		if m.lostSelection(v) {
+			return 0
+		}
+		e := v.GetCurrentTab()
+		start, end, _ := e.Selection()
+		if m.Mode != VIM_VISUAL_BLOCK {
+			pos := start
+			if after && m.Mode == VIM_VISUAL_LINE {
+				row := max(e.Anchor.Row, e.Cursor.Row)
+				pos = Cursor{Row: row, Col: uint(e.Lines[row-1].Length()) + 1}
+			} else if after {
+				pos = end
+			}
+			m.setMode(v, VIM_NORMAL)
+			e.MoveTargetRow(pos.Row)
+			e.MoveTargetCol(pos.Col)
+			m.startInsert(v, 1)
+			return 0
+		}
+		e.SaveUndo()
+		m.visualRows = [2]uint{start.Row, end.Row}
+		left, right := e.BlockCells()
+		placed := false
+		switch {
+		case !after:
+			placed = e.PlaceColumnCarets(start.Row, end.Row, left, false)
+		case e.Cursor.Want == math.MaxInt32:
+			placed = e.PlaceColumnCarets(start.Row, end.Row, -1, false)
+		default:
+			placed = e.PlaceColumnCarets(start.Row, end.Row, right, true)
+		}
+		if !placed {
+			e.MoveTargetRow(start.Row)
+			e.MoveTargetCol(start.Col)
+		}
+		m.startInsert(v, 1)
+		return 0
+	}
+}
+
+// 選択範囲をレジスタの内容で置き換え (ビジュアルモードのp・P)
+func (m *Vim) cmdVisualPaste(v *View, c *vimCmd) uint8 {
+	reg, ok := v.GetRegister(c.Reg)
+	if !ok {
+		m.setMode(v, VIM_NORMAL)
+		v.SetMessage("E353: Nothing in register %c", c.Reg)
+		return 0
+	}
+	e := v.GetCurrentTab()
+	if !m.visualRange(v, c) {
+		return 0
+	}
+	e.SaveUndo()
+	v.SetRegister('"', rangeRegister(e, c), true)
+	deleteRange(e, c)
+	emptied := len(e.Lines) == 1 && e.Lines[0].Length() == 0
+	switch {
+	case c.Type == MOTION_LINEWISE && reg.Kind == REG_LINES:
+		pasteRegister(e, reg, c.count1(), false)
+		if emptied { // 全ての行を置き換えた場合は残った空の行を削除
+			e.DeleteLines(uint(len(e.Lines)), uint(len(e.Lines)))
+		}
+		e.MoveTargetRow(c.Start.Row)
+		return 0
+	case c.Type == MOTION_LINEWISE:
+		if !emptied {
+			e.InsertLines(c.Start.Row, []string{""})
+		}
+		e.MoveTargetRow(c.Start.Row)
+		e.MoveHeadCol()
+	case reg.Kind == REG_LINES: // 文字単位の選択範囲に行を貼り付ける場合は行を分割
+		e.InsertText(*e.Cursor, []rune("\n"))
+		e.MoveTargetRow(e.Cursor.Row + 1)
+		e.MoveHeadCol()
+	}
+	pasteRegister(e, reg, c.count1(), false)
+	return 0
+}
+
+// 挿入モードの開始 (i)
+func (m *Vim) cmdInsert(v *View, c *vimCmd) uint8 {
+	m.startInsert(v, c.count1())
+	return 0
+}
+
+// カーソルの後ろから挿入 (a)
+func (m *Vim) cmdAppend(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	if len(e.currentLine()) > 0 {
+		e.MoveTargetCol(e.Cursor.Col + 1)
+	}
+	m.startInsert(v, c.count1())
+	return 0
+}
+
+// 行頭の空白を除いた位置から挿入 (I)
+func (m *Vim) cmdInsertLineStart(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	e.MoveTargetCol(uint(core.FirstNonBlank(e.currentLine())) + 1)
+	m.startInsert(v, c.count1())
+	return 0
+}
+
+// 行末から挿入 (A)
+func (m *Vim) cmdAppendLineEnd(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	e.MoveTargetCol(uint(len(e.currentLine())) + 1)
+	m.startInsert(v, c.count1())
+	return 0
+}
+
+// 新しい行を追加して挿入 (o・O、現在の行のインデントを引き継ぐ)
+func vimOpenLine(below bool) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		e := v.GetCurrentTab()
+		e.SaveUndo()
+		line := e.currentLine()
+		width := core.FirstNonBlank(line)
+		row := e.Cursor.Row
+		if below { // 下の行は開き括弧などの後では1段階深くする
+			width, _ = core.NextIndent(line, e.indentOpeners(), e.indentUnit())
+			row++
+		}
+		indent := strings.Repeat(" ", width)
+		e.InsertLines(row, []string{indent})
+		e.MoveTargetRow(row)
+		e.MoveTargetCol(uint(len([]rune(indent))) + 1)
+		m.startInsert(v, 1)
+		return 0
+	}
+}
+
+// カーソル位置から回数分の文字の大文字・小文字の入れ替え (~)
+func (m *Vim) cmdToggleCase(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	line := e.currentLine()
+	if len(line) == 0 {
+		return 0
+	}
+	e.SaveUndo()
+	from := int(e.Cursor.Col - 1)
+	to := min(from+c.count1(), len(line))
+	for i := from; i < to; i++ {
+		line[i] = toggleCase(line[i])
+	}
+	e.ReplaceLine(e.Cursor.Row, line)
+	e.MoveTargetCol(uint(to) + 1)
+	return 0
+}
+
+// カーソル位置から回数分の文字の置き換え (r、Enterの場合は改行に置き換える)
+func (m *Vim) cmdReplace(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	line := e.currentLine()
+	from := int(e.Cursor.Col - 1)
+	n := c.count1()
+	if from+n > len(line) || (c.Arg != CTRL_M && !isInsertable(c.Arg)) {
+		return 0
+	}
+	e.SaveUndo()
+	row := e.Cursor.Row
+	if c.Arg == CTRL_M {
+		e.DeleteRange(Cursor{Row: row, Col: uint(from) + 1}, Cursor{Row: row, Col: uint(from+n) + 1})
+		e.InsertText(Cursor{Row: row, Col: uint(from) + 1}, []rune("\n"))
+		e.MoveTargetRow(row + 1)
+		e.MoveHeadCol()
+		return 0
+	}
+	for i := from; i < from+n; i++ {
+		line[i] = c.Arg
+	}
+	e.ReplaceLine(row, line)
+	e.MoveTargetCol(uint(from + n))
+	return 0
+}
+
+// 行の連結 (J、回数は連結する行数)
+func (m *Vim) cmdJoin(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	joinLines(e, e.Cursor.Row, max(c.count1()-1, 1))
+	return 0
+}
+
+// 選択した行の連結 (ビジュアルモードのJ)
+func (m *Vim) cmdVisualJoin(v *View, c *vimCmd) uint8 {
+	if m.lostSelection(v) {
+		return 0
+	}
+	e := v.GetCurrentTab()
+	top, bottom := min(e.Anchor.Row, e.Cursor.Row), max(e.Anchor.Row, e.Cursor.Row)
+	m.setMode(v, VIM_NORMAL)
+	joinLines(e, top, max(int(bottom-top), 1))
+	return 0
+}
+
+// rowの行に続くn行の連結 (行頭の空白を除き、空白1つを挟んで連結する)
+// カーソルは最後に連結した位置に移動する
+func joinLines(e *Editor, row uint, n int) {
+	if !e.hasRow(row + 1) {
+		return
+	}
+	e.SaveUndo()
+	col := e.Cursor.Col
+	for i := 0; i < n && e.hasRow(row+1); i++ {
+		line := e.LineRunes(row)
+		next := e.LineRunes(row + 1)
+		next = next[core.FirstNonBlank(next):]
+		col = uint(len(line)) + 1
+		if len(line) > 0 && len(next) > 0 && !unicode.IsSpace(line[len(line)-1]) && next[0] != ')' {
+			line = append(line, ' ')
+		}
+		e.ReplaceLine(row, append(line, next...))
+		e.DeleteLines(row+1, row+1)
+	}
+	e.MoveTargetRow(row)
+	e.MoveTargetCol(col)
+}
+
+// ビジュアルモードの切り替え (同じモードの場合はノーマルモードに戻る)
+func vimVisual(mode VimMode) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		if m.Mode == mode {
+			m.setMode(v, VIM_NORMAL)
+			return 0
+		}
+		m.setMode(v, mode)
+		return 0
+	}
+}
+
+// 選択範囲を行単位に広げて演算子を適用 (ビジュアルモードのX・D・C・S・R・Y)
+func vimVisualLines(op func(m *Vim, v *View, c *vimCmd) uint8) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		m.setMode(v, VIM_VISUAL_LINE)
+		if !m.visualRange(v, c) {
+			return 0
+		}
+		return op(m, v, c)
+	}
+}
+
+// 選択範囲の開始位置とカーソル位置の入れ替え (ビジュアルモードのo)
+func (m *Vim) cmdSwapEnds(v *View, c *vimCmd) uint8 {
+	if m.lostSelection(v) {
+		return 0
+	}
+	e := v.GetCurrentTab()
+	anchor := *e.Anchor
+	*e.Anchor = Cursor{Row: e.Cursor.Row, Col: e.Cursor.Col}
+	*e.Cursor = Cursor{Row: anchor.Row, Col: anchor.Col}
+	return 0
+}
+
+// テキストオブジェクトの選択 (ビジュアルモードのiw・a(など)
+func (m *Vim) cmdSelectObject(v *View, c *vimCmd) uint8 {
+	if m.lostSelection(v) {
+		return 0
+	}
+	e := v.GetCurrentTab()
+	if !c.Start.Before(c.End) && c.Type != MOTION_LINEWISE {
+		return 0
+	}
+	if c.Type == MOTION_LINEWISE {
+		if m.Mode == VIM_VISUAL {
+			m.setMode(v, VIM_VISUAL_LINE)
+		}
+		*e.Anchor = Cursor{Row: c.Start.Row, Col: 1}
+		*e.Cursor = Cursor{Row: c.End.Row, Col: 1}
+		return 0
+	}
+	end := c.End
+	if end.Col > 1 {
+		end.Col--
+	} else {
+		end = Cursor{Row: end.Row - 1, Col: uint(e.Lines[end.Row-2].Length()) + 1}
+	}
+	*e.Anchor = Cursor{Row: c.Start.Row, Col: c.Start.Col}
+	*e.Cursor = end
+	return 0
+}
+
+// 取り消し (u)
+func (m *Vim) cmdUndo(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	for i := 0; i < c.count1(); i++ {
+		if !e.Undo() {
+			if i == 0 {
+				v.SetMessage("Already at oldest change")
+			}
+			break
+		}
+	}
+	v.Reflesh()
+	return 0
+}
+
+// やり直し (Ctrl+R)
+func (m *Vim) cmdRedo(v *View, c *vimCmd) uint8 {
+	e := v.GetCurrentTab()
+	for i := 0; i < c.count1(); i++ {
+		if !e.Redo() {
+			if i == 0 {
+				v.SetMessage("Already at newest change")
+			}
+			break
+		}
+	}
+	v.Reflesh()
+	return 0
+}
+
+// 直前の変更の繰り返し (.、回数を指定した場合は元の回数の代わりに使用する)
+func (m *Vim) cmdRepeat(v *View, c *vimCmd) uint8 {
+	keys := m.lastChange
+	if len(keys) == 0 {
+		return 0
+	}
+	if c.Count > 0 { // 元の回数を除いてレジスタ名の後ろに回数を付ける
+		_, i := readCount(keys, 0)
+		reg := []rune{}
+		if i+1 < len(keys) && keys[i] == '"' {
+			reg = keys[i : i+2]
+			_, i = readCount(keys, i+2)
+		}
+		keys = append(append(append([]rune{}, reg...), []rune(strconv.Itoa(c.Count))...), keys[i:]...)
+	}
+	for _, k := range keys {
+		if code := v.handleKey(k); code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+// exコマンドの入力 (:、回数を指定した場合はその行数の範囲、ビジュアルモードでは選択した行の範囲)
+func (m *Vim) cmdEx(v *View, c *vimCmd) uint8 {
+	initial := ""
+	switch {
+	case m.Mode != VIM_NORMAL:
+		m.setMode(v, VIM_NORMAL)
+		initial = "'<,'>"
+	case c.Count > 1:
+		initial = fmt.Sprintf(".,.+%d", c.Count-1)
+	}
+	m.refresh(v)
+	m.promptEx(v, initial)
+	return 0
+}
+
+// マクロの記録の開始 (q{レジスタ}、記録中のqは記録の終了)
+func (m *Vim) cmdRecord(v *View, c *vimCmd) uint8 {
+	if !isMacroName(c.Arg) {
+		v.Fail()
+		return 0
+	}
+	v.StartRecording(c.Arg)
+	return 0
+}
+
+// マクロの再生 ([回数]@{レジスタ}、@@は最後に再生したマクロ)
+func (m *Vim) cmdPlay(v *View, c *vimCmd) uint8 {
+	if !isMacroName(c.Arg) && c.Arg != '@' {
+		v.Fail()
+		return 0
+	}
+	return v.PlayMacro(c.Arg, c.Count)
+}
+
+// exコマンドの実行 (ZZ・ZQ)
+func vimEx(command string) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		return m.runEx(v, command)
+	}
+}
+
+// アクションの実行
+func vimAction(name string) func(m *Vim, v *View, c *vimCmd) uint8 {
+	return func(m *Vim, v *View, c *vimCmd) uint8 {
+		return v.RunAction(name)
+	}
+}